@@ -107,7 +107,7 @@ func diffCmd() *cli.Command {
 		Short: "differences between the configuration and the cluster",
 		Args:  workflowArgs,
 		Predictors: complete.Flags{
-			"diff-strategy": cli.PredictSet("native", "subset"),
+			"diff-strategy": cli.PredictSet("native", "subset", "server", "auto", "exact", "mixed"),
 		},
 	}
 
@@ -116,6 +116,14 @@ func diffCmd() *cli.Command {
 	cmd.Flags().BoolVarP(&opts.Summarize, "summarize", "s", false, "print summary of the differences, not the actual contents")
 	cmd.Flags().BoolVarP(&opts.WithPrune, "with-prune", "p", false, "include objects deleted from the configuration in the differences")
 	cmd.Flags().BoolVarP(&opts.ExitZero, "exit-zero", "z", false, "Exit with 0 even when differences are found.")
+	cmd.Flags().StringSliceVar(&opts.Kinds, "kinds", nil, "only diff objects of these kinds, e.g. Deployment,Service")
+	cmd.Flags().BoolVar(&opts.SortByChanges, "sort-by-changes", false, "show the objects with the most changed lines first")
+	cmd.Flags().IntVar(&opts.MinChanges, "min-changes", 0, "hide objects with less changed lines than this")
+	cmd.Flags().BoolVar(&opts.OnlyNew, "only-new", false, "only show objects that do not exist in the cluster yet")
+	cmd.Flags().BoolVar(&opts.ShowStatusReplicas, "show-status-replicas", false, "show the replica counts of the status of workloads (subset and exact strategies only)")
+	cmd.Flags().BoolVar(&opts.AwaitCRDs, "await-crds", false, "show custom resources as created if their CRD is part of the environment, but not applied yet")
+	wrap := cmd.Flags().Int("wrap", 0, "wrap lines of the diff longer than this many columns, keeping their +/- prefix. Disabled if 0")
+	rendered := cmd.Flags().String("rendered", "", "compare the cluster to the manifests in this directory (e.g. output of tk export) instead of evaluating Jsonnet")
 
 	vars := workflowFlags(cmd.Flags())
 	getJsonnetOpts := jsonnetFlags(cmd.Flags())
@@ -129,7 +137,14 @@ func diffCmd() *cli.Command {
 		opts.JsonnetOpts = getJsonnetOpts()
 		opts.Name = vars.name
 
-		changes, err := tanka.Diff(args[0], opts)
+		diff := tanka.Diff
+		if *rendered != "" {
+			diff = func(baseDir string, opts tanka.DiffOpts) (*string, error) {
+				return tanka.DiffRendered(baseDir, *rendered, opts)
+			}
+		}
+
+		changes, err := diff(args[0], opts)
 		if err != nil {
 			return err
 		}
@@ -139,7 +154,7 @@ func diffCmd() *cli.Command {
 			os.Exit(ExitStatusClean)
 		}
 
-		r := term.Colordiff(*changes)
+		r := term.Colordiff(term.Wrap(*changes, *wrap))
 		if err := fPageln(r); err != nil {
 			return err
 		}
@@ -10,6 +10,7 @@ import (
 	jsonnet "github.com/google/go-jsonnet"
 	"github.com/google/go-jsonnet/ast"
 	"github.com/grafana/tanka/pkg/helm"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
 	"github.com/grafana/tanka/pkg/kustomize"
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v3"
@@ -37,15 +38,21 @@ func Funcs() []*jsonnet.NativeFunction {
 	}
 }
 
-// parseJSON wraps `json.Unmarshal` to convert a json string into a dict
+// parseJSON wraps `json.Unmarshal` to convert a json string into a dict.
+// Unlike `json.Unmarshal`, duplicate keys are an error
 func parseJSON() *jsonnet.NativeFunction {
 	return &jsonnet.NativeFunction{
 		Name:   "parseJson",
 		Params: ast.Identifiers{"json"},
 		Func: func(dataString []interface{}) (res interface{}, err error) {
 			data := []byte(dataString[0].(string))
-			err = json.Unmarshal(data, &res)
-			return
+			if err := json.Unmarshal(data, &res); err != nil {
+				return nil, err
+			}
+			if err := manifest.CheckDuplicateKeys(data); err != nil {
+				return nil, errors.Wrap(err, "parsing json")
+			}
+			return res, nil
 		},
 	}
 }
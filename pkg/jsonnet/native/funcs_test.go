@@ -45,6 +45,14 @@ func TestParseJSONInvalid(t *testing.T) {
 	assert.IsType(t, &json.SyntaxError{}, err)
 }
 
+func TestParseJSONDuplicateKey(t *testing.T) {
+	ret, err, callerr := callNative("parseJson", []interface{}{"{\n  \"a\": {\"b\": 1,\n  \"b\": 2}\n}"})
+
+	assert.Empty(t, callerr)
+	assert.Empty(t, ret)
+	assert.EqualError(t, err, "parsing json: duplicate key 'a.b' in line 3")
+}
+
 func TestParseYAMLEmpty(t *testing.T) {
 	ret, err, callerr := callNative("parseYaml", []interface{}{""})
 
@@ -69,6 +77,14 @@ func TestParseYAMLInvalid(t *testing.T) {
 	assert.NotEmpty(t, err)
 }
 
+func TestParseYAMLDuplicateKey(t *testing.T) {
+	ret, err, callerr := callNative("parseYaml", []interface{}{"a: 1\n---\nb: 1\nb: 2\n"})
+
+	assert.Empty(t, callerr)
+	assert.Empty(t, ret)
+	assert.Contains(t, err.Error(), `line 4: mapping key "b" already defined at line 3`)
+}
+
 func TestManifestJSONFromJSON(t *testing.T) {
 	ret, err, callerr := callNative("manifestJsonFromJson", []interface{}{"{}", float64(4)})
 
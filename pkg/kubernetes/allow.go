@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// AllowRule limits the diff of objects of a certain kind to the given fields,
+// the inverse of IgnoreRule. All other fields are removed from both the
+// desired and the live state before comparing.
+type AllowRule struct {
+	// Kind the rule applies to. Empty matches all kinds
+	Kind string
+	// Paths to the compared fields in dotted notation, e.g. `spec.replicas`.
+	// Lists are descended into, so `spec.template.spec.containers.image`
+	// refers to the image of every container
+	Paths []string
+}
+
+// Matches returns whether the rule applies to m
+func (r AllowRule) Matches(m manifest.Manifest) bool {
+	return r.Kind == "" || r.Kind == m.Kind()
+}
+
+// identityPaths are kept by keepPaths, so reduced objects remain identifiable
+var identityPaths = []string{"apiVersion", "kind", "metadata.name", "metadata.namespace"}
+
+// allowedPaths returns all paths to be compared for m
+func allowedPaths(rules []AllowRule, m manifest.Manifest) []string {
+	var paths []string
+	for _, r := range rules {
+		if r.Matches(m) {
+			paths = append(paths, r.Paths...)
+		}
+	}
+	return paths
+}
+
+// keepPaths returns a copy of m, that only holds the fields at the dotted
+// paths, besides the identity of the object
+func keepPaths(m manifest.Manifest, paths []string) manifest.Manifest {
+	out := make(map[string]interface{})
+	for _, p := range append(identityPaths, paths...) {
+		copyPath(out, m, splitPath(p))
+	}
+	return manifest.Manifest(out)
+}
+
+// copyPath deep copies the field at keys from src to dst, creating
+// intermediate maps and lists as required
+func copyPath(dst, src map[string]interface{}, keys []string) {
+	v, ok := src[keys[0]]
+	if !ok {
+		return
+	}
+	if len(keys) == 1 {
+		dst[keys[0]] = copyValue(v)
+		return
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		next, ok := dst[keys[0]].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			dst[keys[0]] = next
+		}
+		copyPath(next, t, keys[1:])
+	case []interface{}:
+		// the remaining path applies to every element
+		list, ok := dst[keys[0]].([]interface{})
+		if !ok {
+			list = make([]interface{}, len(t))
+			for i := range list {
+				list[i] = make(map[string]interface{})
+			}
+			dst[keys[0]] = list
+		}
+		for i, elem := range t {
+			from, ok := elem.(map[string]interface{})
+			if !ok || i >= len(list) {
+				continue
+			}
+			if to, ok := list[i].(map[string]interface{}); ok {
+				copyPath(to, from, keys[1:])
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestKeepPaths(t *testing.T) {
+	m := deploymentWithImage("grafana/grafana:7.0.0")
+	m["spec"].(map[string]interface{})["replicas"] = 2
+
+	got := keepPaths(m, []string{
+		"spec.replicas",
+		"spec.template.spec.containers.image",
+		"spec.missing.field",
+	})
+
+	assert.Equal(t, manifest.Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "grafana",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"replicas": 2,
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"image": "grafana/grafana:7.0.0"},
+					},
+				},
+			},
+		},
+	}, got)
+
+	// the input is not modified
+	assert.Contains(t, m["spec"], "selector")
+}
+
+func TestSubsetDifferAllow(t *testing.T) {
+	live := deploymentWithImage("grafana/grafana:7.0.0")
+	live["spec"].(map[string]interface{})["replicas"] = 1
+	live.Metadata()["labels"] = map[string]interface{}{"team": "a"}
+
+	local := deploymentWithImage("grafana/grafana:7.1.0")
+	local["spec"].(map[string]interface{})["replicas"] = 2
+	local.Metadata()["labels"] = map[string]interface{}{"team": "b"}
+
+	cm := configMap("config", "default", map[string]interface{}{"foo": "new"})
+
+	c := newFakeClient(live, configMap("config", "default", map[string]interface{}{"foo": "old"}))
+	opts := SubsetDiffOpts{Allow: []AllowRule{{
+		Kind:  "Deployment",
+		Paths: []string{"spec.replicas", "spec.template.spec.containers.image"},
+	}}}
+
+	diff, err := SubsetDiffer(c, opts)(manifest.List{local, cm})
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+
+	assert.Contains(t, *diff, "+  replicas: 2")
+	assert.Contains(t, *diff, "+      - image: grafana/grafana:7.1.0")
+	assert.NotContains(t, *diff, "team")
+	assert.NotContains(t, *diff, "selector")
+
+	// ConfigMaps have no rule, so nothing is compared
+	assert.NotContains(t, *diff, "foo")
+}
@@ -2,7 +2,6 @@ package kubernetes
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/grafana/tanka/pkg/kubernetes/client"
@@ -43,48 +42,19 @@ See https://tanka.dev/garbage-collection for more details.`)
 	}
 	fmt.Println("done", time.Since(start))
 
-	var orphaned manifest.List
-
-	// join all kinds that support LIST into a comma separated string for
-	// kubectl
-	kinds := ""
-	for _, r := range apiResources {
-		if !strings.Contains(r.Verbs, "list") {
-			continue
-		}
-
-		kinds += "," + r.FQN()
-	}
-	kinds = strings.TrimPrefix(kinds, ",")
+	kinds := listableKinds(apiResources)
 
 	start = time.Now()
 	fmt.Print("fetching previously created resources .. ")
 	// get all resources matching our label
-	matched, err := k.ctl.GetByLabels("", kinds, map[string]string{
+	orphaned, err := orphans(k.ctl, kinds, map[string]string{
 		process.LabelEnvironment: k.Env.Metadata.NameLabel(),
-	})
+	}, uids, func(m manifest.Manifest) string { return m.Metadata().UID() })
 	if err != nil {
 		return nil, err
 	}
 	fmt.Println("done", time.Since(start))
 
-	// filter unknown
-	for _, m := range matched {
-		// ignore known ones
-		if uids[m.Metadata().UID()] {
-			continue
-		}
-
-		// skip objects not created explicitely
-		if _, ok := m.Metadata().Annotations()[AnnotationLastApplied]; !ok {
-			continue
-		}
-
-		// record and skip from now on
-		orphaned = append(orphaned, m)
-		uids[m.Metadata().UID()] = true
-	}
-
 	return orphaned, nil
 }
 
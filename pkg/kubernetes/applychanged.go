@@ -0,0 +1,59 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/kubernetes/util"
+)
+
+// ApplyChangedOpts allow to tune ApplyChanged
+type ApplyChangedOpts struct {
+	SubsetDiffOpts
+
+	// Apply is passed to the apply of the changed objects
+	Apply ApplyOpts
+}
+
+// ApplyChanged diffs state using the subset diff and applies only the objects
+// that changed or do not exist in the cluster yet, leaving unchanged ones
+// alone. It returns the applied objects. Nothing is applied if there are none.
+func ApplyChanged(c client.Client, state manifest.List, opts ApplyChangedOpts) (manifest.List, error) {
+	result, err := diffState(c, state, opts.SubsetDiffOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "diffing")
+	}
+
+	changed := result.Changed(state)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if err := c.Apply(changed, client.ApplyOpts(opts.Apply)); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// Changed returns the objects of state that applying would create or update,
+// according to the entries of r. Objects of unknown drift (see
+// DiffEntry.Unknown) and ones lacking an entry, e.g. as they were excluded
+// from diffing, are returned as well, as they may have changed.
+func (r DiffResult) Changed(state manifest.List) manifest.List {
+	actions := make(map[string]PlanAction, len(r.Entries))
+	for _, e := range r.Entries {
+		if _, ok := actions[e.Name]; !ok {
+			actions[e.Name] = e.Action()
+		}
+	}
+
+	var changed manifest.List
+	for _, m := range state {
+		switch actions[util.DiffName(m)] {
+		case PlanUnchanged, PlanPrune:
+			continue
+		}
+		changed = append(changed, m)
+	}
+	return changed
+}
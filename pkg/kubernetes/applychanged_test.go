@@ -0,0 +1,43 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestApplyChanged(t *testing.T) {
+	c := newFakeClient(
+		configMap("changed", "default", map[string]interface{}{"foo": "old"}),
+		configMap("unchanged", "default", map[string]interface{}{"foo": "bar"}),
+	)
+
+	skipped := configMap("skipped", "default", map[string]interface{}{"foo": "bar"})
+	skipped.Metadata()["annotations"] = map[string]interface{}{AnnotationDiffStrategy: ObjectStrategyNone}
+
+	state := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "new"}),
+		configMap("unchanged", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("created", "default", map[string]interface{}{"foo": "bar"}),
+		skipped,
+	}
+
+	applied, err := ApplyChanged(c, state, ApplyChangedOpts{})
+	require.NoError(t, err)
+
+	want := manifest.List{state[0], state[2], state[3]}
+	assert.Equal(t, want, applied)
+	assert.Equal(t, want, c.applied)
+	assert.Equal(t, []string{"apply 3"}, c.CallsWith("apply "))
+
+	t.Run("nothing changed", func(t *testing.T) {
+		c := newFakeClient(configMap("unchanged", "default", map[string]interface{}{"foo": "bar"}))
+		applied, err := ApplyChanged(c, state[1:2], ApplyChangedOpts{})
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+		assert.Empty(t, c.CallsWith("apply "))
+	})
+}
@@ -0,0 +1,39 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/kubernetes/util"
+)
+
+// skipUnmodified splits off the objects of state whose live resourceVersion
+// equals the one of opts.Baseline. Their entries are returned instead, marked
+// as Unmodified. Only a single request is made to obtain the resourceVersions.
+func skipUnmodified(c client.Client, state manifest.List, opts SubsetDiffOpts) (manifest.List, []DiffEntry, error) {
+	versions, err := resourceVersions(c, state, opts.Selector)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "fetching resourceVersions")
+	}
+
+	remaining := make(manifest.List, 0, len(state))
+	var skipped []DiffEntry
+	for _, m := range state {
+		name := util.DiffName(m)
+		version, ok := versions[objectKey(m)]
+		if !ok || version == "" || opts.Baseline[name] != version {
+			remaining = append(remaining, m)
+			continue
+		}
+
+		skipped = append(skipped, DiffEntry{
+			Name:            name,
+			Ref:             RefOf(m),
+			Labels:          labelsOf(m),
+			ResourceVersion: version,
+			Unmodified:      true,
+		})
+	}
+	return remaining, skipped, nil
+}
@@ -0,0 +1,66 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestDiffStateBaseline(t *testing.T) {
+	withVersion := func(name, version string, data map[string]interface{}) manifest.Manifest {
+		m := configMap(name, "default", data)
+		m.Metadata()["resourceVersion"] = version
+		return m
+	}
+
+	state := manifest.List{
+		configMap("same", "default", map[string]interface{}{"foo": "new"}),
+		configMap("changed", "default", map[string]interface{}{"foo": "new"}),
+		configMap("created", "default", map[string]interface{}{"foo": "new"}),
+	}
+	baseline := map[string]string{
+		"v1.ConfigMap.default.same":    "1",
+		"v1.ConfigMap.default.changed": "1",
+	}
+
+	c := newFakeClient(
+		// drift, which is not reported, as the object was not modified
+		withVersion("same", "1", map[string]interface{}{"foo": "old"}),
+		withVersion("changed", "2", map[string]interface{}{"foo": "old"}),
+	)
+
+	result, err := diffState(c, state, SubsetDiffOpts{Baseline: baseline})
+	require.NoError(t, err)
+
+	actions := make(map[string]PlanAction)
+	for _, e := range result.Entries {
+		actions[e.Name] = e.Action()
+	}
+	assert.Equal(t, map[string]PlanAction{
+		"v1.ConfigMap.default.same":    PlanUnchanged,
+		"v1.ConfigMap.default.changed": PlanUpdate,
+		"v1.ConfigMap.default.created": PlanCreate,
+	}, actions)
+
+	// the unmodified object is not fetched
+	assert.NotContains(t, c.CallsWith("get "), "get default ConfigMap same")
+	assert.Contains(t, c.CallsWith("get "), "get default ConfigMap changed")
+
+	// the result serves as the next baseline
+	assert.Equal(t, map[string]string{
+		"v1.ConfigMap.default.same":    "1",
+		"v1.ConfigMap.default.changed": "2",
+	}, result.ResourceVersions())
+
+	t.Run("all unmodified", func(t *testing.T) {
+		c := newFakeClient(withVersion("same", "1", nil))
+		result, err := diffState(c, state[:1], SubsetDiffOpts{Baseline: baseline})
+		require.NoError(t, err)
+		require.Len(t, result.Entries, 1)
+		assert.True(t, result.Entries[0].Unmodified)
+		assert.Equal(t, 0, c.Gets())
+	})
+}
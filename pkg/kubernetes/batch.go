@@ -0,0 +1,34 @@
+package kubernetes
+
+import (
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// matchLive maps the objects of a batched response (see
+// client.Client.GetByState) back to the objects of state they were requested
+// for. The result holds the live counterpart of each object in the order of
+// state, or nil if the cluster returned none.
+//
+// kubectl omits the namespace of cluster-wide objects, so objects lacking one
+// also match local objects that have it set.
+func matchLive(state, live manifest.List) []manifest.Manifest {
+	type key struct{ kind, namespace, name string }
+
+	byKey := make(map[key]manifest.Manifest, len(live))
+	for _, m := range live {
+		byKey[key{m.Kind(), m.Metadata().Namespace(), m.Metadata().Name()}] = m
+	}
+
+	matched := make([]manifest.Manifest, len(state))
+	for i, m := range state {
+		k := key{m.Kind(), m.Metadata().Namespace(), m.Metadata().Name()}
+		if l, ok := byKey[k]; ok {
+			matched[i] = l
+			continue
+		}
+
+		k.namespace = ""
+		matched[i] = byKey[k]
+	}
+	return matched
+}
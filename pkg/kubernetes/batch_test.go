@@ -0,0 +1,52 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestMatchLive(t *testing.T) {
+	role := clusterRole()
+	role.Metadata()["namespace"] = "default"
+
+	state := manifest.List{
+		configMap("a", "default", nil),
+		configMap("a", "other", nil),
+		configMap("missing", "default", nil),
+		role,
+	}
+
+	// as returned by kubectl, in a different order and without the namespace
+	// of cluster-wide objects
+	liveRole := clusterRole()
+	delete(liveRole.Metadata(), "namespace")
+	live := manifest.List{
+		liveRole,
+		configMap("a", "other", map[string]interface{}{"from": "other"}),
+		configMap("a", "default", map[string]interface{}{"from": "default"}),
+	}
+
+	got := matchLive(state, live)
+	require.Len(t, got, len(state))
+	assert.Equal(t, live[2], got[0])
+	assert.Equal(t, live[1], got[1])
+	assert.Nil(t, got[2])
+	assert.Equal(t, liveRole, got[3])
+}
+
+func TestResourceVersionsBatched(t *testing.T) {
+	state := manifest.List{configMap("a", "default", nil), configMap("b", "default", nil)}
+
+	live := configMap("a", "default", nil)
+	live.Metadata()["resourceVersion"] = "42"
+	c := newFakeClient(live)
+
+	got, err := resourceVersions(c, state, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{objectKey(state[0]): "42"}, got)
+
+}
@@ -0,0 +1,307 @@
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// DiffCache stores the results of previous diffs. Keys are derived from the
+// rendered object and the resourceVersion of its live counterpart, so an entry
+// automatically becomes stale once either side changes.
+type DiffCache interface {
+	Get(key string) ([]DiffEntry, bool)
+	Put(key string, entries []DiffEntry) error
+}
+
+// FileDiffCache is a DiffCache persisting entries as files in Dir
+type FileDiffCache struct {
+	Dir string
+}
+
+// Get returns the entries stored for key, if any
+func (f FileDiffCache) Get(key string) ([]DiffEntry, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(f.Dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+
+	var entries []DiffEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, false
+	}
+	return entries, true
+}
+
+// Put stores the entries for key
+func (f FileDiffCache) Put(key string, entries []DiffEntry) error {
+	// entries hold rendered diffs, which may include Secrets
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(f.Dir, key+".json"), data, 0600)
+}
+
+// cachedDiffState is like diffState, but serves objects from opts.Cache if
+// possible. Only a single request is made to obtain the resourceVersions, the
+// objects themselves are only fetched on cache misses. Objects whose
+// resourceVersion is unknown, as they do not exist or do not match
+// opts.Selector, are never served from the cache.
+func cachedDiffState(c client.Client, state manifest.List, opts SubsetDiffOpts) (*DiffResult, error) {
+	versions, live, err := liveVersions(c, state, opts.Selector)
+	if _, ok := errors.Cause(err).(client.ErrorConnection); ok && opts.TolerateUnreachable {
+		// all objects miss, fetchLive reports them as unknown drift
+		versions, live, err = map[string]string{}, nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching resourceVersions")
+	}
+	if opts.WithRollout {
+		if err := addRolloutVersions(c, live, versions); err != nil {
+			return nil, errors.Wrap(err, "fetching resourceVersions")
+		}
+	}
+
+	keys := make([]string, len(state))
+	cached := make([][]DiffEntry, len(state))
+	var misses manifest.List
+	var missIdx []int
+	for i, m := range state {
+		version, ok := versions[objectKey(m)]
+		if ok {
+			if keys[i], err = cacheKey(c.Info().Kubeconfig, m, version, opts); err != nil {
+				return nil, err
+			}
+			if entries, ok := opts.Cache.Get(keys[i]); ok {
+				cached[i] = entries
+				continue
+			}
+		}
+		misses = append(misses, m)
+		missIdx = append(missIdx, i)
+	}
+
+	perObject, err := fetchLive(c, misses, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "calculating subset")
+	}
+	if opts.Settle > 0 {
+		if perObject, err = settle(c, misses, perObject, opts); err != nil {
+			return nil, errors.Wrap(err, "settling")
+		}
+	}
+	var errs ErrorDiffs
+	for j, cs := range perObject {
+		r, err := diffComparisons(cs, opts)
+		partial, _ := err.(ErrorDiffs)
+		if err != nil && partial == nil {
+			return nil, err
+		}
+
+		i := missIdx[j]
+		cached[i] = r.Entries
+		if keys[i] == "" {
+			continue
+		}
+		if partial != nil || len(r.UnknownDrift()) > 0 {
+			// to be fetched again next time
+			errs = append(errs, partial...)
+			continue
+		}
+		if err := opts.Cache.Put(keys[i], r.Entries); err != nil {
+			return nil, errors.Wrap(err, "writing diff cache")
+		}
+	}
+
+	result := DiffResult{}
+	for _, entries := range cached {
+		result.Entries = append(result.Entries, entries...)
+	}
+	if len(errs) > 0 {
+		return &result, errs
+	}
+	return &result, nil
+}
+
+// cacheInputs are the options influencing the cached entries. Functions and
+// interfaces cannot be compared across processes, so only the type of
+// Sanitizer and Serializer and whether Encoder.Less is set are part of the
+// key. A different Cache must be used when changing their behavior.
+// Options added to SubsetDiffOpts must be added here, unless they do not
+// affect the cached entries (see TestCacheInputsComplete).
+type cacheInputs struct {
+	MaxDepth         int
+	Ignore           []IgnoreRule
+	NoDefaultIgnores bool
+	KeepClusterKeys  []string
+	Allow            []AllowRule
+
+	Encoder    string
+	Less       bool
+	Serializer string
+	Sanitizer  string
+
+	AnnotateOwned   bool
+	AnnotateManaged bool
+	SpecOnly        bool
+	WithRollout     bool
+	RecordPruned    bool
+	JSONPatch       bool
+	ListTypes       ListTypes
+	DetectListTypes bool
+	AnnotatePaths   bool
+
+	KeepEmpty                bool
+	EquateEmpty              bool
+	EquateEmptyPaths         []string
+	KeepLineEndings          bool
+	TrimTrailingSpace        bool
+	NormalizeJSONAnnotations bool
+
+	AnnotateRestarts   bool
+	ShowStatusReplicas bool
+	RevisionAnnotation string
+	Created            CreatedRendering
+	KubectlFormat      bool
+	MaskSecrets        bool
+	ReportIgnored      bool
+	Project            bool
+	SubsetData         bool
+	KindStrategies     map[string]string
+	AwaitCRDs          bool
+	Selector           map[string]string
+	Exact              bool
+}
+
+// cacheKey computes the key of m, given the resourceVersion of its live
+// counterpart in the cluster of the given kubeconfig. The options are part of
+// the key, as they influence the result. So is the cluster, as
+// resourceVersions are only unique within a single one.
+func cacheKey(cluster client.Config, m manifest.Manifest, resourceVersion string, opts SubsetDiffOpts) (string, error) {
+	rendered, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	identity, err := json.Marshal(cluster)
+	if err != nil {
+		return "", err
+	}
+
+	enc := opts.Encoder
+	enc.Less = nil
+	inputs, err := json.Marshal(cacheInputs{
+		MaxDepth:         opts.MaxDepth,
+		Ignore:           opts.Ignore,
+		NoDefaultIgnores: opts.NoDefaultIgnores,
+		KeepClusterKeys:  opts.KeepClusterKeys,
+		Allow:            opts.Allow,
+
+		Encoder:    fmt.Sprintf("%#v", enc),
+		Less:       opts.Encoder.Less != nil,
+		Serializer: fmt.Sprintf("%T", opts.Serializer),
+		Sanitizer:  fmt.Sprintf("%T", opts.Sanitizer),
+
+		AnnotateOwned:   opts.AnnotateOwned,
+		AnnotateManaged: opts.AnnotateManaged,
+		SpecOnly:        opts.SpecOnly,
+		WithRollout:     opts.WithRollout,
+		RecordPruned:    opts.RecordPruned,
+		JSONPatch:       opts.JSONPatch,
+		ListTypes:       opts.ListTypes,
+		DetectListTypes: opts.DetectListTypes,
+		AnnotatePaths:   opts.AnnotatePaths,
+
+		KeepEmpty:                opts.KeepEmpty,
+		EquateEmpty:              opts.EquateEmpty,
+		EquateEmptyPaths:         opts.EquateEmptyPaths,
+		KeepLineEndings:          opts.KeepLineEndings,
+		TrimTrailingSpace:        opts.TrimTrailingSpace,
+		NormalizeJSONAnnotations: opts.NormalizeJSONAnnotations,
+
+		AnnotateRestarts:   opts.AnnotateRestarts,
+		ShowStatusReplicas: opts.ShowStatusReplicas,
+		RevisionAnnotation: opts.RevisionAnnotation,
+		Created:            opts.Created,
+		KubectlFormat:      opts.KubectlFormat,
+		MaskSecrets:        opts.MaskSecrets,
+		ReportIgnored:      opts.ReportIgnored,
+		Project:            opts.Project,
+		SubsetData:         opts.SubsetData,
+		KindStrategies:     opts.KindStrategies,
+		AwaitCRDs:          opts.AwaitCRDs,
+		Selector:           opts.Selector,
+		Exact:              opts.exact,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s", identity, rendered, resourceVersion, inputs)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// addRolloutVersions appends the resourceVersion of the active ReplicaSet to
+// the one of each live Deployment, as its pod template is compared as well if
+// SubsetDiffOpts.WithRollout is set
+func addRolloutVersions(c client.Client, live map[string]manifest.Manifest, versions map[string]string) error {
+	for key, m := range live {
+		if m.Kind() != "Deployment" {
+			continue
+		}
+
+		rs, err := activeReplicaSet(c, m)
+		if err != nil {
+			return errors.Wrapf(err, "finding active ReplicaSet of %s", m.KindName())
+		}
+		if rs != nil {
+			rv, _ := rs.Metadata()["resourceVersion"].(string)
+			versions[key] += "/" + rv
+		}
+	}
+	return nil
+}
+
+// resourceVersions fetches the resourceVersions of all live objects of state
+// using a single request, keyed by the objectKey of their local counterpart.
+// If selector is set, only objects matching it are considered.
+func resourceVersions(c client.Client, state manifest.List, selector map[string]string) (map[string]string, error) {
+	versions, _, err := liveVersions(c, state, selector)
+	return versions, err
+}
+
+// liveVersions is like resourceVersions, but additionally returns the live
+// objects, keyed alike
+func liveVersions(c client.Client, state manifest.List, selector map[string]string) (map[string]string, map[string]manifest.Manifest, error) {
+	list, err := c.GetByState(state, client.GetByStateOpts{IgnoreNotFound: true, Labels: selector})
+	if _, ok := err.(client.ErrorNothingReturned); ok {
+		return map[string]string{}, map[string]manifest.Manifest{}, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	versions := make(map[string]string, len(list))
+	live := make(map[string]manifest.Manifest, len(list))
+	for i, m := range matchLive(state, list) {
+		if m != nil {
+			key := objectKey(state[i])
+			versions[key], _ = m.Metadata()["resourceVersion"].(string)
+			live[key] = m
+		}
+	}
+	return versions, live, nil
+}
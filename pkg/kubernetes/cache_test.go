@@ -0,0 +1,222 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSubsetDifferCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diffcache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	live := configMap("foo", "default", map[string]interface{}{"key": "old"})
+	live.Metadata()["resourceVersion"] = "1"
+	c := newFakeClient(live)
+
+	opts := SubsetDiffOpts{Cache: FileDiffCache{Dir: dir}}
+	state := manifest.List{configMap("foo", "default", map[string]interface{}{"key": "new"})}
+
+	gets := func() int {
+		n := 0
+		for _, call := range c.Calls() {
+			if strings.HasPrefix(call, "get ") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// miss
+	first, err := SubsetDiffer(c, opts)(state)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.Equal(t, 1, gets())
+
+	// hit: same render, same resourceVersion
+	second, err := SubsetDiffer(c, opts)(state)
+	require.NoError(t, err)
+	assert.Equal(t, 1, gets())
+	assert.Equal(t, *first, *second)
+
+	// changed render busts the cache
+	changed := manifest.List{configMap("foo", "default", map[string]interface{}{"key": "newer"})}
+	third, err := SubsetDiffer(c, opts)(changed)
+	require.NoError(t, err)
+	assert.Equal(t, 2, gets())
+	assert.Contains(t, *third, "+  key: newer")
+
+	// changed resourceVersion busts the cache
+	c.objects[0].Metadata()["resourceVersion"] = "2"
+	_, err = SubsetDiffer(c, opts)(state)
+	require.NoError(t, err)
+	assert.Equal(t, 3, gets())
+}
+
+func TestCacheKeyStable(t *testing.T) {
+	m := configMap("foo", "default", map[string]interface{}{"key": "new"})
+
+	// functions differ by pointer on every run, they must not be part of the
+	// key
+	opts := func() SubsetDiffOpts {
+		return SubsetDiffOpts{
+			Sanitizer: SanitizerFunc(func(m manifest.Manifest) (manifest.Manifest, error) { return m, nil }),
+			Encoder:   manifest.Encoder{Less: func(a, b string) bool { return a < b }},
+		}
+	}
+	a, err := cacheKey(client.Config{}, m, "1", opts())
+	require.NoError(t, err)
+	b, err := cacheKey(client.Config{}, m, "1", opts())
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	masked := opts()
+	masked.MaskSecrets = true
+	c, err := cacheKey(client.Config{}, m, "1", masked)
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c)
+
+	// no input
+	post := opts()
+	post.Metrics, post.DumpDir = &DiffMetrics{}, "dump"
+	d, err := cacheKey(client.Config{}, m, "1", post)
+	require.NoError(t, err)
+	assert.Equal(t, a, d)
+}
+
+// cacheExcluded are the fields of SubsetDiffOpts that are not part of
+// cacheInputs, as they do not affect the cached entries
+var cacheExcluded = map[string]string{
+	"Budget":              "rendering",
+	"PostProcessors":      "applied to the whole result",
+	"Metrics":             "output",
+	"Cache":               "the cache itself",
+	"Settle":              "changes what is fetched, not how it is compared",
+	"Rereads":             "unstable objects are not cached",
+	"PruneSelector":       "pruned objects are not cached",
+	"PruneAllowlist":      "pruned objects are not cached",
+	"Kinds":               "filters the state before",
+	"GroupByLabel":        "rendering",
+	"Compact":             "rendering",
+	"GitHubAnnotations":   "rendering",
+	"DumpDir":             "output",
+	"Preflight":           "runs before",
+	"TolerateUnreachable": "unknown drift is not cached",
+	"ContinueOnError":     "failed objects are not cached",
+	"Baseline":            "unmodified objects are not compared",
+}
+
+// TestCacheInputsComplete asserts every option is either part of the cache
+// key or explicitly excluded, so new options cannot silently serve stale
+// diffs
+func TestCacheInputsComplete(t *testing.T) {
+	inputs := reflect.TypeOf(cacheInputs{})
+	opts := reflect.TypeOf(SubsetDiffOpts{})
+	for i := 0; i < opts.NumField(); i++ {
+		name := opts.Field(i).Name
+		_, included := inputs.FieldByName(strings.ToUpper(name[:1]) + name[1:])
+		_, excluded := cacheExcluded[name]
+		assert.True(t, included != excluded, "SubsetDiffOpts.%s must be either part of cacheInputs or cacheExcluded", name)
+	}
+}
+
+func TestCacheKeyCluster(t *testing.T) {
+	m := configMap("foo", "default", map[string]interface{}{"key": "new"})
+	var a, b client.Config
+	a.Cluster.Cluster.Server = "https://a.example.com"
+	b.Cluster.Cluster.Server = "https://b.example.com"
+
+	ka, err := cacheKey(a, m, "1", SubsetDiffOpts{})
+	require.NoError(t, err)
+	kb, err := cacheKey(b, m, "1", SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.NotEqual(t, ka, kb)
+
+	// the same object and resourceVersion on another cluster is not served
+	// from the cache
+	live := configMap("foo", "default", map[string]interface{}{"key": "old"})
+	live.Metadata()["resourceVersion"] = "1"
+	opts := SubsetDiffOpts{Cache: FileDiffCache{Dir: t.TempDir()}}
+
+	ca := newFakeClient(live)
+	ca.info.Kubeconfig = a
+	_, err = SubsetDiffer(ca, opts)(manifest.List{m})
+	require.NoError(t, err)
+
+	same := configMap("foo", "default", map[string]interface{}{"key": "new"})
+	same.Metadata()["resourceVersion"] = "1"
+	cb := newFakeClient(same)
+	cb.info.Kubeconfig = b
+	diff, err := SubsetDiffer(cb, opts)(manifest.List{m})
+	require.NoError(t, err)
+	assert.Nil(t, diff)
+	assert.Equal(t, 1, cb.Gets())
+}
+
+func TestSubsetDifferCacheUnreachable(t *testing.T) {
+	c := newFakeClient()
+	c.getByStateErr = client.ErrorConnection{}
+	c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		return nil, client.ErrorConnection{}
+	}
+	state := manifest.List{configMap("foo", "default", nil)}
+
+	opts := SubsetDiffOpts{Cache: FileDiffCache{Dir: t.TempDir()}, TolerateUnreachable: true}
+	result, err := diffState(c, state, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.ConfigMap.default.foo"}, result.UnknownDrift())
+
+	// fail by default
+	opts.TolerateUnreachable = false
+	_, err = diffState(c, state, opts)
+	assert.Error(t, err)
+}
+
+func TestSubsetDifferCacheRollout(t *testing.T) {
+	local := deploymentWithImage("grafana/grafana:7.3.0")
+	live := deploymentWithImage("grafana/grafana:7.3.0")
+	live.Metadata()["uid"] = "d-uid"
+	live.Metadata()["resourceVersion"] = "1"
+	live.Metadata()["annotations"] = map[string]interface{}{AnnotationRevision: "2"}
+	active := replicaSet("grafana-new", "grafana/grafana:7.1.0", "2", "d-uid")
+	active.Metadata()["resourceVersion"] = "1"
+
+	c := newFakeClient(live, active)
+	opts := SubsetDiffOpts{WithRollout: true, Cache: FileDiffCache{Dir: t.TempDir()}}
+
+	diff, err := SubsetDiffer(c, opts)(manifest.List{local})
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Contains(t, *diff, "-      - image: grafana/grafana:7.1.0")
+
+	// the rollout finished, only the ReplicaSet changed
+	c.objects[1] = replicaSet("grafana-new", "grafana/grafana:7.3.0", "2", "d-uid")
+	c.objects[1].Metadata()["resourceVersion"] = "2"
+	diff, err = SubsetDiffer(c, opts)(manifest.List{local})
+	require.NoError(t, err)
+	assert.Nil(t, diff)
+}
+
+func TestFileDiffCachePermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache := FileDiffCache{Dir: dir}
+	require.NoError(t, cache.Put("key", []DiffEntry{{Name: "v1.Secret.default.creds"}}))
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Join(dir, "key.json"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
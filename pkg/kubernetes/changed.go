@@ -0,0 +1,58 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// ChangedOpts allow to tune ChangedObjects
+type ChangedOpts struct {
+	SubsetDiffOpts
+
+	// Created additionally reports objects that do not exist in the cluster yet
+	Created bool
+}
+
+// ChangedObjects is a quiet variant of the SubsetDiffer, that only reports
+// which objects of state differ from the cluster. As no diff is rendered, it
+// is considerably cheaper. Objects of unknown drift (see DiffEntry.Unknown)
+// are reported, as they may have changed.
+func ChangedObjects(c client.Client, state manifest.List, opts ChangedOpts) ([]ObjectRef, error) {
+	state, err := skipNone(filterKinds(state, opts.Kinds), opts.KindStrategies)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateNames(state); err != nil {
+		return nil, err
+	}
+
+	comparisons, err := liveComparisons(c, state, opts.SubsetDiffOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []ObjectRef
+	s := opts.subsetter()
+	for _, cmp := range comparisons {
+		if cmp.unknown() != "" {
+			refs = append(refs, RefOf(cmp.local))
+			continue
+		}
+
+		entry, err := s.compare(cmp.local, cmp.live)
+		if err != nil {
+			return nil, ErrorDiff{Ref: RefOf(cmp.local), Phase: DiffPhaseRender, Err: errors.Wrap(err, "calculating subset")}
+		}
+
+		switch {
+		case entry.Live == entry.Merged:
+			continue
+		case cmp.live == nil && !opts.Created:
+			continue
+		}
+		refs = append(refs, RefOf(cmp.local))
+	}
+	return refs, nil
+}
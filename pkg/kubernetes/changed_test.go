@@ -0,0 +1,42 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestChangedObjects(t *testing.T) {
+	c := newFakeClient(
+		configMap("changed", "default", map[string]interface{}{"foo": "old"}),
+		configMap("unchanged", "default", map[string]interface{}{"foo": "bar"}),
+	)
+	state := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "new"}),
+		configMap("unchanged", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("created", "default", map[string]interface{}{"foo": "bar"}),
+	}
+
+	// fail if a diff is rendered
+	defer func(orig func(string, string, string) (string, error)) { diffStr = orig }(diffStr)
+	diffStr = func(name, is, should string) (string, error) {
+		t.Fatalf("diffStr called for %s", name)
+		return "", nil
+	}
+
+	refs, err := ChangedObjects(c, state, ChangedOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []ObjectRef{
+		{Version: "v1", Kind: "ConfigMap", Namespace: "default", Name: "changed"},
+	}, refs)
+
+	refs, err = ChangedObjects(c, state, ChangedOpts{Created: true})
+	require.NoError(t, err)
+	assert.Equal(t, []ObjectRef{
+		{Version: "v1", Kind: "ConfigMap", Namespace: "default", Name: "changed"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "default", Name: "created"},
+	}, refs)
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// CanI returns whether the current identity is allowed to perform verb on
+// objects of kind in namespace, using `kubectl auth can-i`. An empty
+// namespace checks cluster-wide access.
+func (k Kubectl) CanI(verb, kind, namespace string) (bool, error) {
+	args := []string{"can-i", verb, kind}
+	if namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	cmd := k.ctl("auth", args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// exits non-zero if denied, so the output is checked first
+	err := cmd.Run()
+	switch strings.TrimSpace(stdout.String()) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+
+	if err == nil {
+		err = fmt.Errorf("unexpected output: %s", stdout.String())
+	}
+	return false, fmt.Errorf("checking permission to %s %s: %s", verb, kind, strings.TrimPrefix(fmt.Sprintf("%s\n%s", strings.TrimSpace(stderr.String()), err), "\n"))
+}
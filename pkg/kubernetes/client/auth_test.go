@@ -0,0 +1,55 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	args := filepath.Join(dir, "args")
+	script := `#!/bin/sh
+echo "$@" > ` + args + `
+case "$*" in
+  *Secret*) echo no; exit 1 ;;
+  *Broken*) echo "error: the server doesn't have a resource type" >&2; exit 1 ;;
+  *) echo yes ;;
+esac
+`
+	bin := filepath.Join(dir, "kubectl")
+	require.NoError(t, ioutil.WriteFile(bin, []byte(script), 0755))
+	os.Setenv("TANKA_KUBECTL_PATH", bin)
+	defer os.Unsetenv("TANKA_KUBECTL_PATH")
+
+	k := Kubectl{}
+
+	ok, err := k.CanI("get", "ConfigMap", "default")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	got, err := ioutil.ReadFile(args)
+	require.NoError(t, err)
+	assert.Equal(t, "auth --context  can-i get ConfigMap -n default\n", string(got))
+
+	ok, err = k.CanI("get", "Secret", "default")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// cluster-wide
+	ok, err = k.CanI("get", "ClusterRole", "")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	got, err = ioutil.ReadFile(args)
+	require.NoError(t, err)
+	assert.Equal(t, "auth --context  can-i get ClusterRole\n", string(got))
+
+	_, err = k.CanI("get", "Broken", "default")
+	assert.EqualError(t, err, "checking permission to get Broken: error: the server doesn't have a resource type\nexit status 1")
+}
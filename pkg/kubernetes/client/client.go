@@ -8,6 +8,9 @@ import (
 type Client interface {
 	// Get the specified object(s) from the cluster
 	Get(namespace, kind, name string) (manifest.Manifest, error)
+	// GetFields is like Get, but only returns the given top-level fields
+	// besides the identity of the object
+	GetFields(namespace, kind, name string, fields []string) (manifest.Manifest, error)
 	GetByLabels(namespace, kind string, labels map[string]string) (manifest.List, error)
 	GetByState(data manifest.List, opts GetByStateOpts) (manifest.List, error)
 
@@ -17,7 +20,7 @@ type Client interface {
 
 	// DiffServerSide runs the diff operation on the server and returns the
 	// result in `diff(1)` format
-	DiffServerSide(data manifest.List) (*string, error)
+	DiffServerSide(data manifest.List, opts DiffOpts) (*string, error)
 
 	// Delete the specified object(s) from the cluster
 	Delete(namespace, kind, name string, opts DeleteOpts) error
@@ -31,6 +34,13 @@ type Client interface {
 	// Resources returns all known api-resources of the cluster
 	Resources() (Resources, error)
 
+	// OpenAPISchema returns the OpenAPI v2 document of the cluster
+	OpenAPISchema() ([]byte, error)
+
+	// CanI returns whether the current identity may perform verb on objects
+	// of kind in namespace. An empty namespace checks cluster-wide access
+	CanI(verb, kind, namespace string) (bool, error)
+
 	// Info returns known informational data about the client. Best effort based,
 	// fields of `Info` that cannot be stocked with valuable data, e.g.
 	// due to an error, shall be left nil.
@@ -52,6 +62,13 @@ type ApplyOpts struct {
 	AutoApprove bool
 }
 
+// DiffOpts allow to specify additional parameters for diff operations
+type DiffOpts struct {
+	// ServerSide computes the differences using server-side apply instead of
+	// the last-applied-configuration annotation. Requires Kubernetes 1.18+
+	ServerSide bool
+}
+
 // DeleteOpts allow to specify additional parameters for delete operations
 // Currently not different from ApplyOpts, but may be required in the future
 type DeleteOpts ApplyOpts
@@ -63,4 +80,7 @@ type DeleteOpts ApplyOpts
 type GetByStateOpts struct {
 	// ignoreNotFound allows to ignore errors caused by missing objects
 	IgnoreNotFound bool
+
+	// Labels limits the result to objects carrying all of these labels
+	Labels map[string]string
 }
@@ -5,17 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"strings"
 
 	"github.com/stretchr/objx"
 	funk "github.com/thoas/go-funk"
+	"sigs.k8s.io/yaml"
 )
 
-// findContext returns a valid context from $KUBECONFIG that uses the given
-// apiServer endpoint.
-func findContext(endpoint string) (Config, error) {
-	cluster, context, err := ContextFromIP(endpoint)
+// findContext returns a valid context from $KUBECONFIG (or the kubeconfig
+// files at paths, if any) that uses the given apiServer endpoint.
+func findContext(endpoint string, paths []string) (Config, error) {
+	cluster, context, err := contextFromIP(endpoint, paths)
 	if err != nil {
 		return Config{}, err
 	}
@@ -28,6 +31,16 @@ func findContext(endpoint string) (Config, error) {
 
 // Kubeconfig returns the merged $KUBECONFIG of the host
 func Kubeconfig() (objx.Map, error) {
+	return kubeconfig(nil)
+}
+
+// kubeconfig returns the merged kubeconfig files at paths, or the one of the
+// host if paths is empty
+func kubeconfig(paths []string) (objx.Map, error) {
+	if len(paths) > 0 {
+		return mergeKubeconfigs(paths)
+	}
+
 	cmd := kubectlCmd("config", "view", "-o", "json")
 	cfgJSON := bytes.Buffer{}
 	cmd.Stdout = &cfgJSON
@@ -53,42 +66,129 @@ func Contexts() ([]string, error) {
 	return strings.Split(buf.String(), "\n"), nil
 }
 
-// ContextFromIP searches the $KUBECONFIG for a context using a cluster that matches the apiServer
+// ContextFromIP searches the $KUBECONFIG for a context using a cluster that matches the apiServer.
+// Servers are compared as URLs, so trailing slashes and default ports do not
+// matter. If several contexts match, the first one is used.
 func ContextFromIP(apiServer string) (*Cluster, *Context, error) {
-	cfg, err := Kubeconfig()
+	return contextFromIP(apiServer, nil)
+}
+
+// contextFromIP is like ContextFromIP, but searches the merged kubeconfig
+// files at paths instead, if any
+func contextFromIP(apiServer string, paths []string) (*Cluster, *Context, error) {
+	cfg, err := kubeconfig(paths)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// find the correct cluster
-	var cluster Cluster
+	// find the correct clusters
 	clusters, err := tryMSISlice(cfg.Get("clusters"), "clusters")
 	if err != nil {
 		return nil, nil, err
 	}
 
-	err = find(clusters, "cluster.server", apiServer, &cluster)
-	if err == ErrorNoMatch {
+	var matching []Cluster
+	for _, c := range clusters {
+		var cluster Cluster
+		if err := decode(c, &cluster); err != nil {
+			return nil, nil, err
+		}
+		if sameServer(cluster.Cluster.Server, apiServer) {
+			matching = append(matching, cluster)
+		}
+	}
+	if len(matching) == 0 {
 		return nil, nil, ErrorNoCluster(apiServer)
-	} else if err != nil {
-		return nil, nil, err
 	}
 
-	// find a context that uses the cluster
-	var context Context
+	// find a context that uses one of the clusters
 	contexts, err := tryMSISlice(cfg.Get("contexts"), "contexts")
 	if err != nil {
 		return nil, nil, err
 	}
 
-	err = find(contexts, "context.cluster", cluster.Name, &context)
-	if err == ErrorNoMatch {
-		return nil, nil, ErrorNoContext(cluster.Name)
-	} else if err != nil {
-		return nil, nil, err
+	names := make([]string, 0, len(matching))
+	for _, cluster := range matching {
+		var context Context
+		err := find(contexts, "context.cluster", cluster.Name, &context)
+		if err == ErrorNoMatch {
+			names = append(names, cluster.Name)
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		return &cluster, &context, nil
 	}
 
-	return &cluster, &context, nil
+	return nil, nil, ErrorNoContext(strings.Join(names, ", "))
+}
+
+// kubeconfigLists are the named lists of a kubeconfig
+var kubeconfigLists = []string{"clusters", "contexts", "users"}
+
+// mergeKubeconfigs merges the kubeconfig files at paths like kubectl merges
+// the ones of a colon-separated $KUBECONFIG: the first file setting the
+// current-context or defining a cluster, context or user of a name wins.
+func mergeKubeconfigs(paths []string) (objx.Map, error) {
+	merged := map[string]interface{}{}
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var cfg map[string]interface{}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing kubeconfig %s: %w", p, err)
+		}
+
+		if current, ok := cfg["current-context"].(string); ok && current != "" && merged["current-context"] == nil {
+			merged["current-context"] = current
+		}
+		for _, list := range kubeconfigLists {
+			items, _ := cfg[list].([]interface{})
+			for _, item := range items {
+				named, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("parsing kubeconfig %s: expected the items of %s to be objects", p, list)
+				}
+				key := fmt.Sprintf("%s/%v", list, named["name"])
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				all, _ := merged[list].([]map[string]interface{})
+				merged[list] = append(all, named)
+			}
+		}
+	}
+	return objx.New(merged), nil
+}
+
+// sameServer returns whether both URLs point to the same API server
+func sameServer(a, b string) bool {
+	return normalizeServer(a) == normalizeServer(b)
+}
+
+// normalizeServer returns the canonical form of the API server URL s. If s is
+// not a valid URL, it is returned as is.
+func normalizeServer(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	switch {
+	case u.Scheme == "https" && u.Port() == "443",
+		u.Scheme == "http" && u.Port() == "80":
+		u.Host = u.Hostname()
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String()
 }
 
 // IPFromContext parses $KUBECONFIG, finds the cluster with the given name and
@@ -172,6 +272,11 @@ func find(list []map[string]interface{}, prop string, expected string, ptr inter
 		return ErrorNoMatch
 	}
 
-	o := objx.New(i).MustJSON()
+	return decode(i.(map[string]interface{}), ptr)
+}
+
+// decode unmarshals the kubeconfig item x to ptr
+func decode(x map[string]interface{}, ptr interface{}) error {
+	o := objx.New(x).MustJSON()
 	return json.Unmarshal([]byte(o), ptr)
 }
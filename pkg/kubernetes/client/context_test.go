@@ -0,0 +1,159 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeconfig = `{
+  "current-context": "prod-admin",
+  "clusters": [
+    {"name": "dev", "cluster": {"server": "https://dev.example.com"}},
+    {"name": "prod", "cluster": {"server": "https://prod.example.com:443/"}},
+    {"name": "orphan", "cluster": {"server": "https://orphan.example.com"}}
+  ],
+  "contexts": [
+    {"name": "dev", "context": {"cluster": "dev", "user": "dev"}},
+    {"name": "prod-readonly", "context": {"cluster": "prod", "user": "readonly"}},
+    {"name": "prod-admin", "context": {"cluster": "prod", "user": "admin"}}
+  ]
+}`
+
+func TestContextFromIP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := filepath.Join(dir, "config.json")
+	require.NoError(t, ioutil.WriteFile(cfg, []byte(testKubeconfig), 0644))
+	bin := filepath.Join(dir, "kubectl")
+	require.NoError(t, ioutil.WriteFile(bin, []byte("#!/bin/sh\ncat "+cfg+"\n"), 0755))
+	os.Setenv("TANKA_KUBECTL_PATH", bin)
+	defer os.Unsetenv("TANKA_KUBECTL_PATH")
+
+	cases := []struct {
+		name      string
+		apiServer string
+		context   string
+		err       error
+	}{
+		{name: "match", apiServer: "https://dev.example.com", context: "dev"},
+		{name: "normalized", apiServer: "https://DEV.example.com:443/", context: "dev"},
+		// of both contexts, the first one is used, regardless of the current-context
+		{name: "first", apiServer: "https://prod.example.com", context: "prod-readonly"},
+		{name: "noCluster", apiServer: "https://staging.example.com", err: ErrorNoCluster("https://staging.example.com")},
+		{name: "otherPort", apiServer: "https://dev.example.com:6443", err: ErrorNoCluster("https://dev.example.com:6443")},
+		{name: "noContext", apiServer: "https://orphan.example.com", err: ErrorNoContext("orphan")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster, context, err := ContextFromIP(c.apiServer)
+			if c.err != nil {
+				assert.Equal(t, c.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.context, context.Name)
+			assert.Equal(t, context.Context.Cluster, cluster.Name)
+		})
+	}
+}
+
+func TestNormalizeServer(t *testing.T) {
+	assert.Equal(t, "https://example.com", normalizeServer("HTTPS://Example.com:443/"))
+	assert.Equal(t, "http://example.com", normalizeServer("http://example.com:80"))
+	assert.Equal(t, "https://example.com:6443/k8s", normalizeServer("https://example.com:6443/k8s/"))
+	assert.Equal(t, "127.0.0.1:6443", normalizeServer("127.0.0.1:6443"))
+}
+
+func TestContextFromKubeconfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dev := filepath.Join(dir, "dev.yaml")
+	require.NoError(t, ioutil.WriteFile(dev, []byte(`
+current-context: dev
+clusters:
+- name: dev
+  cluster: {server: https://dev.example.com}
+contexts:
+- name: dev
+  context: {cluster: dev, user: dev}
+`), 0644))
+
+	// defines dev again, which is ignored, as the first file wins
+	prod := filepath.Join(dir, "prod.yaml")
+	require.NoError(t, ioutil.WriteFile(prod, []byte(`
+current-context: prod
+clusters:
+- name: dev
+  cluster: {server: https://other.example.com}
+- name: prod
+  cluster: {server: https://prod.example.com}
+contexts:
+- name: prod
+  context: {cluster: prod, user: admin}
+`), 0644))
+
+	// never consulted
+	os.Setenv("TANKA_KUBECTL_PATH", "/nonexistent")
+	defer os.Unsetenv("TANKA_KUBECTL_PATH")
+
+	paths := []string{dev, prod}
+	cases := []struct {
+		apiServer string
+		context   string
+		err       error
+	}{
+		{apiServer: "https://dev.example.com", context: "dev"},
+		{apiServer: "https://prod.example.com", context: "prod"},
+		{apiServer: "https://other.example.com", err: ErrorNoCluster("https://other.example.com")},
+	}
+	for _, c := range cases {
+		t.Run(c.apiServer, func(t *testing.T) {
+			cluster, context, err := contextFromIP(c.apiServer, paths)
+			if c.err != nil {
+				assert.Equal(t, c.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.context, context.Name)
+			assert.Equal(t, c.apiServer, cluster.Cluster.Server)
+		})
+	}
+
+	cfg, err := mergeKubeconfigs(paths)
+	require.NoError(t, err)
+	assert.Equal(t, "dev", cfg.Get("current-context").Str())
+
+	_, err = mergeKubeconfigs([]string{dev, filepath.Join(dir, "missing.yaml")})
+	assert.Error(t, err)
+}
+
+func TestKubeconfigsEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// fake kubectl, recording the kubeconfig it was called with
+	env := filepath.Join(dir, "env")
+	bin := filepath.Join(dir, "kubectl")
+	require.NoError(t, ioutil.WriteFile(bin, []byte("#!/bin/sh\necho \"$KUBECONFIG\" > "+env+"\necho '{\"apiVersion\": \"v1\", \"kind\": \"ConfigMap\", \"metadata\": {\"name\": \"foo\"}}'\n"), 0755))
+	os.Setenv("TANKA_KUBECTL_PATH", bin)
+	defer os.Unsetenv("TANKA_KUBECTL_PATH")
+
+	k := Kubectl{opts: Opts{Kubeconfigs: []string{"/a/dev.yaml", "/b/prod.yaml"}}}
+	_, err = k.Get("default", "ConfigMap", "foo")
+	require.NoError(t, err)
+
+	got, err := ioutil.ReadFile(env)
+	require.NoError(t, err)
+	assert.Equal(t, "/a/dev.yaml:/b/prod.yaml\n", string(got))
+}
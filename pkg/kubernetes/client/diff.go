@@ -13,8 +13,12 @@ import (
 
 // DiffServerSide takes the desired state and computes the differences on the
 // server, returning them in `diff(1)` format
-func (k Kubectl) DiffServerSide(data manifest.List) (*string, error) {
-	cmd := k.ctl("diff", "-f", "-")
+func (k Kubectl) DiffServerSide(data manifest.List, opts DiffOpts) (*string, error) {
+	args := []string{"-f", "-"}
+	if opts.ServerSide {
+		args = append(args, "--server-side")
+	}
+	cmd := k.ctl("diff", args...)
 
 	raw := bytes.Buffer{}
 	cmd.Stdout = &raw
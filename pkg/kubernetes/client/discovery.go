@@ -0,0 +1,128 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultDiscoveryTTL is the TTL of persisted discovery results, if none is
+// given
+const DefaultDiscoveryTTL = 10 * time.Minute
+
+// CachedDiscovery wraps a Client, caching the results of API discovery
+// (Resources and OpenAPISchema), which are expensive to obtain. Within a run,
+// each is fetched at most once. Failures are not cached. It is safe for
+// concurrent use.
+type CachedDiscovery struct {
+	Client
+
+	// Dir additionally persists the results across runs, one set of files
+	// per API server. Disabled if empty
+	Dir string
+	// TTL is the age after which persisted results are fetched again.
+	// Defaults to DefaultDiscoveryTTL
+	TTL time.Duration
+
+	mu        sync.Mutex
+	resources Resources
+	schema    []byte
+}
+
+// NewCachedDiscovery returns a CachedDiscovery for c, persisting to dir if
+// set
+func NewCachedDiscovery(c Client, dir string, ttl time.Duration) *CachedDiscovery {
+	return &CachedDiscovery{Client: c, Dir: dir, TTL: ttl}
+}
+
+// Resources returns the cached api-resources, fetching them if required
+func (d *CachedDiscovery) Resources() (Resources, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.resources != nil {
+		return d.resources, nil
+	}
+
+	var res Resources
+	if data, ok := d.load("resources.json"); ok && json.Unmarshal(data, &res) == nil {
+		d.resources = res
+		return res, nil
+	}
+
+	res, err := d.Client.Resources()
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(res); err == nil {
+		d.store("resources.json", data)
+	}
+	d.resources = res
+	return res, nil
+}
+
+// OpenAPISchema returns the cached OpenAPI schema, fetching it if required
+func (d *CachedDiscovery) OpenAPISchema() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.schema != nil {
+		return d.schema, nil
+	}
+
+	if data, ok := d.load("openapi-v2.json"); ok {
+		d.schema = data
+		return data, nil
+	}
+
+	data, err := d.Client.OpenAPISchema()
+	if err != nil {
+		return nil, err
+	}
+	d.store("openapi-v2.json", data)
+	d.schema = data
+	return data, nil
+}
+
+// path returns where the given file is persisted. As discovery differs between
+// clusters, they get distinct directories.
+func (d *CachedDiscovery) path(name string) string {
+	h := sha256.Sum256([]byte(d.Info().Kubeconfig.Cluster.Cluster.Server))
+	return filepath.Join(d.Dir, hex.EncodeToString(h[:8]), name)
+}
+
+// load returns the persisted file, unless it is missing or expired
+func (d *CachedDiscovery) load(name string) ([]byte, bool) {
+	if d.Dir == "" {
+		return nil, false
+	}
+
+	ttl := d.TTL
+	if ttl == 0 {
+		ttl = DefaultDiscoveryTTL
+	}
+	file := d.path(name)
+	info, err := os.Stat(file)
+	if err != nil || time.Since(info.ModTime()) > ttl {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(file)
+	return data, err == nil
+}
+
+// store persists data as file. Being a cache, failures are ignored.
+func (d *CachedDiscovery) store(name string, data []byte) {
+	if d.Dir == "" {
+		return
+	}
+
+	file := d.path(name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(file, data, 0644)
+}
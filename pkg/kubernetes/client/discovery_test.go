@@ -0,0 +1,117 @@
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingClient counts the discovery requests made
+type countingClient struct {
+	Client
+
+	mu        sync.Mutex
+	resources int
+	schemas   int
+	fail      bool
+}
+
+func (c *countingClient) Resources() (Resources, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resources++
+	if c.fail {
+		return nil, errors.New("unreachable")
+	}
+	return Resources{{Kind: "ConfigMap", Name: "configmaps", Namespaced: true}}, nil
+}
+
+func (c *countingClient) OpenAPISchema() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schemas++
+	if c.fail {
+		return nil, errors.New("unreachable")
+	}
+	return []byte(`{"swagger":"2.0"}`), nil
+}
+
+func (c *countingClient) Info() Info {
+	var info Info
+	info.Kubeconfig.Cluster.Cluster.Server = "https://localhost:6443"
+	return info
+}
+
+func TestCachedDiscovery(t *testing.T) {
+	c := &countingClient{}
+	d := NewCachedDiscovery(c, "", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := d.Resources()
+			assert.NoError(t, err)
+			assert.Len(t, res, 1)
+			schema, err := d.OpenAPISchema()
+			assert.NoError(t, err)
+			assert.Equal(t, `{"swagger":"2.0"}`, string(schema))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, c.resources)
+	assert.Equal(t, 1, c.schemas)
+}
+
+func TestCachedDiscoveryFailure(t *testing.T) {
+	c := &countingClient{fail: true}
+	d := NewCachedDiscovery(c, "", 0)
+
+	_, err := d.Resources()
+	require.Error(t, err)
+
+	// failures are retried
+	c.fail = false
+	res, err := d.Resources()
+	require.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, 2, c.resources)
+}
+
+func TestCachedDiscoveryPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	first := &countingClient{}
+	_, err = NewCachedDiscovery(first, dir, time.Hour).Resources()
+	require.NoError(t, err)
+	_, err = NewCachedDiscovery(first, dir, time.Hour).OpenAPISchema()
+	require.NoError(t, err)
+
+	// a later run is served from disk
+	second := &countingClient{}
+	d := NewCachedDiscovery(second, dir, time.Hour)
+	res, err := d.Resources()
+	require.NoError(t, err)
+	assert.Equal(t, Resources{{Kind: "ConfigMap", Name: "configmaps", Namespaced: true}}, res)
+	schema, err := d.OpenAPISchema()
+	require.NoError(t, err)
+	assert.Equal(t, `{"swagger":"2.0"}`, string(schema))
+	assert.Equal(t, 0, second.resources)
+	assert.Equal(t, 0, second.schemas)
+
+	// expired
+	third := &countingClient{}
+	_, err = NewCachedDiscovery(third, dir, time.Nanosecond).Resources()
+	require.NoError(t, err)
+	assert.Equal(t, 1, third.resources)
+}
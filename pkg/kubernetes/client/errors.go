@@ -21,6 +21,17 @@ func (e ErrorUnknownResource) Error() string {
 	return e.errOut
 }
 
+// ErrorConnection means that the server could not be reached, e.g. because of
+// network issues. Other errors (like missing permissions) are reported by the
+// server itself and are never of this type
+type ErrorConnection struct {
+	errOut string
+}
+
+func (e ErrorConnection) Error() string {
+	return e.errOut
+}
+
 // ErrorNoContext means that the context that was searched for couldn't be found
 type ErrorNoContext string
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // kubectlCmd returns command a object that will launch kubectl at an appropriate path.
@@ -23,10 +24,28 @@ func (k Kubectl) ctl(action string, args ...string) *exec.Cmd {
 	argv := []string{action,
 		"--context", k.info.Kubeconfig.Context.Name,
 	}
+	if k.opts.RequestTimeout > 0 {
+		argv = append(argv, "--request-timeout", k.opts.RequestTimeout.String())
+	}
+	if k.opts.As != "" {
+		argv = append(argv, "--as", k.opts.As)
+	}
+	for _, g := range k.opts.AsGroups {
+		argv = append(argv, "--as-group", g)
+	}
 	argv = append(argv, args...)
 
 	// prepare the cmd
 	cmd := kubectlCmd(argv...)
+	if k.opts.ProxyURL != "" {
+		cmd.Env = append(os.Environ(), "HTTPS_PROXY="+k.opts.ProxyURL)
+	}
+	if len(k.opts.Kubeconfigs) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, "KUBECONFIG="+strings.Join(k.opts.Kubeconfigs, string(os.PathListSeparator)))
+	}
 
 	if os.Getenv("TANKA_KUBECTL_TRACE") != "" {
 		fmt.Println(cmd.String())
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/grafana/tanka/pkg/kubernetes/manifest"
@@ -15,6 +16,129 @@ func (k Kubectl) Get(namespace, kind, name string) (manifest.Manifest, error) {
 	return k.get(namespace, kind, []string{name}, getOpts{})
 }
 
+// GetFields retrieves a single Kubernetes object from the cluster, like Get,
+// but reduced to the given top-level fields (see Project). kubectl only
+// prints those fields, using a jsonpath template, so the others are neither
+// transferred to Tanka nor decoded. jsonpath prints objects and lists as
+// JSON, but strings as is, so scalars cannot be told apart from each other
+// and empty strings not from absent fields. If any of the fields is not an
+// object or list, the whole object is fetched instead. The same applies if
+// the output cannot be parsed (e.g. old kubectl versions print maps in Go
+// syntax).
+func (k Kubectl) GetFields(namespace, kind, name string, fields []string) (manifest.Manifest, error) {
+	keys := append([]string{}, identityFields...)
+	seen := map[string]bool{"apiVersion": true, "kind": true, "metadata": true}
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			keys = append(keys, f)
+		}
+	}
+	tmpl, ok := fieldsTemplate(keys)
+	if !ok {
+		return k.getProjected(namespace, kind, name, fields)
+	}
+
+	out, err := k.getOutput(namespace, kind, []string{name}, getOpts{output: "jsonpath=" + tmpl})
+	if err != nil {
+		return nil, err
+	}
+	m, ok := parseFields(out, keys)
+	if !ok {
+		return k.getProjected(namespace, kind, name, fields)
+	}
+	return Project(m, fields), nil
+}
+
+// getProjected fetches the whole object and projects it afterwards
+func (k Kubectl) getProjected(namespace, kind, name string, fields []string) (manifest.Manifest, error) {
+	m, err := k.Get(namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	return Project(m, fields), nil
+}
+
+// fieldName matches top-level fields that can be used in jsonpath templates
+// as is
+var fieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// fieldsTemplate returns a jsonpath template printing each of the top-level
+// fields on its own line, like `{.kind}{"\n"}{.spec}{"\n"}`
+func fieldsTemplate(fields []string) (string, bool) {
+	var b strings.Builder
+	for _, f := range fields {
+		if !fieldName.MatchString(f) {
+			return "", false
+		}
+		fmt.Fprintf(&b, `{.%s}{"\n"}`, f)
+	}
+	return b.String(), true
+}
+
+// parseFields parses the output of fieldsTemplate. apiVersion and kind
+// are strings, the other fields must be JSON objects or lists.
+func parseFields(out []byte, fields []string) (manifest.Manifest, bool) {
+	lines := strings.Split(string(out), "\n")
+	if len(lines) != len(fields)+1 || lines[len(fields)] != "" {
+		return nil, false
+	}
+
+	m := make(manifest.Manifest, len(fields))
+	for i, f := range fields {
+		line := lines[i]
+		if f == "apiVersion" || f == "kind" {
+			if line == "" {
+				return nil, false
+			}
+			m[f] = line
+			continue
+		}
+
+		if !strings.HasPrefix(line, "{") && !strings.HasPrefix(line, "[") {
+			return nil, false
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			return nil, false
+		}
+		m[f] = v
+	}
+
+	if _, ok := m["metadata"].(map[string]interface{}); !ok {
+		return nil, false
+	}
+	return m, true
+}
+
+// identityFields are always kept by Project
+var identityFields = []string{"apiVersion", "kind", "metadata"}
+
+// Project reduces m to its identity (apiVersion, kind and metadata) and the
+// given top-level fields. The bulky metadata.managedFields is dropped as well.
+// m is not modified.
+func Project(m manifest.Manifest, fields []string) manifest.Manifest {
+	out := make(manifest.Manifest, len(identityFields)+len(fields))
+	for _, f := range append(identityFields, fields...) {
+		if v, ok := m[f]; ok {
+			out[f] = v
+		}
+	}
+
+	if meta, ok := m["metadata"].(map[string]interface{}); ok {
+		if _, ok := meta["managedFields"]; ok {
+			projected := make(map[string]interface{}, len(meta)-1)
+			for k, v := range meta {
+				if k != "managedFields" {
+					projected[k] = v
+				}
+			}
+			out["metadata"] = projected
+		}
+	}
+	return out
+}
+
 // GetByLabels retrieves all objects matched by the given labels from the cluster.
 // Set namespace to empty string for --all-namespaces
 func (k Kubectl) GetByLabels(namespace, kind string, labels map[string]string) (manifest.List, error) {
@@ -46,19 +170,65 @@ func (k Kubectl) GetByState(data manifest.List, opts GetByStateOpts) (manifest.L
 		return nil, err
 	}
 
-	return unwrapList(list)
+	items, err := unwrapList(list)
+	if err != nil || len(opts.Labels) == 0 {
+		return items, err
+	}
+	return filterLabels(items, opts.Labels), nil
+}
+
+// filterLabels returns the objects of list carrying all of the given labels
+func filterLabels(list manifest.List, labels map[string]string) manifest.List {
+	out := make(manifest.List, 0, len(list))
+	for _, m := range list {
+		matches := true
+		for k, v := range labels {
+			if m.Metadata().Labels()[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			out = append(out, m)
+		}
+	}
+	return out
 }
 
 type getOpts struct {
 	allNamespaces  bool
 	ignoreNotFound bool
 	stdin          string
+
+	// output format passed to `-o`. Defaults to json
+	output string
 }
 
 func (k Kubectl) get(namespace, kind string, selector []string, opts getOpts) (manifest.Manifest, error) {
+	out, err := k.getOutput(namespace, kind, selector, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse result
+	var m manifest.Manifest
+	if err := json.Unmarshal(out, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// getOutput runs `kubectl get` and returns its output
+func (k Kubectl) getOutput(namespace, kind string, selector []string, opts getOpts) ([]byte, error) {
+	output := opts.output
+	if output == "" {
+		output = "json"
+	}
+
 	// build cli flags and args
 	argv := []string{
-		"-o", "json",
+		"-o", output,
 	}
 	if opts.ignoreNotFound {
 		argv = append(argv, "--ignore-not-found")
@@ -76,6 +246,10 @@ func (k Kubectl) get(namespace, kind string, selector []string, opts getOpts) (m
 
 	argv = append(argv, selector...)
 
+	if k.limiter != nil {
+		k.limiter.wait()
+	}
+
 	// setup command environment
 	cmd := k.ctl("get", argv...)
 	var sout, serr bytes.Buffer
@@ -96,13 +270,7 @@ func (k Kubectl) get(namespace, kind string, selector []string, opts getOpts) (m
 		return nil, ErrorNothingReturned{}
 	}
 
-	// parse result
-	var m manifest.Manifest
-	if err := json.Unmarshal(sout.Bytes(), &m); err != nil {
-		return nil, err
-	}
-
-	return m, nil
+	return sout.Bytes(), nil
 }
 
 func parseGetErr(err error, stderr string) error {
@@ -112,20 +280,46 @@ func parseGetErr(err error, stderr string) error {
 	if strings.HasPrefix(stderr, "error: the server doesn't have a resource type") {
 		return ErrorUnknownResource{stderr}
 	}
+	if connectionFailed(stderr) {
+		return ErrorConnection{stderr}
+	}
 
 	return errors.New(strings.TrimPrefix(fmt.Sprintf("%s\n%s", stderr, err), "\n"))
 }
 
-func unwrapList(list manifest.Manifest) (manifest.List, error) {
-	if list.Kind() != "List" {
-		return nil, fmt.Errorf("expected kind `List` but got `%s` instead", list.Kind())
-	}
+// connectionErrors are printed by kubectl if the server cannot be reached
+var connectionErrors = []string{
+	"Unable to connect to the server",
+	"The connection to the server",
+	"dial tcp",
+	"i/o timeout",
+}
 
-	items := list["items"].([]interface{})
-	ms := make(manifest.List, 0, len(items))
-	for _, i := range items {
-		ms = append(ms, manifest.Manifest(i.(map[string]interface{})))
+// connectionFailed returns whether stderr reports that the server could not
+// be reached. Errors returned by the server, like "Error from server
+// (Forbidden)", are not considered connection failures
+func connectionFailed(stderr string) bool {
+	if strings.HasPrefix(stderr, "Error from server") {
+		return false
 	}
+	for _, e := range connectionErrors {
+		if strings.Contains(stderr, e) {
+			return true
+		}
+	}
+	return false
+}
 
-	return ms, nil
+// unwrapList returns the objects of a response of kubectl get. Requests for
+// multiple objects are answered with a wrapping List (e.g.
+// `{"kind": "List", "items": [...]}`), while kubectl returns a single object
+// as is, even if it was requested using a batched request like `-f -`.
+func unwrapList(list manifest.Manifest) (manifest.List, error) {
+	if !list.IsList() {
+		if _, ok := list["kind"].(string); !ok {
+			return nil, fmt.Errorf("expected kind `List` or a single object, but the response has no kind")
+		}
+		return manifest.List{list}, nil
+	}
+	return list.Items()
 }
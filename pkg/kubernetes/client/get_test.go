@@ -0,0 +1,342 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestFilterLabels(t *testing.T) {
+	labeled := func(name string, labels map[string]interface{}) manifest.Manifest {
+		return manifest.Manifest{
+			"kind":     "ConfigMap",
+			"metadata": map[string]interface{}{"name": name, "labels": labels},
+		}
+	}
+
+	list := manifest.List{
+		labeled("a", map[string]interface{}{"env": "prod", "team": "x"}),
+		labeled("b", map[string]interface{}{"env": "dev"}),
+		labeled("c", nil),
+	}
+
+	got := filterLabels(list, map[string]string{"env": "prod"})
+	assert.Equal(t, manifest.List{list[0]}, got)
+}
+
+func TestProject(t *testing.T) {
+	m := manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":          "foo",
+			"managedFields": []interface{}{map[string]interface{}{"manager": "kubectl"}},
+		},
+		"data":   map[string]interface{}{"key": "value"},
+		"status": map[string]interface{}{"phase": "Active"},
+	}
+
+	got := Project(m, []string{"data", "spec"})
+	assert.Equal(t, manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "foo"},
+		"data":       map[string]interface{}{"key": "value"},
+	}, got)
+
+	// m is not modified
+	assert.Contains(t, m, "status")
+	assert.Contains(t, m.Metadata(), "managedFields")
+}
+
+// fakeKubectl installs a kubectl that records its arguments and returns a
+// ConfigMap. It returns the path of the file holding the recorded arguments.
+func fakeKubectl(t *testing.T) (args string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "kubectl")
+	require.NoError(t, err)
+
+	args = filepath.Join(dir, "args")
+	bin := filepath.Join(dir, "kubectl")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\necho '{\"apiVersion\": \"v1\", \"kind\": \"ConfigMap\", \"metadata\": {\"name\": \"foo\"}}'\n", args)
+	require.NoError(t, ioutil.WriteFile(bin, []byte(script), 0755))
+	os.Setenv("TANKA_KUBECTL_PATH", bin)
+
+	return args, func() {
+		os.Unsetenv("TANKA_KUBECTL_PATH")
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGetRequestTimeout(t *testing.T) {
+	args, cleanup := fakeKubectl(t)
+	defer cleanup()
+
+	k := Kubectl{opts: Opts{RequestTimeout: 5 * time.Second}}
+	_, err := k.Get("default", "ConfigMap", "foo")
+	require.NoError(t, err)
+
+	got, err := ioutil.ReadFile(args)
+	require.NoError(t, err)
+	assert.Equal(t, "get --context  --request-timeout 5s -o json -n default ConfigMap foo\n", string(got))
+
+	// omitted by default
+	_, err = Kubectl{}.Get("default", "ConfigMap", "foo")
+	require.NoError(t, err)
+	got, err = ioutil.ReadFile(args)
+	require.NoError(t, err)
+	assert.NotContains(t, string(got), "--request-timeout")
+}
+
+func TestGetImpersonation(t *testing.T) {
+	args, cleanup := fakeKubectl(t)
+	defer cleanup()
+
+	k := Kubectl{opts: Opts{
+		As:       "system:serviceaccount:default:auditor",
+		AsGroups: []string{"auditors", "system:authenticated"},
+	}}
+	_, err := k.Get("default", "ConfigMap", "foo")
+	require.NoError(t, err)
+
+	got, err := ioutil.ReadFile(args)
+	require.NoError(t, err)
+	assert.Equal(t, "get --context  --as system:serviceaccount:default:auditor --as-group auditors --as-group system:authenticated -o json -n default ConfigMap foo\n", string(got))
+
+	// omitted by default
+	_, err = Kubectl{}.Get("default", "ConfigMap", "foo")
+	require.NoError(t, err)
+	got, err = ioutil.ReadFile(args)
+	require.NoError(t, err)
+	assert.NotContains(t, string(got), "--as")
+}
+
+func TestGetProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// fake kubectl, recording the proxy of each object it was called for
+	bin := filepath.Join(dir, "kubectl")
+	script := fmt.Sprintf("#!/bin/sh\nfor last; do :; done\necho \"$HTTPS_PROXY\" > %s/proxy-$last\necho '{\"apiVersion\": \"v1\", \"kind\": \"ConfigMap\", \"metadata\": {\"name\": \"'$last'\"}}'\n", dir)
+	require.NoError(t, ioutil.WriteFile(bin, []byte(script), 0755))
+	os.Setenv("TANKA_KUBECTL_PATH", bin)
+	defer os.Unsetenv("TANKA_KUBECTL_PATH")
+
+	// the environment is overridden
+	os.Setenv("HTTPS_PROXY", "http://other:3128")
+	defer os.Unsetenv("HTTPS_PROXY")
+
+	k := Kubectl{opts: Opts{ProxyURL: "socks5://bastion:1080"}}
+
+	names := []string{"a", "b", "c", "d", "e"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			_, err := k.Get("default", "ConfigMap", name)
+			assert.NoError(t, err)
+		}(name)
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		got, err := ioutil.ReadFile(filepath.Join(dir, "proxy-"+name))
+		require.NoError(t, err)
+		assert.Equal(t, "socks5://bastion:1080\n", string(got), name)
+	}
+
+	// the environment is used by default
+	_, err = Kubectl{}.Get("default", "ConfigMap", "a")
+	require.NoError(t, err)
+	got, err := ioutil.ReadFile(filepath.Join(dir, "proxy-a"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://other:3128\n", string(got))
+}
+
+func TestParseGetErrConnection(t *testing.T) {
+	cases := []struct {
+		stderr string
+		conn   bool
+	}{
+		{"Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout", true},
+		{"The connection to the server eu.example.com:6443 was refused - did you specify the right host or port?", true},
+		{"Unable to connect to the server: net/http: TLS handshake timeout", true},
+		{`Error from server (Forbidden): configmaps "foo" is forbidden: User "auditor" cannot get resource "configmaps"`, false},
+		{`Error from server (NotFound): configmaps "foo" not found`, false},
+		{"error: unknown flag", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.stderr, func(t *testing.T) {
+			err := parseGetErr(fmt.Errorf("exit status 1"), c.stderr)
+			_, ok := err.(ErrorConnection)
+			assert.Equal(t, c.conn, ok)
+		})
+	}
+}
+
+func TestGetByStateUnwrap(t *testing.T) {
+	cases := []struct {
+		name     string
+		response string
+		want     []string
+	}{
+		{
+			name:     "list",
+			response: `{"apiVersion": "v1", "kind": "List", "items": [{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}, {"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "b"}}]}`,
+			want:     []string{"ConfigMap/a", "Secret/b"},
+		},
+		{
+			name:     "typedList",
+			response: `{"apiVersion": "v1", "kind": "ConfigMapList", "items": [{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}]}`,
+			want:     []string{"ConfigMap/a"},
+		},
+		{
+			// kubectl does not wrap a single object
+			name:     "single",
+			response: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}`,
+			want:     []string{"ConfigMap/a"},
+		},
+		{
+			name:     "empty",
+			response: `{"apiVersion": "v1", "kind": "List", "items": []}`,
+			want:     []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "kubectl")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			bin := filepath.Join(dir, "kubectl")
+			script := fmt.Sprintf("#!/bin/sh\ncat > /dev/null\necho '%s'\n", c.response)
+			require.NoError(t, ioutil.WriteFile(bin, []byte(script), 0755))
+			os.Setenv("TANKA_KUBECTL_PATH", bin)
+			defer os.Unsetenv("TANKA_KUBECTL_PATH")
+
+			state := manifest.List{{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "a"}}}
+			list, err := Kubectl{}.GetByState(state, GetByStateOpts{})
+			require.NoError(t, err)
+
+			got := make([]string, 0, len(list))
+			for _, m := range list {
+				got = append(got, m.Kind()+"/"+m.Metadata().Name())
+			}
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestUnwrapListMalformed(t *testing.T) {
+	_, err := unwrapList(manifest.Manifest{"items": []interface{}{"foo"}})
+	assert.Error(t, err)
+
+	_, err = unwrapList(manifest.Manifest{"metadata": map[string]interface{}{"name": "a"}})
+	assert.Error(t, err)
+}
+
+func TestGetFields(t *testing.T) {
+	cases := []struct {
+		name   string
+		fields []string
+		output string
+		want   manifest.Manifest
+		whole  bool
+	}{
+		{
+			name:   "jsonpath",
+			fields: []string{"data", "metadata"},
+			output: "v1\nConfigMap\n{\"name\":\"foo\",\"managedFields\":[{\"manager\":\"kubectl\"}]}\n{\"key\":\"value\"}\n",
+			want: manifest.Manifest{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "foo"},
+				"data":       map[string]interface{}{"key": "value"},
+			},
+		},
+		{
+			// values of objects keep their type
+			name:   "numericString",
+			fields: []string{"data"},
+			output: "v1\nConfigMap\n{\"name\":\"foo\"}\n{\"count\":\"1\",\"enabled\":\"true\"}\n",
+			want: manifest.Manifest{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "foo"},
+				"data":       map[string]interface{}{"count": "1", "enabled": "true"},
+			},
+		},
+		{
+			// "1" could be a string or a number
+			name:   "scalar",
+			fields: []string{"version"},
+			output: "v1\nConfigMap\n{\"name\":\"foo\"}\n1\n",
+			whole:  true,
+		},
+		{
+			// could be an empty string or absent, like spec
+			name:   "empty",
+			fields: []string{"type"},
+			output: "v1\nConfigMap\n{\"name\":\"foo\"}\n\n",
+			whole:  true,
+		},
+		{
+			// old kubectl versions print maps in Go syntax
+			name:   "unparseable",
+			fields: []string{"data"},
+			output: "v1\nConfigMap\nmap[name:foo]\nmap[key:value]\n",
+			whole:  true,
+		},
+		{
+			name:   "invalid field",
+			fields: []string{"data-x"},
+			whole:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "kubectl")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			// fake kubectl, answering jsonpath requests with output and
+			// others with the whole object
+			output := filepath.Join(dir, "output")
+			require.NoError(t, ioutil.WriteFile(output, []byte(c.output), 0644))
+			bin := filepath.Join(dir, "kubectl")
+			script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$*\" >> %s/args\ncase \"$*\" in\n*jsonpath=*) cat %s;;\n*) echo '{\"apiVersion\": \"v1\", \"kind\": \"ConfigMap\", \"metadata\": {\"name\": \"whole\"}, \"data\": {\"key\": \"value\"}}';;\nesac\n", dir, output)
+			require.NoError(t, ioutil.WriteFile(bin, []byte(script), 0755))
+			os.Setenv("TANKA_KUBECTL_PATH", bin)
+			defer os.Unsetenv("TANKA_KUBECTL_PATH")
+
+			got, err := Kubectl{}.GetFields("default", "ConfigMap", "foo", c.fields)
+			require.NoError(t, err)
+
+			args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+			require.NoError(t, err)
+			if c.whole {
+				assert.Equal(t, "whole", got.Metadata().Name())
+				assert.Contains(t, string(args), "-o json ")
+				return
+			}
+
+			assert.Equal(t, c.want, got)
+			assert.Equal(t, 1, strings.Count(string(args), "\n"))
+			assert.Contains(t, string(args), "-o jsonpath={.apiVersion}{\"\\n\"}{.kind}{\"\\n\"}{.metadata}{\"\\n\"}")
+		})
+	}
+}
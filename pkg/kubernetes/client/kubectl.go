@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -15,15 +16,66 @@ import (
 // Kubectl uses the `kubectl` command to operate on a Kubernetes cluster
 type Kubectl struct {
 	info Info
+	opts Opts
+
+	// limiter throttles get requests, if Opts.QPS is set
+	limiter *limiter
+}
+
+// Opts allow to tune how Kubectl talks to the cluster
+type Opts struct {
+	// RequestTimeout bounds every single request to the cluster, so a
+	// unresponsive cluster cannot hang Tanka. Passed to kubectl as
+	// --request-timeout. Disabled if zero
+	RequestTimeout time.Duration
+
+	// QPS limits the number of get requests per second, so large concurrent
+	// diffs do not trip the rate limits of the API server. Disabled if zero
+	QPS float64
+	// Burst is the number of get requests allowed to exceed QPS for a short
+	// time. Defaults to 1
+	Burst int
+
+	// As impersonates this user or service account (e.g.
+	// `system:serviceaccount:<namespace>:<name>`) for all requests, to verify
+	// what it would see. Passed to kubectl as --as
+	As string
+	// AsGroups impersonates these groups, passed to kubectl as --as-group
+	AsGroups []string
+
+	// ProxyURL is used to reach the cluster, e.g. through a bastion. Passed to
+	// kubectl as $HTTPS_PROXY, overriding the one of the environment. A
+	// proxy-url of the kubeconfig takes precedence
+	ProxyURL string
+
+	// Kubeconfigs are the paths of kubeconfig files to use instead of the
+	// ones of $KUBECONFIG, e.g. one per cluster. They are merged like a
+	// colon-separated $KUBECONFIG, before the context is resolved
+	Kubeconfigs []string
+
+	// DiscoveryCache persists the results of API discovery in this directory
+	// for DiscoveryTTL (see CachedDiscovery). Discovery is still only done
+	// once per run if empty
+	DiscoveryCache string
+	// DiscoveryTTL defaults to DefaultDiscoveryTTL
+	DiscoveryTTL time.Duration
 }
 
 // New returns a instance of Kubectl with a correct context already discovered.
 func New(endpoint string) (*Kubectl, error) {
-	k := Kubectl{}
+	return NewWithOpts(endpoint, Opts{})
+}
+
+// NewWithOpts is like New, but uses the given Opts
+func NewWithOpts(endpoint string, opts Opts) (*Kubectl, error) {
+	k := Kubectl{opts: opts}
+	if opts.QPS > 0 {
+		k.limiter = newLimiter(opts.QPS, opts.Burst)
+	}
 
 	// discover context
 	var err error
-	k.info.Kubeconfig, err = findContext(endpoint)
+	k.info.Kubeconfig, err = findContext(endpoint, opts.Kubeconfigs)
 	if err != nil {
 		return nil, errors.Wrap(err, "finding usable context")
 	}
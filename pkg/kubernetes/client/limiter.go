@@ -0,0 +1,56 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket, allowing qps operations per second on average and
+// up to burst at once. It is safe for concurrent use.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+
+	// replaceable for testing
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newLimiter(qps float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		interval: time.Duration(float64(time.Second) / qps),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// wait blocks until the next operation is allowed
+func (l *limiter) wait() {
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	// reserve a token, even if it is not available yet. This keeps concurrent
+	// waiters in order
+	l.tokens--
+	delay := time.Duration(-l.tokens * float64(l.interval))
+	l.mu.Unlock()
+
+	if delay > 0 {
+		l.sleep(delay)
+	}
+}
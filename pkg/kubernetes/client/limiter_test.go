@@ -0,0 +1,34 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept []time.Duration
+
+	l := newLimiter(10, 2)
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+
+	// the burst passes immediately, further ones are spaced by 1/qps
+	for i := 0; i < 4; i++ {
+		l.wait()
+	}
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 100 * time.Millisecond}, slept)
+
+	// tokens refill while idle, but never beyond burst
+	slept = nil
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		l.wait()
+	}
+	assert.Equal(t, []time.Duration{100 * time.Millisecond}, slept)
+}
@@ -18,13 +18,38 @@ type Resources []Resource
 
 // Namespaced returns whether a resource is namespace-specific or cluster-wide
 func (r Resources) Namespaced(m manifest.Manifest) bool {
-	for _, res := range r {
-		if m.Kind() == res.Kind {
-			return res.Namespaced
+	res, ok := r.Lookup(m)
+	if !ok {
+		return false
+	}
+	return res.Namespaced
+}
+
+// Lookup returns the Resource of the given object. Resources of the same kind
+// are told apart by their API group, if possible.
+func (r Resources) Lookup(m manifest.Manifest) (Resource, bool) {
+	group := ""
+	if parts := strings.SplitN(m.APIVersion(), "/", 2); len(parts) == 2 {
+		group = parts[0]
+	}
+
+	var found *Resource
+	for i, res := range r {
+		if m.Kind() != res.Kind {
+			continue
+		}
+		if res.APIGroup == group {
+			return res, true
+		}
+		if found == nil {
+			found = &r[i]
 		}
 	}
 
-	return false
+	if found == nil {
+		return Resource{}, false
+	}
+	return *found, true
 }
 
 // Resource is a Kubernetes API Resource
@@ -6,6 +6,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
 )
 
 func TestUnmarshalTable(t *testing.T) {
@@ -74,3 +76,34 @@ networking  Ingress     true
 `
 
 var tblNothing = ``
+
+func TestResourcesLookup(t *testing.T) {
+	res := Resources{
+		{APIGroup: "", Kind: "Event", Namespaced: true},
+		{APIGroup: "events.k8s.io", Kind: "Event", Namespaced: true},
+		{APIGroup: "example.com", Kind: "Widget", Namespaced: false},
+		{APIGroup: "other.io", Kind: "Widget", Namespaced: true},
+	}
+
+	cases := []struct {
+		apiVersion, kind string
+		want             Resource
+		found            bool
+	}{
+		{apiVersion: "example.com/v1", kind: "Widget", want: res[2], found: true},
+		{apiVersion: "other.io/v1beta1", kind: "Widget", want: res[3], found: true},
+		{apiVersion: "v1", kind: "Event", want: res[0], found: true},
+		{apiVersion: "events.k8s.io/v1", kind: "Event", want: res[1], found: true},
+		// unknown group: falls back to kind
+		{apiVersion: "unknown.io/v1", kind: "Widget", want: res[2], found: true},
+		{apiVersion: "v1", kind: "Gadget", found: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.apiVersion+"/"+c.kind, func(t *testing.T) {
+			got, ok := res.Lookup(manifest.Manifest{"apiVersion": c.apiVersion, "kind": c.kind})
+			assert.Equal(t, c.found, ok)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package client
+
+import (
+	"bytes"
+	"os"
+)
+
+// OpenAPISchema returns the OpenAPI v2 (swagger) document served by the API
+// server
+func (k Kubectl) OpenAPISchema() ([]byte, error) {
+	cmd := k.ctl("get", "--raw", "/openapi/v2")
+
+	var buf bytes.Buffer
+	cmd.Stdout = &buf
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,214 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/kubernetes/util"
+)
+
+// fakeClient is an in-memory client.Client for testing. Methods not
+// implemented here panic, because of the embedded nil interface.
+type fakeClient struct {
+	client.Client
+
+	objects   manifest.List
+	resources client.Resources
+	info      client.Info
+
+	// schema is returned by OpenAPISchema, unless schemaErr is set
+	schema    []byte
+	schemaErr error
+
+	// getFunc overrides Get, if set
+	getFunc func(namespace, kind, name string) (manifest.Manifest, error)
+	// getByStateErr is returned by GetByState, if set
+	getByStateErr error
+	// denied lists the permissions CanI rejects, formatted as
+	// "<verb> <kind> <namespace>"
+	denied map[string]bool
+
+	// applied holds the objects passed to Apply
+	applied manifest.List
+	// diffOpts holds the options passed to DiffServerSide
+	diffOpts []client.DiffOpts
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func newFakeClient(objects ...manifest.Manifest) *fakeClient {
+	return &fakeClient{objects: objects}
+}
+
+func (f *fakeClient) record(format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+// Calls returns a copy of all recorded calls
+func (f *fakeClient) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *fakeClient) Info() client.Info {
+	return f.info
+}
+
+func (f *fakeClient) OpenAPISchema() ([]byte, error) {
+	f.record("openapi")
+	if f.schemaErr != nil {
+		return nil, f.schemaErr
+	}
+	return f.schema, nil
+}
+
+// CallsWith returns all recorded calls starting with prefix
+func (f *fakeClient) CallsWith(prefix string) []string {
+	var calls []string
+	for _, call := range f.Calls() {
+		if strings.HasPrefix(call, prefix) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Gets returns the number of recorded Get calls
+func (f *fakeClient) Gets() int {
+	return len(f.CallsWith("get "))
+}
+
+func (f *fakeClient) Get(namespace, kind, name string) (manifest.Manifest, error) {
+	f.record("get %s %s %s", namespace, kind, name)
+	if f.getFunc != nil {
+		return f.getFunc(namespace, kind, name)
+	}
+
+	for _, m := range f.objects {
+		if m.Kind() == kind && m.Metadata().Namespace() == namespace && m.Metadata().Name() == name {
+			return manifest.Manifest(copyMSI(m)), nil
+		}
+	}
+	return nil, client.ErrorNotFound{}
+}
+
+func (f *fakeClient) GetFields(namespace, kind, name string, fields []string) (manifest.Manifest, error) {
+	f.record("getFields %s %s %s %v", namespace, kind, name, fields)
+	for _, m := range f.objects {
+		if m.Kind() == kind && m.Metadata().Namespace() == namespace && m.Metadata().Name() == name {
+			return client.Project(manifest.Manifest(copyMSI(m)), fields), nil
+		}
+	}
+	return nil, client.ErrorNotFound{}
+}
+
+func (f *fakeClient) GetByLabels(namespace, kind string, labels map[string]string) (manifest.List, error) {
+	f.record("list %s %s %v", namespace, kind, labels)
+
+	var list manifest.List
+	for _, m := range f.objects {
+		if !matchesKind(m, kind) || (namespace != "" && m.Metadata().Namespace() != namespace) {
+			continue
+		}
+		if !hasLabels(m, labels) {
+			continue
+		}
+		list = append(list, manifest.Manifest(copyMSI(m)))
+	}
+	return list, nil
+}
+
+// matchesKind reports whether m is of one of the comma separated kinds, which
+// may be qualified by their API group (Kind.group), like kubectl accepts them
+func matchesKind(m manifest.Manifest, kinds string) bool {
+	for _, k := range strings.Split(kinds, ",") {
+		if k == m.Kind() || k == strings.TrimSuffix(m.Kind()+"."+RefOf(m).Group, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLabels(m manifest.Manifest, labels map[string]string) bool {
+	for k, v := range labels {
+		if m.Metadata().Labels()[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeClient) CanI(verb, kind, namespace string) (bool, error) {
+	f.record("can-i %s %s %s", verb, kind, namespace)
+	return !f.denied[fmt.Sprintf("%s %s %s", verb, kind, namespace)], nil
+}
+
+func (f *fakeClient) Namespaces() (map[string]bool, error) {
+	f.record("namespaces")
+	namespaces := make(map[string]bool)
+	for _, m := range f.objects {
+		if m.Kind() == "Namespace" {
+			namespaces[m.Metadata().Name()] = true
+		}
+	}
+	return namespaces, nil
+}
+
+// DiffServerSide reports every object as changed, rendering only a header
+// instead of the actual differences
+func (f *fakeClient) DiffServerSide(data manifest.List, opts client.DiffOpts) (*string, error) {
+	names := make([]string, 0, len(data))
+	for _, m := range data {
+		names = append(names, m.Metadata().Name())
+	}
+	f.record("diffServerSide %v", names)
+	f.mu.Lock()
+	f.diffOpts = append(f.diffOpts, opts)
+	f.mu.Unlock()
+
+	d := ""
+	for _, m := range data {
+		d += fmt.Sprintf("diff -u -N kubectl/%s\n", util.DiffName(m))
+	}
+	return &d, nil
+}
+
+func (f *fakeClient) Apply(data manifest.List, opts client.ApplyOpts) error {
+	f.record("apply %d", len(data))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.applied = append(f.applied, data...)
+	return nil
+}
+
+func (f *fakeClient) Resources() (client.Resources, error) {
+	f.record("resources")
+	return f.resources, nil
+}
+
+func (f *fakeClient) GetByState(data manifest.List, opts client.GetByStateOpts) (manifest.List, error) {
+	f.record("getByState %d %v", len(data), opts.Labels)
+	if f.getByStateErr != nil {
+		return nil, f.getByStateErr
+	}
+
+	var list manifest.List
+	for _, d := range data {
+		for _, m := range f.objects {
+			if objectKey(m) == objectKey(d) && hasLabels(m, opts.Labels) {
+				list = append(list, manifest.Manifest(copyMSI(m)))
+			}
+		}
+	}
+	if len(list) == 0 {
+		return nil, client.ErrorNothingReturned{}
+	}
+	return list, nil
+}
@@ -0,0 +1,45 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// actionSymbols prefix the lines of DiffResult.Compact
+var actionSymbols = map[PlanAction]string{
+	PlanCreate: "+",
+	PlanUpdate: "~",
+	PlanPrune:  "-",
+}
+
+// Compact renders every changed object as a single line, like
+// `~ apps/Deployment/default/grafana (3 fields changed)`, without the actual
+// differences. Meant for notifications, where a full diff is too long.
+func (r DiffResult) Compact() string {
+	var s strings.Builder
+	for _, e := range r.Entries {
+		if e.Diff == "" {
+			continue
+		}
+
+		n := len(changedFields(e.Diff, e.Live, e.Merged))
+		fields := fmt.Sprintf("%d fields changed", n)
+		if n == 1 {
+			fields = "1 field changed"
+		}
+		fmt.Fprintf(&s, "%s %s (%s)\n", actionSymbols[e.Action()], compactRef(e.Ref), fields)
+	}
+	return s.String()
+}
+
+// compactRef returns the ref as `<group>/<kind>/<namespace>/<name>`. The group
+// is omitted for the core group, the namespace for cluster-wide objects
+func compactRef(r ObjectRef) string {
+	parts := make([]string, 0, 4)
+	for _, p := range []string{r.Group, r.Kind, r.Namespace, r.Name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
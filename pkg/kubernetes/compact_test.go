@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestCompact(t *testing.T) {
+	before := deploymentWithImage("grafana/grafana:7.0.0")
+	before["spec"].(map[string]interface{})["replicas"] = 1
+
+	after := deploymentWithImage("grafana/grafana:7.1.0")
+	after["spec"].(map[string]interface{})["replicas"] = 2
+	after.Metadata()["labels"] = map[string]interface{}{"app": "grafana"}
+
+	a := manifest.List{
+		before,
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("removed", "default", map[string]interface{}{"foo": "bar"}),
+	}
+	b := manifest.List{
+		after,
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		clusterRole(),
+	}
+
+	result, err := DiffRenders(a, b, SubsetDiffOpts{})
+	require.NoError(t, err)
+
+	assert.Equal(t, `~ apps/Deployment/default/grafana (3 fields changed)
++ rbac.authorization.k8s.io/ClusterRole/reader (4 fields changed)
+- ConfigMap/default/removed (5 fields changed)
+`, result.Compact())
+}
+
+func TestSubsetDifferCompact(t *testing.T) {
+	c := newFakeClient(configMap("config", "default", map[string]interface{}{"foo": "old"}))
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{Compact: true})(manifest.List{
+		configMap("config", "default", map[string]interface{}{"foo": "new"}),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Equal(t, "~ ConfigMap/default/config (1 field changed)\n", *diff)
+}
@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// declaredKinds returns the kinds defined by the CustomResourceDefinitions of
+// state, keyed by groupKind
+func declaredKinds(state manifest.List) map[string]bool {
+	kinds := make(map[string]bool)
+	for _, m := range state {
+		if m.Kind() != "CustomResourceDefinition" {
+			continue
+		}
+
+		spec, _ := m["spec"].(map[string]interface{})
+		names, _ := spec["names"].(map[string]interface{})
+		group, _ := spec["group"].(string)
+		kind, _ := names["kind"].(string)
+		if kind != "" {
+			kinds[group+"/"+kind] = true
+		}
+	}
+	return kinds
+}
+
+// groupKind returns the API group and kind of m, like `monitoring.coreos.com/ServiceMonitor`
+func groupKind(m manifest.Manifest) string {
+	return RefOf(m).Group + "/" + m.Kind()
+}
+
+// awaitsCRD returns whether m is a custom resource of a kind declared by the
+// desired state (see declaredKinds), that is unknown to the API server, as
+// its CustomResourceDefinition is not established yet. Such objects cannot
+// exist either, and requesting them fails.
+func awaitsCRD(m manifest.Manifest, declared map[string]bool, resources client.Resources) bool {
+	if !declared[groupKind(m)] {
+		return false
+	}
+
+	group := RefOf(m).Group
+	for _, res := range resources {
+		if res.Kind == m.Kind() && res.APIGroup == group {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/spec/v1alpha1"
+)
+
+func serviceMonitorCRD() manifest.Manifest {
+	return manifest.Manifest{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "servicemonitors.monitoring.coreos.com"},
+		"spec": map[string]interface{}{
+			"group": "monitoring.coreos.com",
+			"scope": "Namespaced",
+			"names": map[string]interface{}{"kind": "ServiceMonitor", "plural": "servicemonitors"},
+		},
+	}
+}
+
+func serviceMonitor(name string) manifest.Manifest {
+	return manifest.Manifest{
+		"apiVersion": "monitoring.coreos.com/v1",
+		"kind":       "ServiceMonitor",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"spec":       map[string]interface{}{"jobLabel": name},
+	}
+}
+
+// crdClient returns a fakeClient of a cluster not knowing the ServiceMonitor
+// kind yet. Requesting objects of unknown kinds fails, like kubectl does.
+func crdClient(t *testing.T) *fakeClient {
+	c := newFakeClient(manifest.Manifest{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "default"}})
+	c.info = client.Info{ClientVersion: semver.MustParse("1.20.0"), ServerVersion: semver.MustParse("1.20.0")}
+	c.resources = client.Resources{
+		{Kind: "CustomResourceDefinition", APIGroup: "apiextensions.k8s.io", Verbs: "[get list]"},
+		{Kind: "ConfigMap", Namespaced: true, Verbs: "[get list]"},
+	}
+	c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		if kind == "ServiceMonitor" {
+			return nil, client.ErrorUnknownResource{}
+		}
+		return nil, client.ErrorNotFound{}
+	}
+	return c
+}
+
+func TestSubsetDifferAwaitCRDs(t *testing.T) {
+	state := manifest.List{serviceMonitorCRD(), serviceMonitor("grafana"), configMap("config", "default", nil)}
+
+	_, err := SubsetDiffer(crdClient(t), SubsetDiffOpts{})(state)
+	require.Error(t, err)
+
+	c := crdClient(t)
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{AwaitCRDs: true})(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Contains(t, *diff, "+kind: ServiceMonitor")
+	assert.NotContains(t, c.CallsWith("get "), "get default ServiceMonitor grafana")
+
+	// only kinds declared by the state are awaited
+	_, err = SubsetDiffer(crdClient(t), SubsetDiffOpts{AwaitCRDs: true})(state[1:])
+	require.Error(t, err)
+}
+
+func TestDiffAwaitCRDs(t *testing.T) {
+	env := v1alpha1.New()
+	env.Spec.DiffStrategy = "native"
+	state := manifest.List{serviceMonitorCRD(), serviceMonitor("grafana"), configMap("config", "default", nil)}
+
+	// passed to kubectl diff, which fails for unknown kinds
+	c := crdClient(t)
+	_, err := newKubernetes(*env, c).Diff(state, DiffOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"diffServerSide [servicemonitors.monitoring.coreos.com grafana config]"}, c.CallsWith("diffServerSide"))
+
+	c = crdClient(t)
+	d, err := newKubernetes(*env, c).Diff(state, DiffOpts{AwaitCRDs: true})
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Equal(t, []string{"diffServerSide [servicemonitors.monitoring.coreos.com config]"}, c.CallsWith("diffServerSide"))
+	assert.Contains(t, *d, "+kind: ServiceMonitor")
+}
+
+func TestDeclaredKinds(t *testing.T) {
+	crd := serviceMonitorCRD()
+	assert.Equal(t, map[string]bool{"monitoring.coreos.com/ServiceMonitor": true}, declaredKinds(manifest.List{crd, configMap("config", "default", nil)}))
+
+	// known to the cluster
+	assert.False(t, awaitsCRD(serviceMonitor("grafana"), declaredKinds(manifest.List{crd}), client.Resources{
+		{Kind: "ServiceMonitor", APIGroup: "monitoring.coreos.com", Namespaced: true},
+	}))
+	// of the same kind, but another group
+	assert.True(t, awaitsCRD(serviceMonitor("grafana"), declaredKinds(manifest.List{crd}), client.Resources{
+		{Kind: "ServiceMonitor", APIGroup: "example.com", Namespaced: true},
+	}))
+}
@@ -0,0 +1,46 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/kubernetes/util"
+)
+
+// CreatedRendering controls how objects that do not exist in the cluster yet
+// are rendered (see SubsetDiffOpts.Created)
+type CreatedRendering string
+
+const (
+	// CreatedFull shows the whole object as additions. The default
+	CreatedFull CreatedRendering = "full"
+	// CreatedCompact replaces the diff with a single `(new object)` line
+	CreatedCompact CreatedRendering = "compact"
+	// CreatedSummary only shows the key fields of the object (see
+	// createdSummaryPaths) as additions
+	CreatedSummary CreatedRendering = "summary"
+)
+
+var createdRenderings = []CreatedRendering{CreatedFull, CreatedCompact, CreatedSummary}
+
+// createdSummaryPaths are shown by CreatedSummary, besides the identity of
+// the object
+var createdSummaryPaths = []string{"metadata.labels", "spec.replicas", "spec.type"}
+
+// createdDiff renders the diff of the object m, that does not exist in the
+// cluster yet, according to opts.Created. should is m serialized
+func (opts SubsetDiffOpts) createdDiff(m manifest.Manifest, should string) (string, error) {
+	switch opts.Created {
+	case "", CreatedFull:
+		return opts.diff(m, "", should)
+	case CreatedCompact:
+		return fmt.Sprintf("+ %s (new object)\n", util.DiffName(m)), nil
+	case CreatedSummary:
+		summary, err := opts.serializer().Marshal(keepPaths(m, createdSummaryPaths))
+		if err != nil {
+			return "", err
+		}
+		return opts.diff(m, "", summary)
+	}
+	return "", fmt.Errorf("unknown rendering '%s' of created objects. Pick one of: %v", opts.Created, createdRenderings)
+}
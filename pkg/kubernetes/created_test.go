@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSubsetDifferCreated(t *testing.T) {
+	m := deploymentWithImage("grafana/grafana:7.3.0")
+	m.Metadata()["labels"] = map[string]interface{}{"app": "grafana"}
+	m["spec"].(map[string]interface{})["replicas"] = float64(2)
+
+	cases := []struct {
+		created  CreatedRendering
+		contains []string
+		excludes []string
+	}{
+		{
+			created:  CreatedFull,
+			contains: []string{"+  name: grafana", "+  replicas: 2", "+      - image: grafana/grafana:7.3.0"},
+		},
+		{
+			// the default
+			created:  "",
+			contains: []string{"+  replicas: 2", "+      - image: grafana/grafana:7.3.0"},
+		},
+		{
+			created:  CreatedCompact,
+			contains: []string{"+ apps-v1.Deployment.default.grafana (new object)\n"},
+			excludes: []string{"@@", "image"},
+		},
+		{
+			created: CreatedSummary,
+			contains: []string{
+				"+apiVersion: apps/v1",
+				"+kind: Deployment",
+				"+    app: grafana",
+				"+  name: grafana",
+				"+  namespace: default",
+				"+  replicas: 2",
+			},
+			excludes: []string{"image", "selector"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.created), func(t *testing.T) {
+			// the client reports NotFound for any object
+			result, err := diffState(newFakeClient(), manifest.List{m}, SubsetDiffOpts{Created: c.created})
+			require.NoError(t, err)
+			require.Len(t, result.Entries, 1)
+
+			e := result.Entries[0]
+			assert.Equal(t, PlanCreate, e.Action())
+			assert.Contains(t, e.Merged, "image: grafana/grafana:7.3.0")
+			for _, s := range c.contains {
+				assert.Contains(t, e.Diff, s)
+			}
+			for _, s := range c.excludes {
+				assert.NotContains(t, e.Diff, s)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := diffState(newFakeClient(), manifest.List{m}, SubsetDiffOpts{Created: "bogus"})
+		assert.EqualError(t, err, "diffing apps/v1/Deployment/default/grafana (render): invoking diff: unknown rendering 'bogus' of created objects. Pick one of: [full compact summary]")
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		live := deploymentWithImage("grafana/grafana:7.2.0")
+		result, err := diffState(newFakeClient(live), manifest.List{deploymentWithImage("grafana/grafana:7.3.0")}, SubsetDiffOpts{Created: CreatedCompact})
+		require.NoError(t, err)
+		assert.Contains(t, result.Entries[0].Diff, "+      - image: grafana/grafana:7.3.0")
+	})
+}
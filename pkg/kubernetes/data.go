@@ -0,0 +1,47 @@
+package kubernetes
+
+import "github.com/grafana/tanka/pkg/kubernetes/manifest"
+
+// dataFields hold the payload of data-carrying kinds. Unless
+// SubsetDiffOpts.SubsetData is set, they are compared exactly, as the subset
+// diff would otherwise hide keys removed from the desired state.
+var dataFields = map[string][]string{
+	"ConfigMap": {"data", "binaryData"},
+	"Secret":    {"data"},
+}
+
+// exactDataFields returns the dataFields of local to be compared exactly.
+// Fields absent locally are skipped, e.g. the data of Secrets populated by a
+// controller or using stringData only.
+func exactDataFields(local, live manifest.Manifest) []string {
+	var fields []string
+	for _, f := range dataFields[local.Kind()] {
+		_, want := local[f]
+		_, got := live[f]
+		if want && got {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// dropStringData removes the keys of the stringData of the Secret local from
+// the data of live. The cluster merges stringData into data, so these keys
+// are not missing from the desired state.
+func dropStringData(local, live manifest.Manifest) {
+	stringData, ok := local["stringData"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	data, ok := live["data"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	want, _ := local["data"].(map[string]interface{})
+
+	for k := range stringData {
+		if _, ok := want[k]; !ok {
+			delete(data, k)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSubsetDifferData(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  SubsetDiffOpts
+		local manifest.Manifest
+		live  manifest.Manifest
+
+		contains []string
+		excludes []string
+		clean    bool
+	}{
+		{
+			name:     "removed key",
+			local:    configMap("config", "default", map[string]interface{}{"a": "1"}),
+			live:     configMap("config", "default", map[string]interface{}{"a": "1", "b": "2"}),
+			contains: []string{"-  b: \"2\"", "   a: \"1\""},
+		},
+		{
+			name:  "subset",
+			opts:  SubsetDiffOpts{SubsetData: true},
+			local: configMap("config", "default", map[string]interface{}{"a": "1"}),
+			live:  configMap("config", "default", map[string]interface{}{"a": "1", "b": "2"}),
+			clean: true,
+		},
+		{
+			name: "binaryData",
+			local: func() manifest.Manifest {
+				m := configMap("config", "default", nil)
+				m["binaryData"] = map[string]interface{}{"a": "AQI="}
+				return m
+			}(),
+			live: func() manifest.Manifest {
+				m := configMap("config", "default", nil)
+				m["binaryData"] = map[string]interface{}{"a": "AQI=", "b": "AwQ="}
+				return m
+			}(),
+			contains: []string{"-  b: AwQ="},
+		},
+		{
+			name:     "secret",
+			local:    secret("creds", map[string]string{"user": "admin"}),
+			live:     secret("creds", map[string]string{"user": "admin", "password": "hunter2"}),
+			contains: []string{"-  password: aHVudGVyMg=="},
+		},
+		{
+			// the cluster merges stringData into data
+			name: "stringData",
+			local: func() manifest.Manifest {
+				m := secret("creds", map[string]string{"user": "admin"})
+				m["stringData"] = map[string]interface{}{"password": "hunter2"}
+				return m
+			}(),
+			live:     secret("creds", map[string]string{"user": "admin", "password": "hunter2", "token": "abc"}),
+			contains: []string{"-  token: YWJj"},
+			excludes: []string{"-  password"},
+		},
+		{
+			// e.g. populated by a controller
+			name: "absent locally",
+			local: func() manifest.Manifest {
+				m := secret("token", nil)
+				delete(m, "data")
+				return m
+			}(),
+			live:  secret("token", map[string]string{"token": "abc"}),
+			clean: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, err := SubsetDiffer(newFakeClient(c.live), c.opts)(manifest.List{c.local})
+			require.NoError(t, err)
+			if c.clean {
+				assert.Nil(t, d)
+				return
+			}
+
+			require.NotNil(t, d)
+			for _, s := range c.contains {
+				assert.Contains(t, *d, s)
+			}
+			for _, s := range c.excludes {
+				assert.NotContains(t, *d, s)
+			}
+		})
+	}
+}
@@ -20,6 +20,13 @@ that makes 'kubectl diff' modify resources in your cluster.
 Please upgrade kubectl to at least version 1.18.1.`)
 	}
 
+	// nothing to diff, e.g. because the environment is empty or all objects
+	// were filtered. Like `kubectl diff`, this reports no differences
+	state = filterKinds(state, opts.Kinds)
+	if len(state) == 0 && !opts.WithPrune {
+		return nil, nil
+	}
+
 	// required for separating
 	namespaces, err := k.ctl.Namespaces()
 	if err != nil {
@@ -48,16 +55,32 @@ Please upgrade kubectl to at least version 1.18.1.`)
 	// would cause an error
 	//
 	// live: all other resources
-	live, soon := separate(state, k.Env.Spec.Namespace, separateOpts{
+	sepOpts := separateOpts{
 		namespaces: namespaces,
 		resources:  resources,
-	})
+	}
+	if opts.AwaitCRDs {
+		sepOpts.crds = declaredKinds(state)
+	}
+	live, soon := separate(state, k.Env.Spec.Namespace, sepOpts)
 
 	// differ for live resources
 	liveDiff, err := k.differ(opts.Strategy)
 	if err != nil {
 		return nil, err
 	}
+	if opts.ShowStatusReplicas {
+		liveDiff = k.statusReplicasDiffer(opts.Strategy, liveDiff)
+	}
+
+	// new objects are reported as created, all others are skipped
+	if opts.OnlyNew {
+		created, err := NewObjects(k.ctl, live)
+		if err != nil {
+			return nil, err
+		}
+		live, soon = nil, append(soon, created...)
+	}
 
 	// reports all resources as created
 	staticDiffAllCreated := StaticDiffer(true)
@@ -67,12 +90,13 @@ Please upgrade kubectl to at least version 1.18.1.`)
 
 	// include orphaned resources in the diff if it was requested by the user
 	orphaned := manifest.List{}
-	if opts.WithPrune {
+	if opts.WithPrune && !opts.OnlyNew {
 		// find orphaned resources
 		orphaned, err = k.Orphaned(state)
 		if err != nil {
 			return nil, err
 		}
+		orphaned = filterKinds(orphaned, opts.Kinds)
 	}
 
 	// run the diff
@@ -89,6 +113,11 @@ Please upgrade kubectl to at least version 1.18.1.`)
 		return nil, nil
 	}
 
+	if opts.MinChanges > 0 || opts.SortByChanges {
+		arranged := util.ArrangeDiff(*d, opts.MinChanges, opts.SortByChanges)
+		d = &arranged
+	}
+
 	if opts.Summarize {
 		return util.Diffstat(*d)
 	}
@@ -99,6 +128,9 @@ Please upgrade kubectl to at least version 1.18.1.`)
 type separateOpts struct {
 	namespaces map[string]bool
 	resources  client.Resources
+
+	// crds holds the kinds declared by the state, see awaitsCRD
+	crds map[string]bool
 }
 
 func separate(state manifest.List, defaultNs string, opts separateOpts) (live manifest.List, soon manifest.List) {
@@ -111,6 +143,12 @@ func separate(state manifest.List, defaultNs string, opts separateOpts) (live ma
 	}
 
 	for _, m := range state {
+		// special case: kind unknown, BUT its CRD will be created during apply
+		if awaitsCRD(m, opts.crds, opts.resources) {
+			soon = append(soon, m)
+			continue
+		}
+
 		// non-namespaced always live
 		if !opts.resources.Namespaced(m) {
 			live = append(live, m)
@@ -151,11 +189,28 @@ func (e ErrorDiffStrategyUnknown) Error() string {
 	return fmt.Sprintf("diff strategy `%s` does not exist. Pick one of: %v", e.Requested, strats)
 }
 
-func (k *Kubernetes) differ(override string) (Differ, error) {
-	strategy := k.Env.Spec.DiffStrategy
+// ErrorNoObjects occurs when there is nothing to diff, e.g. because all
+// objects were filtered out. It is distinct from "no differences", so users are
+// not misled into thinking their environment matches the cluster. Returned by
+// SubsetDiffer and the functions built on it, but not by Kubernetes.Diff,
+// which reports no differences in that case.
+type ErrorNoObjects struct{}
+
+func (e ErrorNoObjects) Error() string {
+	return "no objects to diff after filtering"
+}
+
+// strategy returns the diff-strategy to use: override if set, the one of the
+// spec otherwise
+func (k *Kubernetes) strategy(override string) string {
 	if override != "" {
-		strategy = override
+		return resolveDiffStrategy(override, k.ctl.Info().ServerVersion)
 	}
+	return k.Env.Spec.DiffStrategy
+}
+
+func (k *Kubernetes) differ(override string) (Differ, error) {
+	strategy := k.strategy(override)
 
 	d, ok := k.differs[strategy]
 	if !ok {
@@ -168,13 +223,33 @@ func (k *Kubernetes) differ(override string) (Differ, error) {
 	return d, nil
 }
 
+// statusReplicasDiffer returns the subset or exact differ of the strategy,
+// with SubsetDiffOpts.ShowStatusReplicas set. Other differs do not support it
+// and are returned as is.
+func (k *Kubernetes) statusReplicasDiffer(override string, d Differ) Differ {
+	opts := k.subsetOpts
+	opts.ShowStatusReplicas = true
+	switch k.strategy(override) {
+	case "subset":
+		return SubsetDiffer(k.ctl, opts)
+	case "exact":
+		return ExactDiffer(k.ctl, opts)
+	}
+	return d
+}
+
 // StaticDiffer returns a differ that reports all resources as either created or
 // deleted.
 func StaticDiffer(create bool) Differ {
 	return func(state manifest.List) (*string, error) {
 		s := ""
 		for _, m := range state {
-			is, should := m.String(), ""
+			str, err := manifest.Encoder{}.Marshal(m)
+			if err != nil {
+				return nil, err
+			}
+
+			is, should := str, ""
 			if create {
 				is, should = should, is
 			}
@@ -204,6 +279,11 @@ type multiDiff []struct {
 func (m multiDiff) diff() (*string, error) {
 	diff := ""
 	for _, d := range m {
+		// individual groups may be empty, e.g. when no namespaces are created
+		if len(d.state) == 0 {
+			continue
+		}
+
 		s, err := d.differ(d.state)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,131 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// ServerSideDiffer returns a Differ that uses `kubectl diff --server-side`,
+// which computes the differences using server-side apply. This gives the most
+// accurate results, but requires Kubernetes 1.18 or later.
+func ServerSideDiffer(c client.Client) Differ {
+	return func(state manifest.List) (*string, error) {
+		return c.DiffServerSide(state, client.DiffOpts{ServerSide: true})
+	}
+}
+
+// LastAppliedDiffer returns a Differ that uses `kubectl diff`, which merges the
+// desired state using the last-applied-configuration annotation on the server.
+// Requires Kubernetes 1.13 or later.
+func LastAppliedDiffer(c client.Client) Differ {
+	return func(state manifest.List) (*string, error) {
+		return c.DiffServerSide(state, client.DiffOpts{})
+	}
+}
+
+// MixedDiffer returns a Differ choosing the strategy per object: objects whose
+// live counterpart has the last-applied-configuration annotation (i.e. they
+// were created using `kubectl apply`) are diffed using LastAppliedDiffer,
+// which also catches fields removed locally. All others, including ones that
+// do not exist in the cluster yet, are diffed using SubsetDiffer.
+func MixedDiffer(c client.Client, opts SubsetDiffOpts) Differ {
+	lastApplied, subset := LastAppliedDiffer(c), SubsetDiffer(c, opts)
+	return func(state manifest.List) (*string, error) {
+		applied, others, err := splitLastApplied(c, state)
+		if err != nil {
+			return nil, err
+		}
+
+		return multiDiff{
+			{differ: lastApplied, state: applied},
+			{differ: subset, state: others},
+		}.diff()
+	}
+}
+
+// splitLastApplied separates the objects of state whose live counterpart has
+// the last-applied-configuration annotation from the others. Only a single
+// request is made, and the order of state is kept within both lists.
+func splitLastApplied(c client.Client, state manifest.List) (applied, others manifest.List, err error) {
+	live, err := c.GetByState(state, client.GetByStateOpts{IgnoreNotFound: true})
+	if _, ok := err.(client.ErrorNothingReturned); ok {
+		return nil, state, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	for i, l := range matchLive(state, live) {
+		var annotations map[string]interface{}
+		if l != nil {
+			annotations, _ = l.Metadata()["annotations"].(map[string]interface{})
+		}
+		if _, ok := annotations[AnnotationLastApplied]; ok {
+			applied = append(applied, state[i])
+		} else {
+			others = append(others, state[i])
+		}
+	}
+	return applied, others, nil
+}
+
+// ExactDiffer returns a Differ that, unlike SubsetDiffer, compares all fields of
+// the desired state and the cluster. Fields present in the cluster but not in
+// the desired state are shown as removals, so removing a field locally shows
+// up in the diff. Only fields maintained by the API server itself (status,
+// uid, resourceVersion, ...) are left out. Note that fields defaulted by the
+// API server are shown as removals as well, unless ignored using opts.Ignore.
+func ExactDiffer(c client.Client, opts SubsetDiffOpts) Differ {
+	opts.exact = true
+	return SubsetDiffer(c, opts)
+}
+
+// DefaultDiffStrategy returns the diff strategy used if the spec sets none:
+//   - native: 1.13 and later
+//   - subset: below 1.13
+//
+// native is returned if the version is unknown. The server strategy is never
+// chosen, as it may report different changes than native. Use
+// AutoDiffStrategy (`"diffStrategy": "auto"`) to opt into it.
+func DefaultDiffStrategy(server *semver.Version) string {
+	if server != nil && minorVersion(server).LessThan(semver.MustParse("1.13.0")) {
+		return "subset"
+	}
+	return "native"
+}
+
+// AutoDiffStrategy returns the most accurate diff strategy supported by an API
+// server of the given version:
+//   - server: 1.18 and later
+//   - native: 1.13 to 1.17
+//   - subset: below 1.13
+//
+// native is returned if the version is unknown.
+func AutoDiffStrategy(server *semver.Version) string {
+	if server != nil && !minorVersion(server).LessThan(semver.MustParse("1.18.0")) {
+		return "server"
+	}
+	return DefaultDiffStrategy(server)
+}
+
+// minorVersion returns the major and minor version of v only, so vendor
+// suffixes (-gke.1) are ignored
+func minorVersion(v *semver.Version) *semver.Version {
+	return semver.MustParse(fmt.Sprintf("%d.%d.0", v.Major(), v.Minor()))
+}
+
+// resolveDiffStrategy returns the diff strategy to use for the given one of
+// the spec or command line: DefaultDiffStrategy if unset, AutoDiffStrategy if
+// "auto" and strategy as is otherwise
+func resolveDiffStrategy(strategy string, server *semver.Version) string {
+	switch strategy {
+	case "":
+		return DefaultDiffStrategy(server)
+	case "auto":
+		return AutoDiffStrategy(server)
+	}
+	return strategy
+}
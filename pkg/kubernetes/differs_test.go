@@ -0,0 +1,148 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/spec/v1alpha1"
+)
+
+func TestDiffStrategySelection(t *testing.T) {
+	cases := []struct {
+		version  string
+		override string
+		want     string
+	}{
+		// the default never changes to server
+		{version: "", want: "native"},
+		{version: "1.12.10", want: "subset"},
+		{version: "1.13.0", want: "native"},
+		{version: "1.17.9", want: "native"},
+		{version: "1.18.0", want: "native"},
+		{version: "1.21.2", want: "native"},
+
+		// auto picks the most accurate one
+		{version: "", override: "auto", want: "native"},
+		{version: "1.12.10", override: "auto", want: "subset"},
+		{version: "1.17.9", override: "auto", want: "native"},
+		{version: "1.18.0", override: "auto", want: "server"},
+		{version: "1.18.0-gke.1", override: "auto", want: "server"},
+		{version: "1.21.2", override: "auto", want: "server"},
+
+		{version: "1.21.2", override: "subset", want: "subset"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.version+c.override, func(t *testing.T) {
+			f := newFakeClient()
+			if c.version != "" {
+				f.info = client.Info{ServerVersion: semver.MustParse(c.version)}
+			}
+
+			env := v1alpha1.New()
+			env.Spec.DiffStrategy = c.override
+
+			k := newKubernetes(*env, f)
+			assert.Equal(t, c.want, k.Env.Spec.DiffStrategy)
+
+			_, err := k.differ("")
+			assert.NoError(t, err)
+
+			// the command line accepts auto as well
+			k = newKubernetes(*v1alpha1.New(), f)
+			if c.override != "" {
+				assert.Equal(t, c.want, k.strategy(c.override))
+			}
+		})
+	}
+}
+
+// TestNativeDiffer asserts native runs `kubectl diff` without --server-side,
+// as it always did
+func TestNativeDiffer(t *testing.T) {
+	f := newFakeClient()
+	f.info = client.Info{ClientVersion: semver.MustParse("1.21.2"), ServerVersion: semver.MustParse("1.21.2")}
+	state := manifest.List{configMap("foo", "default", nil)}
+
+	k := newKubernetes(*v1alpha1.New(), f)
+	_, err := k.Diff(state, DiffOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []client.DiffOpts{{}}, f.diffOpts)
+
+	_, err = k.Diff(state, DiffOpts{Strategy: "server"})
+	require.NoError(t, err)
+	assert.Equal(t, []client.DiffOpts{{}, {ServerSide: true}}, f.diffOpts)
+}
+
+func TestExactDiffer(t *testing.T) {
+	// "b" was removed locally
+	live := configMap("foo", "default", map[string]interface{}{"a": "1", "b": "2"})
+	live.Metadata()["uid"] = "c0ffee"
+	live.Metadata()["resourceVersion"] = "42"
+	live.Metadata()["annotations"] = map[string]interface{}{AnnotationLastApplied: "{}"}
+	state := manifest.List{configMap("foo", "default", map[string]interface{}{"a": "1"})}
+
+	// data is compared exactly by default, see TestSubsetDifferData
+	subset, err := SubsetDiffer(newFakeClient(live), SubsetDiffOpts{SubsetData: true})(state)
+	require.NoError(t, err)
+	assert.Nil(t, subset)
+
+	exact, err := ExactDiffer(newFakeClient(live), SubsetDiffOpts{})(state)
+	require.NoError(t, err)
+	require.NotNil(t, exact)
+	assert.Contains(t, *exact, "-  b: \"2\"")
+
+	// fields maintained by the API server are not shown
+	assert.NotContains(t, *exact, "c0ffee")
+	assert.NotContains(t, *exact, "resourceVersion")
+	assert.NotContains(t, *exact, AnnotationLastApplied)
+}
+
+func TestMixedDiffer(t *testing.T) {
+	applied := func(name string, data map[string]interface{}) manifest.Manifest {
+		m := configMap(name, "default", data)
+		m.Metadata()["annotations"] = map[string]interface{}{AnnotationLastApplied: "{}"}
+		return m
+	}
+
+	c := newFakeClient(
+		applied("applied", map[string]interface{}{"foo": "old"}),
+		configMap("created-otherwise", "default", map[string]interface{}{"foo": "old"}),
+		applied("applied-2", map[string]interface{}{"foo": "bar"}),
+	)
+	state := manifest.List{
+		configMap("applied", "default", map[string]interface{}{"foo": "new"}),
+		configMap("created-otherwise", "default", map[string]interface{}{"foo": "new"}),
+		configMap("new", "default", map[string]interface{}{"foo": "new"}),
+		configMap("applied-2", "default", map[string]interface{}{"foo": "bar"}),
+	}
+
+	gotApplied, gotOthers, err := splitLastApplied(c, state)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.List{state[0], state[3]}, gotApplied)
+	assert.Equal(t, manifest.List{state[1], state[2]}, gotOthers)
+
+	diff, err := MixedDiffer(c, SubsetDiffOpts{})(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+
+	// the annotated objects are diffed by kubectl, the others using subset
+	assert.Equal(t, []string{"diffServerSide [applied applied-2]"}, c.CallsWith("diffServerSide"))
+	assert.Contains(t, *diff, "diff -u -N kubectl/v1.ConfigMap.default.applied\n")
+	assert.Contains(t, *diff, "+  foo: new")
+	assert.Contains(t, *diff, "LIVE-v1.ConfigMap.default.created-otherwise")
+	assert.Contains(t, *diff, "v1.ConfigMap.default.new")
+	assert.NotContains(t, *diff, "kubectl/v1.ConfigMap.default.created-otherwise")
+
+	t.Run("none in cluster", func(t *testing.T) {
+		applied, others, err := splitLastApplied(newFakeClient(), state)
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+		assert.Equal(t, state, others)
+	})
+}
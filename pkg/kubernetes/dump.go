@@ -0,0 +1,24 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// dumpInputs writes the serialized states of e that are passed to diffStr to
+// files below dir, named by its ref: `<ref>.local` holds the desired state,
+// `<ref>.cluster` the live state after subsetting. The latter is empty for
+// objects that do not exist in the cluster yet.
+func dumpInputs(dir string, e DiffEntry) error {
+	// the states are not masked, so they may include Secrets
+	base := filepath.Join(dir, filepath.FromSlash(e.Ref.String()))
+	if err := os.MkdirAll(filepath.Dir(base), 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(base+".local", []byte(e.Merged), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(base+".cluster", []byte(e.Live), 0600)
+}
@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestDumpDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// fields absent locally are dropped by the subset
+	live := configMap("changed", "default", map[string]interface{}{"foo": "old"})
+	live.Metadata()["uid"] = "1234"
+	c := newFakeClient(live)
+	state := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "new"}),
+		configMap("created", "default", map[string]interface{}{"foo": "bar"}),
+	}
+
+	// record what is actually compared
+	inputs := make(map[string][2]string)
+	orig := diffStr
+	defer func() { diffStr = orig }()
+	diffStr = func(name, is, should string) (string, error) {
+		inputs[name] = [2]string{is, should}
+		return orig(name, is, should)
+	}
+
+	_, err = SubsetDiffer(c, SubsetDiffOpts{DumpDir: dir})(state)
+	require.NoError(t, err)
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	local, cluster := read("v1/ConfigMap/default/changed.local"), read("v1/ConfigMap/default/changed.cluster")
+	assert.Equal(t, `apiVersion: v1
+data:
+  foo: old
+kind: ConfigMap
+metadata:
+  name: changed
+  namespace: default
+`, cluster)
+	assert.Equal(t, `apiVersion: v1
+data:
+  foo: new
+kind: ConfigMap
+metadata:
+  name: changed
+  namespace: default
+`, local)
+	assert.Equal(t, [2]string{cluster, local}, inputs["v1.ConfigMap.default.changed"])
+
+	// created objects have no cluster state
+	assert.Contains(t, read("v1/ConfigMap/default/created.local"), "name: created")
+	assert.Empty(t, read("v1/ConfigMap/default/created.cluster"))
+}
+
+func TestDumpDirPermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dump")
+	c := newFakeClient()
+	_, err := SubsetDiffer(c, SubsetDiffOpts{DumpDir: dir})(manifest.List{secret("creds", map[string]string{"password": "hunter2"})})
+	require.NoError(t, err)
+
+	for name, perm := range map[string]os.FileMode{
+		"v1":                              0700,
+		"v1/Secret/default":               0700,
+		"v1/Secret/default/creds.local":   0600,
+		"v1/Secret/default/creds.cluster": 0600,
+	} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, perm, info.Mode().Perm(), name)
+	}
+}
@@ -0,0 +1,121 @@
+package kubernetes
+
+import "strings"
+
+// fillEmpty copies empty maps and lists of small to big, where big lacks them.
+// The API server usually omits empty collections, which would otherwise show
+// up as a difference although they are equivalent.
+func fillEmpty(small, big map[string]interface{}) {
+	for k, v := range small {
+		b, ok := big[k]
+		if !ok || b == nil {
+			if isEmptyCollection(v) {
+				big[k] = v
+			}
+			continue
+		}
+
+		switch a := v.(type) {
+		case map[string]interface{}:
+			if b, ok := b.(map[string]interface{}); ok {
+				fillEmpty(a, b)
+			}
+		case []interface{}:
+			b, ok := b.([]interface{})
+			if !ok {
+				continue
+			}
+			for i := 0; i < len(a) && i < len(b); i++ {
+				am, ok := a[i].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if bm, ok := b[i].(map[string]interface{}); ok {
+					fillEmpty(am, bm)
+				}
+			}
+		}
+	}
+}
+
+func isEmptyCollection(v interface{}) bool {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	case []map[string]interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+// isBlank returns whether v is an empty string or null
+func isBlank(v interface{}) bool {
+	return v == nil || v == ""
+}
+
+// emptyPaths holds the paths of SubsetDiffOpts.EquateEmptyPaths, as keys
+// joined by pathSep. A nil emptyPaths matches every path.
+type emptyPaths map[string]bool
+
+// pathSep joins keys of emptyPaths, as keys may contain dots
+const pathSep = "\x00"
+
+func newEmptyPaths(paths []string) emptyPaths {
+	if paths == nil {
+		return nil
+	}
+	e := make(emptyPaths, len(paths))
+	for _, p := range paths {
+		e[strings.Join(splitPath(p), pathSep)] = true
+	}
+	return e
+}
+
+func (e emptyPaths) matches(path string) bool {
+	return e == nil || e[path]
+}
+
+// equateEmpty makes empty strings, nulls and absent fields of local and live
+// compare equal, at the given paths. Blank fields of live are replaced by
+// those of local, or removed if local lacks them. List items are paired by
+// their index.
+func equateEmpty(local, live map[string]interface{}, paths emptyPaths, prefix string) {
+	for k, lv := range live {
+		path := prefix + k
+		if _, ok := local[k]; !ok && isBlank(lv) && paths.matches(path) {
+			delete(live, k)
+		}
+	}
+
+	for k, v := range local {
+		path := prefix + k
+		b, ok := live[k]
+		if isBlank(v) && (!ok || isBlank(b)) && paths.matches(path) {
+			live[k] = v
+			continue
+		}
+
+		switch a := v.(type) {
+		case map[string]interface{}:
+			if b, ok := b.(map[string]interface{}); ok {
+				equateEmpty(a, b, paths, path+pathSep)
+			}
+		case []interface{}:
+			b, ok := b.([]interface{})
+			if !ok {
+				continue
+			}
+			for i := 0; i < len(a) && i < len(b); i++ {
+				am, ok := a[i].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if bm, ok := b[i].(map[string]interface{}); ok {
+					equateEmpty(am, bm, paths, path+pathSep)
+				}
+			}
+		}
+	}
+}
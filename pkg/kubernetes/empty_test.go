@@ -0,0 +1,162 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestEmptyCollections(t *testing.T) {
+	withEmpty := func() manifest.Manifest {
+		m := deploymentWithImage("grafana/grafana")
+		m.Metadata()["annotations"] = map[string]interface{}{}
+		spec := m["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+		spec["volumes"] = []interface{}{}
+		container := spec["containers"].([]interface{})[0].(map[string]interface{})
+		container["env"] = []interface{}{}
+		container["resources"] = map[string]interface{}{}
+		return m
+	}
+
+	cases := []struct {
+		name        string
+		local, live manifest.Manifest
+		opts        SubsetDiffOpts
+		diff        bool
+	}{
+		{
+			name:  "empty-local",
+			local: withEmpty(),
+			live:  deploymentWithImage("grafana/grafana"),
+		},
+		{
+			name:  "empty-live",
+			local: deploymentWithImage("grafana/grafana"),
+			live:  withEmpty(),
+		},
+		{
+			name:  "keep-empty",
+			local: withEmpty(),
+			live:  deploymentWithImage("grafana/grafana"),
+			opts:  SubsetDiffOpts{KeepEmpty: true},
+			diff:  true,
+		},
+		{
+			name: "non-empty",
+			local: func() manifest.Manifest {
+				m := withEmpty()
+				m.Metadata()["annotations"] = map[string]interface{}{"foo": "bar"}
+				return m
+			}(),
+			live: deploymentWithImage("grafana/grafana"),
+			diff: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := DiffAgainst(manifest.List{c.local}, manifest.List{c.live}, c.opts)
+			require.NoError(t, err)
+			if c.diff {
+				assert.NotEmpty(t, result.Entries[0].Diff)
+			} else {
+				assert.Empty(t, result.Entries[0].Diff)
+			}
+		})
+	}
+}
+
+func TestEquateEmpty(t *testing.T) {
+	with := func(fields map[string]interface{}) manifest.Manifest {
+		m := configMap("config", "default", nil)
+		for k, v := range fields {
+			m[k] = v
+		}
+		return m
+	}
+
+	cases := []struct {
+		name        string
+		local, live manifest.Manifest
+		opts        SubsetDiffOpts
+		diff        bool
+	}{
+		{
+			// the subset diff never compares fields absent locally
+			name:  "absent-local",
+			local: with(nil),
+			live:  with(map[string]interface{}{"schedulerName": ""}),
+			opts:  SubsetDiffOpts{EquateEmpty: true, exact: true},
+		},
+		{
+			name:  "absent-local-disabled",
+			local: with(nil),
+			live:  with(map[string]interface{}{"schedulerName": ""}),
+			opts:  SubsetDiffOpts{exact: true},
+			diff:  true,
+		},
+		{
+			name:  "absent-live",
+			local: with(map[string]interface{}{"schedulerName": ""}),
+			live:  with(nil),
+			opts:  SubsetDiffOpts{EquateEmpty: true},
+		},
+		{
+			name:  "absent-live-disabled",
+			local: with(map[string]interface{}{"schedulerName": ""}),
+			live:  with(nil),
+			diff:  true,
+		},
+		{
+			name:  "null",
+			local: with(map[string]interface{}{"schedulerName": nil}),
+			live:  with(map[string]interface{}{"schedulerName": ""}),
+			opts:  SubsetDiffOpts{EquateEmpty: true},
+		},
+		{
+			name: "nested",
+			local: with(map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "a", "workingDir": ""}},
+			}}),
+			live: with(map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "a"}},
+			}}),
+			opts: SubsetDiffOpts{EquateEmpty: true},
+		},
+		{
+			name:  "non-empty",
+			local: with(map[string]interface{}{"schedulerName": ""}),
+			live:  with(map[string]interface{}{"schedulerName": "custom"}),
+			opts:  SubsetDiffOpts{EquateEmpty: true},
+			diff:  true,
+		},
+		{
+			name:  "path",
+			local: with(map[string]interface{}{"spec": map[string]interface{}{"schedulerName": ""}}),
+			live:  with(map[string]interface{}{"spec": map[string]interface{}{}}),
+			opts:  SubsetDiffOpts{EquateEmptyPaths: []string{"spec.schedulerName"}},
+		},
+		{
+			name:  "other-path",
+			local: with(map[string]interface{}{"spec": map[string]interface{}{"hostname": ""}}),
+			live:  with(map[string]interface{}{"spec": map[string]interface{}{}}),
+			opts:  SubsetDiffOpts{EquateEmptyPaths: []string{"spec.schedulerName"}},
+			diff:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := DiffAgainst(manifest.List{c.local}, manifest.List{c.live}, c.opts)
+			require.NoError(t, err)
+			if c.diff {
+				assert.NotEmpty(t, result.Entries[0].Diff)
+			} else {
+				assert.Empty(t, result.Entries[0].Diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// WriteFiles writes the diff of every changed object to its own file below
+// dir, named by its ref: `<dir>/<apiVersion>/<kind>/[<namespace>/]<name>.diff`.
+// The files hold the same content as Render, including notes. Objects without
+// differences get no file. Existing files are overwritten, but stale ones
+// (e.g. of objects that were changed in a previous run) are not removed.
+func (r DiffResult) WriteFiles(dir string) error {
+	for _, e := range r.Entries {
+		if e.Diff == "" {
+			continue
+		}
+
+		// diffs may include Secrets
+		file := filepath.Join(dir, filepath.FromSlash(e.Ref.String())+".diff")
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file, []byte(e.render()), 0600); err != nil {
+			return errors.Wrapf(err, "writing diff of %s", e.Ref)
+		}
+	}
+	return nil
+}
+
+// WriteDiffFiles returns a PostProcessor writing the diffs to dir using
+// DiffResult.WriteFiles. The diff is still returned as usual. Post-processors
+// running afterwards do not affect the files.
+func WriteDiffFiles(dir string) PostProcessor {
+	return func(r *DiffResult) error {
+		return r.WriteFiles(dir)
+	}
+}
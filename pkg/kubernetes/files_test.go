@@ -0,0 +1,82 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestWriteDiffFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diffs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := newFakeClient(
+		configMap("changed", "default", map[string]interface{}{"foo": "old"}),
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		deploymentWithImage("grafana/grafana:7.0.0"),
+	)
+	state := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "new"}),
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		deploymentWithImage("grafana/grafana:7.1.0"),
+		clusterRole(),
+	}
+
+	opts := SubsetDiffOpts{PostProcessors: []PostProcessor{WriteDiffFiles(dir)}}
+	diff, err := SubsetDiffer(c, opts)(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+
+	var files []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"v1/ConfigMap/default/changed.diff",
+		"apps/v1/Deployment/default/grafana.diff",
+		"rbac.authorization.k8s.io/v1/ClusterRole/" + clusterRole().Metadata().Name() + ".diff",
+	}, files)
+
+	// together, the files hold the whole diff
+	contents := map[string]string{
+		"v1/ConfigMap/default/changed.diff":       "+  foo: new\n",
+		"apps/v1/Deployment/default/grafana.diff": "+      - image: grafana/grafana:7.1.0\n",
+	}
+	for file, line := range contents {
+		got, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+		require.NoError(t, err)
+		assert.Contains(t, string(got), line)
+		assert.Contains(t, *diff, string(got))
+	}
+}
+
+func TestWriteFilesPermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "diffs")
+	r := DiffResult{Entries: []DiffEntry{{
+		Ref:  ObjectRef{Version: "v1", Kind: "Secret", Namespace: "default", Name: "creds"},
+		Diff: "+  password: hunter2\n",
+	}}}
+	require.NoError(t, r.WriteFiles(dir))
+
+	for name, perm := range map[string]os.FileMode{
+		"v1/Secret/default":            0700,
+		"v1/Secret/default/creds.diff": 0600,
+	} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, perm, info.Mode().Perm(), name)
+	}
+}
@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// githubLevels are the annotation commands used by DiffResult.GitHubAnnotations
+var githubLevels = map[PlanAction]string{
+	PlanCreate: "notice",
+	PlanUpdate: "warning",
+	PlanPrune:  "warning",
+}
+
+// GitHubAnnotations renders every changed object as a GitHub Actions
+// workflow command, like
+// `::warning file=main.jsonnet,title=~ apps/Deployment/default/grafana::2 fields changed: spec.replicas, ...`
+// so the changes are shown inline on pull requests. The file is taken from
+// DiffEntry.Source and omitted if unknown. Created objects are reported as
+// notices, all others as warnings.
+func (r DiffResult) GitHubAnnotations() string {
+	var s strings.Builder
+	for _, e := range r.Entries {
+		if e.Diff == "" {
+			continue
+		}
+		action := e.Action()
+
+		props := make([]string, 0, 2)
+		if e.Source != "" {
+			props = append(props, "file="+escapeGitHubProperty(e.Source))
+		}
+		props = append(props, "title="+escapeGitHubProperty(actionSymbols[action]+" "+compactRef(e.Ref)))
+
+		fields := changedFields(e.Diff, e.Live, e.Merged)
+		msg := fmt.Sprintf("%d fields changed", len(fields))
+		if len(fields) == 1 {
+			msg = "1 field changed"
+		}
+		if len(fields) > 0 {
+			msg += ": " + strings.Join(fields, ", ")
+		}
+
+		fmt.Fprintf(&s, "::%s %s::%s\n", githubLevels[action], strings.Join(props, ","), escapeGitHubData(msg))
+	}
+	return s.String()
+}
+
+// WithSources returns a PostProcessor setting DiffEntry.Source of the
+// entries whose object is found in sources, e.g. the files manifests were
+// loaded from
+func WithSources(sources map[ObjectRef]string) PostProcessor {
+	return func(r *DiffResult) error {
+		for i, e := range r.Entries {
+			if src, ok := sources[e.Ref]; ok {
+				r.Entries[i].Source = src
+			}
+		}
+		return nil
+	}
+}
+
+// escapeGitHubData escapes s for use as the message of a workflow command
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes s for use as a property of a workflow command
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeGitHubData(s))
+}
@@ -0,0 +1,64 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestGitHubAnnotations(t *testing.T) {
+	before := deploymentWithImage("grafana/grafana:7.0.0")
+	before["spec"].(map[string]interface{})["replicas"] = 1
+
+	after := deploymentWithImage("grafana/grafana:7.0.0")
+	after["spec"].(map[string]interface{})["replicas"] = 2
+
+	a := manifest.List{
+		before,
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("removed", "default", map[string]interface{}{"foo": "bar"}),
+	}
+	b := manifest.List{
+		after,
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("created", "default", map[string]interface{}{"foo": "bar"}),
+	}
+
+	result, err := DiffRenders(a, b, SubsetDiffOpts{})
+	require.NoError(t, err)
+
+	err = WithSources(map[ObjectRef]string{
+		RefOf(after): "environments/default/grafana.libsonnet",
+		RefOf(b[1]):  "environments/default/main.jsonnet",
+		RefOf(b[2]):  "environments/default/config,new.jsonnet",
+	})(result)
+	require.NoError(t, err)
+
+	assert.Equal(t, `::warning file=environments/default/grafana.libsonnet,title=~ apps/Deployment/default/grafana::1 field changed: spec.replicas
+::notice file=environments/default/config%2Cnew.jsonnet,title=+ ConfigMap/default/created::5 fields changed: apiVersion, data.foo, kind, metadata.name, metadata.namespace
+::warning title=- ConfigMap/default/removed::5 fields changed: apiVersion, data.foo, kind, metadata.name, metadata.namespace
+`, result.GitHubAnnotations())
+}
+
+func TestEscapeGitHub(t *testing.T) {
+	assert.Equal(t, "100%25 done%0Anext: line", escapeGitHubData("100% done\nnext: line"))
+	assert.Equal(t, "a%3Ab%2Cc%0D%0A", escapeGitHubProperty("a:b,c\r\n"))
+}
+
+func TestSubsetDifferGitHubAnnotations(t *testing.T) {
+	c := newFakeClient(configMap("config", "default", map[string]interface{}{"foo": "old"}))
+	local := configMap("config", "default", map[string]interface{}{"foo": "new"})
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{
+		GitHubAnnotations: true,
+		PostProcessors: []PostProcessor{
+			WithSources(map[ObjectRef]string{RefOf(local): "main.jsonnet"}),
+		},
+	})(manifest.List{local})
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Equal(t, "::warning file=main.jsonnet,title=~ ConfigMap/default/config::1 field changed: data.foo\n", *diff)
+}
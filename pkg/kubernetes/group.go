@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// DiffGroup holds the entries of a DiffResult sharing the same label value
+type DiffGroup struct {
+	// Value of the label. Empty for objects lacking the label
+	Value string
+	DiffResult
+}
+
+// GroupByLabel splits the entries by the value of the given label, e.g. to
+// review the changes of multi-team environments per team. Groups are sorted
+// by their value, the one of objects lacking the label comes last. Entries
+// keep their relative order within each group.
+func (r DiffResult) GroupByLabel(key string) []DiffGroup {
+	index := make(map[string]int)
+	var groups []DiffGroup
+	for _, e := range r.Entries {
+		v := e.Labels[key]
+		i, ok := index[v]
+		if !ok {
+			i = len(groups)
+			index[v] = i
+			groups = append(groups, DiffGroup{Value: v})
+		}
+		groups[i].Entries = append(groups[i].Entries, e)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i].Value, groups[j].Value
+		if a == "" || b == "" {
+			return b == ""
+		}
+		return a < b
+	})
+	return groups
+}
+
+// renderGroups renders the groups of GroupByLabel that have differences,
+// each one headed by a comment naming the label value
+func (r DiffResult) renderGroups(key string, budget DiffBudget) string {
+	var s []string
+	for _, g := range r.GroupByLabel(key) {
+		d := g.Render(budget)
+		if d == "" {
+			continue
+		}
+
+		header := fmt.Sprintf("# %s=%s", key, g.Value)
+		if g.Value == "" {
+			header = fmt.Sprintf("# no %s label", key)
+		}
+		s = append(s, header+"\n"+d)
+	}
+	return strings.Join(s, "\n")
+}
+
+// labelsOf returns the labels of m, without modifying it
+func labelsOf(m manifest.Manifest) map[string]string {
+	meta, _ := m["metadata"].(map[string]interface{})
+	raw, _ := meta["labels"].(map[string]interface{})
+	if len(raw) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			labels[k] = s
+		}
+	}
+	return labels
+}
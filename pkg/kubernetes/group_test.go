@@ -0,0 +1,68 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func teamConfigMap(name, team, value string) manifest.Manifest {
+	m := configMap(name, "default", map[string]interface{}{"foo": value})
+	if team != "" {
+		m.Metadata()["labels"] = map[string]interface{}{"team": team}
+	}
+	return m
+}
+
+func TestGroupByLabel(t *testing.T) {
+	c := newFakeClient(
+		teamConfigMap("api", "backend", "old"),
+		teamConfigMap("db", "backend", "old"),
+		teamConfigMap("web", "frontend", "old"),
+		teamConfigMap("shared", "", "old"),
+		teamConfigMap("same", "frontend", "same"),
+	)
+
+	state := manifest.List{
+		teamConfigMap("shared", "", "new"),
+		teamConfigMap("web", "frontend", "new"),
+		teamConfigMap("api", "backend", "new"),
+		teamConfigMap("same", "frontend", "same"),
+		teamConfigMap("db", "backend", "new"),
+	}
+
+	result, err := diffState(c, state, SubsetDiffOpts{})
+	require.NoError(t, err)
+
+	groups := result.GroupByLabel("team")
+	require.Len(t, groups, 3)
+
+	names := func(g DiffGroup) []string {
+		var n []string
+		for _, e := range g.Entries {
+			n = append(n, e.Name)
+		}
+		return n
+	}
+	assert.Equal(t, "backend", groups[0].Value)
+	assert.Equal(t, []string{"v1.ConfigMap.default.api", "v1.ConfigMap.default.db"}, names(groups[0]))
+	assert.Equal(t, "frontend", groups[1].Value)
+	assert.Equal(t, []string{"v1.ConfigMap.default.web", "v1.ConfigMap.default.same"}, names(groups[1]))
+	assert.Equal(t, "", groups[2].Value)
+	assert.Equal(t, []string{"v1.ConfigMap.default.shared"}, names(groups[2]))
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{GroupByLabel: "team"})(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+
+	backend := strings.Index(*diff, "# team=backend\n")
+	frontend := strings.Index(*diff, "\n# team=frontend\n")
+	unlabeled := strings.Index(*diff, "\n# no team label\n")
+	assert.True(t, backend == 0 && backend < frontend && frontend < unlabeled, *diff)
+	assert.Contains(t, (*diff)[unlabeled:], "MERGED-v1.ConfigMap.default.shared")
+	assert.NotContains(t, *diff, "default.same")
+}
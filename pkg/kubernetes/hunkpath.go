@@ -0,0 +1,154 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// annotateHunks appends the dotted paths of the fields changed by each hunk of
+// d to its header, like `@@ -6,7 +6,7 @@ spec.replicas`. live and merged are
+// the documents d was computed from.
+func annotateHunks(d, live, merged string) string {
+	if d == "" {
+		return d
+	}
+
+	lines := strings.Split(d, "\n")
+	paths := make(map[int][]string)
+	var headers []int
+	walkHunks(lines, live, merged, func(header int, p, _ string) {
+		if _, ok := paths[header]; !ok {
+			headers = append(headers, header)
+		}
+		paths[header] = appendUnique(paths[header], p)
+	})
+
+	for _, h := range headers {
+		lines[h] += " " + strings.Join(paths[h], ", ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// changedFields returns the dotted paths of all values changed by d, in the
+// order they first occur. Lines only opening a nested map or list (like
+// `labels:`) are not counted, only the fields therein.
+func changedFields(d, live, merged string) []string {
+	var paths []string
+	walkHunks(strings.Split(d, "\n"), live, merged, func(_ int, p, line string) {
+		if strings.HasSuffix(strings.TrimRight(line, " "), ":") {
+			return
+		}
+		paths = appendUnique(paths, p)
+	})
+	return paths
+}
+
+// walkHunks calls fn with the dotted path of the field of every added or
+// removed line of the diff lines, along with the index of the header of its
+// hunk and the line itself. live and merged are the documents the diff was computed from. Lines
+// that cannot be attributed to a field are skipped.
+func walkHunks(lines []string, live, merged string, fn func(header int, path, line string)) {
+	liveRoot, mergedRoot := parseNode(live), parseNode(merged)
+
+	header := -1
+	var oldLine, newLine int
+	for i, l := range lines {
+		if m := hunkHeader.FindStringSubmatch(l); m != nil {
+			header = i
+			oldLine, _ = strconv.Atoi(m[1])
+			newLine, _ = strconv.Atoi(m[2])
+			continue
+		}
+		if header < 0 || l == "" {
+			continue
+		}
+
+		var p string
+		switch l[0] {
+		case '-':
+			p = pathAt(liveRoot, oldLine)
+			oldLine++
+		case '+':
+			p = pathAt(mergedRoot, newLine)
+			newLine++
+		case ' ':
+			oldLine++
+			newLine++
+		}
+		if p != "" {
+			fn(header, p, l)
+		}
+	}
+}
+
+// appendUnique appends s to list, unless it is contained already
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// parseNode parses a YAML document, returning nil on failure
+func parseNode(s string) *yaml.Node {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// pathAt returns the dotted path of the field at the given line of the
+// document n
+func pathAt(n *yaml.Node, line int) string {
+	if n == nil {
+		return ""
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Line > line || (i+2 < len(n.Content) && n.Content[i+2].Line <= line) {
+				continue
+			}
+
+			sub := ""
+			if line > key.Line {
+				sub = pathAt(value, line)
+			}
+			if sub == "" {
+				return key.Value
+			}
+			if strings.HasPrefix(sub, "[") {
+				return key.Value + sub
+			}
+			return key.Value + "." + sub
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			if item.Line > line || (i+1 < len(n.Content) && n.Content[i+1].Line <= line) {
+				continue
+			}
+
+			sub := pathAt(item, line)
+			idx := fmt.Sprintf("[%d]", i)
+			if sub == "" {
+				return idx
+			}
+			if strings.HasPrefix(sub, "[") {
+				return idx + sub
+			}
+			return idx + "." + sub
+		}
+	}
+	return ""
+}
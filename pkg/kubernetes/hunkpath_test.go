@@ -0,0 +1,68 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestPathAt(t *testing.T) {
+	doc := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: grafana
+  name: grafana
+spec:
+  template:
+    spec:
+      containers:
+      - image: grafana/grafana
+        name: grafana
+        ports:
+        - containerPort: 3000
+      - image: nginx
+        args:
+        - -v
+`
+	root := parseNode(doc)
+	require.NotNil(t, root)
+
+	cases := map[int]string{
+		1:  "apiVersion",
+		3:  "metadata",
+		5:  "metadata.labels.app",
+		6:  "metadata.name",
+		10: "spec.template.spec.containers",
+		11: "spec.template.spec.containers[0].image",
+		12: "spec.template.spec.containers[0].name",
+		14: "spec.template.spec.containers[0].ports[0].containerPort",
+		15: "spec.template.spec.containers[1].image",
+		17: "spec.template.spec.containers[1].args[0]",
+	}
+	for line, want := range cases {
+		assert.Equal(t, want, pathAt(root, line), "line %d", line)
+	}
+}
+
+func TestAnnotatePaths(t *testing.T) {
+	local := deploymentWithImage("grafana/grafana:7.3.0")
+	local.Metadata()["labels"] = map[string]interface{}{"app": "grafana"}
+	live := deploymentWithImage("grafana/grafana:7.2.0")
+	live.Metadata()["labels"] = map[string]interface{}{"app": "old"}
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{AnnotatePaths: true})
+	require.NoError(t, err)
+
+	d := result.Entries[0].Diff
+	assert.Regexp(t, `(?m)^@@ -\d+,\d+ \+\d+,\d+ @@ metadata\.labels\.app$`, d)
+	assert.Regexp(t, `(?m)^@@ -\d+,\d+ \+\d+,\d+ @@ spec\.template\.spec\.containers\[0\]\.image$`, d)
+
+	// disabled by default
+	result, err = DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.Regexp(t, `(?m)^@@ -\d+,\d+ \+\d+,\d+ @@$`, result.Entries[0].Diff)
+}
@@ -0,0 +1,245 @@
+package kubernetes
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// IgnoreRule excludes fields of objects of a certain kind from the diff. Those
+// fields are removed from both the desired and the live state before comparing.
+type IgnoreRule struct {
+	// Kind the rule applies to. Empty matches all kinds
+	Kind string
+	// Paths to the ignored fields in dotted notation, e.g. `spec.volumeName`.
+	// Dots within keys are escaped using a backslash (see splitPath). Lists
+	// are descended into, so `webhooks.clientConfig.caBundle` ignores the
+	// caBundle of every webhook. Drift of fields within lists is not reported
+	// by SubsetDiffOpts.ReportIgnored
+	Paths []string
+
+	// Assigned only ignores fields that are absent from the desired state, as
+	// they are assigned by the API server. Fields set in the desired state are
+	// compared as usual. List items are paired by their index, so
+	// `spec.ports.nodePort` ignores the nodePort of every port lacking one.
+	// As the subset diff never compares fields absent locally, Assigned rules
+	// only affect exact comparisons.
+	Assigned bool
+}
+
+// DefaultIgnores are fields that are filled in by Kubernetes controllers and
+// would otherwise cause perpetual drift. They are used unless
+// SubsetDiffOpts.NoDefaultIgnores is set.
+var DefaultIgnores = append([]IgnoreRule{
+	// set by the binding controller once a volume is bound, unless given
+	{Kind: "PersistentVolumeClaim", Assigned: true, Paths: []string{
+		"spec.volumeName",
+		"spec.volumeMode",
+	}},
+	{Kind: "PersistentVolumeClaim", Paths: []string{"status"}},
+	assignedServiceFields,
+	// injected by cert-manager's cainjector or the webhook itself, unless given
+	{Kind: "ValidatingWebhookConfiguration", Assigned: true, Paths: []string{"webhooks.clientConfig.caBundle"}},
+	{Kind: "MutatingWebhookConfiguration", Assigned: true, Paths: []string{"webhooks.clientConfig.caBundle"}},
+}, containerDefaults()...)
+
+// assignedServiceFields are assigned to Services by the API server
+var assignedServiceFields = IgnoreRule{Kind: "Service", Assigned: true, Paths: []string{
+	"spec.clusterIP",
+	"spec.clusterIPs",
+	"spec.ipFamilies",
+	"spec.ipFamilyPolicy",
+	"spec.sessionAffinity",
+	"spec.ports.nodePort",
+}}
+
+// podSpecs are the paths to the pod spec of workload kinds
+var podSpecs = []struct{ kind, path string }{
+	{"Pod", "spec"},
+	{"Deployment", "spec.template.spec"},
+	{"StatefulSet", "spec.template.spec"},
+	{"DaemonSet", "spec.template.spec"},
+	{"ReplicaSet", "spec.template.spec"},
+	{"Job", "spec.template.spec"},
+	{"CronJob", "spec.jobTemplate.spec.template.spec"},
+}
+
+// containerDefaultFields are defaulted by the API server for every container
+var containerDefaultFields = []string{
+	"imagePullPolicy",
+	"terminationMessagePath",
+	"terminationMessagePolicy",
+}
+
+// probeDefaultFields are defaulted by the API server for every probe
+var probeDefaultFields = []string{
+	"successThreshold",
+	"failureThreshold",
+	"periodSeconds",
+	"timeoutSeconds",
+}
+
+// containerDefaults returns Assigned rules for the container and probe fields
+// defaulted by the API server, for all kinds of podSpecs
+func containerDefaults() []IgnoreRule {
+	rules := make([]IgnoreRule, 0, len(podSpecs))
+	for _, ps := range podSpecs {
+		var paths []string
+		for _, list := range []string{"containers", "initContainers"} {
+			prefix := ps.path + "." + list + "."
+			for _, f := range containerDefaultFields {
+				paths = append(paths, prefix+f)
+			}
+			for _, probe := range []string{"livenessProbe", "readinessProbe", "startupProbe"} {
+				for _, f := range probeDefaultFields {
+					paths = append(paths, prefix+probe+"."+f)
+				}
+			}
+		}
+		rules = append(rules, IgnoreRule{Kind: ps.kind, Assigned: true, Paths: paths})
+	}
+	return rules
+}
+
+// Matches returns whether the rule applies to m
+func (r IgnoreRule) Matches(m manifest.Manifest) bool {
+	return r.Kind == "" || r.Kind == m.Kind()
+}
+
+// ignoreRules returns the rules in effect for the given options
+func (opts SubsetDiffOpts) ignoreRules() []IgnoreRule {
+	rules := make([]IgnoreRule, 0, len(DefaultIgnores)+len(opts.Ignore))
+	if !opts.NoDefaultIgnores {
+		rules = append(rules, DefaultIgnores...)
+	}
+	return append(rules, opts.Ignore...)
+}
+
+// ignoredPaths returns all paths to be ignored for m. If assigned is set, the
+// paths of Assigned rules are returned, otherwise those of all others.
+func ignoredPaths(rules []IgnoreRule, m manifest.Manifest, assigned bool) []string {
+	var paths []string
+	for _, r := range rules {
+		if r.Matches(m) && r.Assigned == assigned {
+			paths = append(paths, r.Paths...)
+		}
+	}
+	return paths
+}
+
+// splitPath returns the keys of the dotted path. Dots preceded by a backslash
+// are part of the key, e.g. `metadata.annotations.example\.com/synced`
+func splitPath(path string) []string {
+	var keys []string
+	var key strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			key.WriteByte('.')
+			i++
+		case path[i] == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+	return append(keys, key.String())
+}
+
+// removePath deletes the field at the dotted path from m, if present. Lists
+// along the path are descended into, removing the field from every item.
+func removePath(m map[string]interface{}, path string) {
+	removeKeys(m, splitPath(path))
+}
+
+func removeKeys(m map[string]interface{}, keys []string) {
+	k := keys[0]
+	if len(keys) == 1 {
+		delete(m, k)
+		return
+	}
+
+	switch next := m[k].(type) {
+	case map[string]interface{}:
+		removeKeys(next, keys[1:])
+	case []interface{}:
+		for _, item := range next {
+			if item, ok := item.(map[string]interface{}); ok {
+				removeKeys(item, keys[1:])
+			}
+		}
+	}
+}
+
+// removeAssigned deletes the fields at keys from live, unless they are set in
+// local. List items of both sides are paired by their index.
+func removeAssigned(local, live map[string]interface{}, keys []string) {
+	k := keys[0]
+	if len(keys) == 1 {
+		if _, ok := local[k]; !ok {
+			delete(live, k)
+		}
+		return
+	}
+
+	switch l := live[k].(type) {
+	case map[string]interface{}:
+		next, _ := local[k].(map[string]interface{})
+		removeAssigned(next, l, keys[1:])
+	case []interface{}:
+		items, _ := local[k].([]interface{})
+		for i, item := range l {
+			liveItem, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var localItem map[string]interface{}
+			if i < len(items) {
+				localItem, _ = items[i].(map[string]interface{})
+			}
+			removeAssigned(localItem, liveItem, keys[1:])
+		}
+	}
+}
+
+// lookupPath returns the field at the dotted path of m
+func lookupPath(m map[string]interface{}, path string) (interface{}, bool) {
+	keys := splitPath(path)
+	for i, k := range keys {
+		v, ok := m[k]
+		if !ok || i == len(keys)-1 {
+			return v, ok
+		}
+
+		if m, ok = v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// ignoredDrift returns the paths at which local and live differ. Like
+// elsewhere, fields absent locally are not considered a difference.
+func (s subsetter) ignoredDrift(local, live manifest.Manifest, paths []string) []string {
+	var drift []string
+	for _, p := range paths {
+		want, ok := lookupPath(local, p)
+		if !ok {
+			continue
+		}
+		got, ok := lookupPath(live, p)
+		if !ok {
+			drift = append(drift, p)
+			continue
+		}
+
+		// wrapped, so subset() handles non-map fields too
+		sub, err := s.subset(map[string]interface{}{"v": want}, map[string]interface{}{"v": got}, "", 0)
+		if err != nil || !reflect.DeepEqual(sub["v"], want) {
+			drift = append(drift, p)
+		}
+	}
+	return drift
+}
@@ -0,0 +1,371 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestIgnorePVCBinding(t *testing.T) {
+	// the cluster rejects changing a volumeName given explicitly
+	pinned := pvc("standard")
+	pinned["spec"].(map[string]interface{})["volumeName"] = "pvc-old"
+
+	live := pvc("standard")
+	spec := live["spec"].(map[string]interface{})
+	spec["volumeName"] = "pvc-1234"
+	spec["volumeMode"] = "Filesystem"
+	live["status"] = map[string]interface{}{
+		"phase":    "Bound",
+		"capacity": map[string]interface{}{"storage": "10Gi"},
+	}
+
+	cases := []struct {
+		name    string
+		local   manifest.Manifest
+		opts    SubsetDiffOpts
+		changed bool
+	}{
+		{
+			name:    "bound",
+			local:   pvc("standard"),
+			changed: false,
+		},
+		{
+			name:    "bound-exact",
+			local:   pvc("standard"),
+			opts:    SubsetDiffOpts{KindStrategies: map[string]string{"PersistentVolumeClaim": ObjectStrategyExact}},
+			changed: false,
+		},
+		{
+			name:    "pinned",
+			local:   pinned,
+			changed: true,
+		},
+		{
+			name:    "storageClass",
+			local:   pvc("fast"),
+			changed: true,
+		},
+		{
+			name:    "no-defaults",
+			local:   pvc("standard"),
+			opts:    SubsetDiffOpts{NoDefaultIgnores: true, KindStrategies: map[string]string{"PersistentVolumeClaim": ObjectStrategyExact}},
+			changed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := DiffAgainst(manifest.List{c.local}, manifest.List{live}, c.opts)
+			require.NoError(t, err)
+			require.Len(t, result.Entries, 1)
+			assert.Equal(t, c.changed, result.Entries[0].Diff != "", result.Entries[0].Diff)
+		})
+	}
+}
+
+func TestIgnoreCustom(t *testing.T) {
+	local := configMap("foo", "default", map[string]interface{}{"a": "1", "b": "2"})
+	live := configMap("foo", "default", map[string]interface{}{"a": "1", "b": "3"})
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{
+		Ignore: []IgnoreRule{{Kind: "ConfigMap", Paths: []string{"data.b"}}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries[0].Diff)
+	assert.NotContains(t, result.Entries[0].Merged, "b: \"2\"")
+
+	// local state must not be modified
+	assert.Equal(t, "2", local["data"].(map[string]interface{})["b"])
+}
+
+func TestIgnoreAssignedServiceFields(t *testing.T) {
+	live := service([]interface{}{port("http", 80, "TCP")}, nil)
+	spec := live["spec"].(map[string]interface{})
+	spec["clusterIP"] = "10.0.0.12"
+	spec["clusterIPs"] = []interface{}{"10.0.0.12"}
+	spec["ipFamilies"] = []interface{}{"IPv4"}
+	spec["ipFamilyPolicy"] = "SingleStack"
+	spec["sessionAffinity"] = "None"
+	spec["ports"].([]interface{})[0].(map[string]interface{})["nodePort"] = 31234.0
+
+	headless := service([]interface{}{port("http", 80, "TCP")}, nil)
+	headless["spec"].(map[string]interface{})["clusterIP"] = "None"
+
+	cases := []struct {
+		name  string
+		local manifest.Manifest
+		opts  SubsetDiffOpts
+		diff  []string
+	}{
+		{
+			name:  "assigned",
+			local: service([]interface{}{port("http", 80, "TCP")}, nil),
+		},
+		{
+			name:  "port",
+			local: service([]interface{}{port("http", 8080, "TCP")}, nil),
+			diff:  []string{"-    port: 80", "+    port: 8080"},
+		},
+		{
+			name:  "set-locally",
+			local: headless,
+			diff:  []string{"-  clusterIP: 10.0.0.12", "+  clusterIP: None"},
+		},
+		{
+			name:  "no-defaults",
+			local: service([]interface{}{port("http", 80, "TCP")}, nil),
+			opts:  SubsetDiffOpts{NoDefaultIgnores: true},
+			diff:  []string{"-  clusterIP: 10.0.0.12", "-    nodePort: 31234"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// all fields are compared, so assigned ones would show up otherwise
+			c.opts.exact = true
+
+			result, err := DiffAgainst(manifest.List{c.local}, manifest.List{manifest.Manifest(copyMSI(live))}, c.opts)
+			require.NoError(t, err)
+			require.Len(t, result.Entries, 1)
+
+			d := result.Entries[0].Diff
+			if c.diff == nil {
+				assert.Empty(t, d)
+			}
+			for _, want := range c.diff {
+				assert.Contains(t, d, want)
+			}
+		})
+	}
+}
+
+func TestIgnoreContainerDefaults(t *testing.T) {
+	withProbe := func(image string) manifest.Manifest {
+		d := deploymentWithImage(image)
+		container := d["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})
+		container["readinessProbe"] = map[string]interface{}{
+			"httpGet": map[string]interface{}{"path": "/health", "port": 3000.0},
+		}
+		return d
+	}
+
+	live := withProbe("grafana/grafana:7.0.0")
+	container := live["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})
+	container["imagePullPolicy"] = "IfNotPresent"
+	container["terminationMessagePath"] = "/dev/termination-log"
+	container["terminationMessagePolicy"] = "File"
+	probe := container["readinessProbe"].(map[string]interface{})
+	probe["successThreshold"] = 1.0
+	probe["failureThreshold"] = 3.0
+	probe["periodSeconds"] = 10.0
+	probe["timeoutSeconds"] = 1.0
+
+	explicit := withProbe("grafana/grafana:7.0.0")
+	explicit["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})["imagePullPolicy"] = "Always"
+
+	cases := []struct {
+		name  string
+		local manifest.Manifest
+		opts  SubsetDiffOpts
+		diff  []string
+	}{
+		{
+			name:  "defaulted",
+			local: withProbe("grafana/grafana:7.0.0"),
+		},
+		{
+			name:  "image",
+			local: withProbe("grafana/grafana:7.1.0"),
+			diff:  []string{"+      - image: grafana/grafana:7.1.0"},
+		},
+		{
+			name:  "explicit",
+			local: explicit,
+			diff:  []string{"-        imagePullPolicy: IfNotPresent", "+        imagePullPolicy: Always"},
+		},
+		{
+			name:  "no-defaults",
+			local: withProbe("grafana/grafana:7.0.0"),
+			opts:  SubsetDiffOpts{NoDefaultIgnores: true},
+			diff:  []string{"-          periodSeconds: 10", "-        terminationMessagePath: /dev/termination-log"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// all fields are compared, so defaulted ones would show up otherwise
+			c.opts.exact = true
+
+			result, err := DiffAgainst(manifest.List{c.local}, manifest.List{manifest.Manifest(copyMSI(live))}, c.opts)
+			require.NoError(t, err)
+			require.Len(t, result.Entries, 1)
+
+			d := result.Entries[0].Diff
+			if c.diff == nil {
+				assert.Empty(t, d)
+			}
+			for _, want := range c.diff {
+				assert.Contains(t, d, want)
+			}
+		})
+	}
+}
+
+func pvc(storageClass string) manifest.Manifest {
+	return manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "PersistentVolumeClaim",
+		"metadata": map[string]interface{}{
+			"name":      "data",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"storageClassName": storageClass,
+			"accessModes":      []interface{}{"ReadWriteOnce"},
+			"resources": map[string]interface{}{
+				"requests": map[string]interface{}{"storage": "10Gi"},
+			},
+		},
+	}
+}
+
+func TestIgnoredDrift(t *testing.T) {
+	rules := []IgnoreRule{{Kind: "ConfigMap", Paths: []string{"data.b", "data.c", "data.nested"}}}
+	opts := SubsetDiffOpts{Ignore: rules, ReportIgnored: true}
+
+	cases := []struct {
+		name  string
+		local map[string]interface{}
+		live  map[string]interface{}
+		drift []string
+	}{
+		{
+			name:  "differing",
+			local: map[string]interface{}{"b": "2", "c": "same"},
+			live:  map[string]interface{}{"b": "3", "c": "same"},
+			drift: []string{"data.b"},
+		},
+		{
+			name:  "missing-live",
+			local: map[string]interface{}{"c": "1"},
+			live:  map[string]interface{}{},
+			drift: []string{"data.c"},
+		},
+		{
+			// only present in the cluster, which is no drift
+			name:  "missing-local",
+			local: map[string]interface{}{},
+			live:  map[string]interface{}{"b": "3"},
+		},
+		{
+			// additional live fields of nested values are no drift either
+			name:  "nested",
+			local: map[string]interface{}{"nested": map[string]interface{}{"x": "1"}},
+			live:  map[string]interface{}{"nested": map[string]interface{}{"x": "1", "y": "2"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			local := configMap("foo", "default", c.local)
+			live := configMap("foo", "default", c.live)
+
+			result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, opts)
+			require.NoError(t, err)
+			assert.Empty(t, result.Entries[0].Diff)
+			assert.Equal(t, c.drift, result.Entries[0].IgnoredDrift)
+		})
+	}
+}
+
+func TestSubsetDifferReportIgnored(t *testing.T) {
+	rules := []IgnoreRule{{Kind: "ConfigMap", Paths: []string{"data.b"}}}
+	c := newFakeClient(
+		configMap("one", "default", map[string]interface{}{"b": "live"}),
+		configMap("two", "default", map[string]interface{}{"b": "live"}),
+		configMap("three", "default", map[string]interface{}{"a": "live", "b": "same"}),
+	)
+	state := manifest.List{
+		configMap("one", "default", map[string]interface{}{"b": "local"}),
+		configMap("two", "default", map[string]interface{}{"b": "local"}),
+		configMap("three", "default", map[string]interface{}{"a": "local", "b": "same"}),
+	}
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{Ignore: rules, ReportIgnored: true})(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Contains(t, *diff, "+  a: local")
+	assert.True(t, strings.HasSuffix(*diff, "\n\n# 2 objects have ignored drift\n"), *diff)
+
+	// ignored drift alone is not hidden either
+	diff, err = SubsetDiffer(c, SubsetDiffOpts{Ignore: rules, ReportIgnored: true})(state[:1])
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Equal(t, "# 1 object has ignored drift\n", *diff)
+
+	// not reported by default
+	diff, err = SubsetDiffer(c, SubsetDiffOpts{Ignore: rules})(state[:1])
+	require.NoError(t, err)
+	assert.Nil(t, diff)
+}
+
+func TestSplitPath(t *testing.T) {
+	assert.Equal(t, []string{"spec", "volumeName"}, splitPath("spec.volumeName"))
+	assert.Equal(t, []string{"metadata", "annotations", "example.com/synced"}, splitPath(`metadata.annotations.example\.com/synced`))
+	assert.Equal(t, []string{"data"}, splitPath("data"))
+}
+
+func TestRemovePath(t *testing.T) {
+	m := map[string]interface{}{
+		"spec": map[string]interface{}{"volumeName": "pv", "keep": true},
+		"webhooks": []interface{}{
+			map[string]interface{}{"name": "a", "clientConfig": map[string]interface{}{"caBundle": "x"}},
+			map[string]interface{}{"name": "b"},
+			"scalar",
+		},
+	}
+	removePath(m, "spec.volumeName")
+	removePath(m, "webhooks.clientConfig.caBundle")
+	removePath(m, "missing.field")
+
+	assert.Equal(t, map[string]interface{}{
+		"spec": map[string]interface{}{"keep": true},
+		"webhooks": []interface{}{
+			map[string]interface{}{"name": "a", "clientConfig": map[string]interface{}{}},
+			map[string]interface{}{"name": "b"},
+			"scalar",
+		},
+	}, m)
+}
+
+func TestKeepClusterKeys(t *testing.T) {
+	local := configMap("foo", "default", map[string]interface{}{"a": "1"})
+
+	live := configMap("foo", "default", map[string]interface{}{"a": "1", "b": "2"})
+	live.Metadata()["annotations"] = map[string]interface{}{
+		"example.com/synced": "2021-03-01T10:00:00Z",
+		"example.com/other":  "x",
+	}
+	live.Metadata()["uid"] = "1234"
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{
+		KeepClusterKeys: []string{`metadata.annotations.example\.com/synced`, "data.b", "spec.missing"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+
+	d := result.Entries[0].Diff
+	assert.Contains(t, d, "-    example.com/synced: \"2021-03-01T10:00:00Z\"")
+	assert.Contains(t, d, "-  b: \"2\"")
+
+	// everything else is still pruned
+	assert.NotContains(t, d, "example.com/other")
+	assert.NotContains(t, d, "uid")
+	assert.NotContains(t, d, "missing")
+}
@@ -0,0 +1,35 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"reflect"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// requiresRecreate reports whether applying local over live changes the
+// content of an immutable ConfigMap or Secret. The API server rejects such
+// updates, the object has to be deleted and created again.
+func requiresRecreate(local, live manifest.Manifest) bool {
+	fields, ok := dataFields[local.Kind()]
+	if !ok || live["immutable"] != true {
+		return false
+	}
+
+	for _, f := range fields {
+		if want, ok := local[f]; ok && !reflect.DeepEqual(want, live[f]) {
+			return true
+		}
+	}
+
+	// the cluster merges stringData into data, base64 encoded
+	stringData, _ := local["stringData"].(map[string]interface{})
+	data, _ := live["data"].(map[string]interface{})
+	for k, v := range stringData {
+		s, _ := v.(string)
+		if data[k] != base64.StdEncoding.EncodeToString([]byte(s)) {
+			return true
+		}
+	}
+	return false
+}
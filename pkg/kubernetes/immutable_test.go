@@ -0,0 +1,97 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func immutable(m manifest.Manifest) manifest.Manifest {
+	m["immutable"] = true
+	return m
+}
+
+func TestSubsetDifferImmutable(t *testing.T) {
+	cases := []struct {
+		name     string
+		local    manifest.Manifest
+		live     manifest.Manifest
+		recreate bool
+	}{
+		{
+			name:     "data changed",
+			local:    immutable(configMap("config", "default", map[string]interface{}{"a": "2"})),
+			live:     immutable(configMap("config", "default", map[string]interface{}{"a": "1"})),
+			recreate: true,
+		},
+		{
+			name:     "key removed",
+			local:    immutable(configMap("config", "default", map[string]interface{}{"a": "1"})),
+			live:     immutable(configMap("config", "default", map[string]interface{}{"a": "1", "b": "2"})),
+			recreate: true,
+		},
+		{
+			// only the content is immutable
+			name: "labels changed",
+			local: func() manifest.Manifest {
+				m := immutable(configMap("config", "default", map[string]interface{}{"a": "1"}))
+				m.Metadata()["labels"] = map[string]interface{}{"team": "a"}
+				return m
+			}(),
+			live: immutable(configMap("config", "default", map[string]interface{}{"a": "1"})),
+		},
+		{
+			name:  "mutable",
+			local: configMap("config", "default", map[string]interface{}{"a": "2"}),
+			live:  configMap("config", "default", map[string]interface{}{"a": "1"}),
+		},
+		{
+			name:     "secret",
+			local:    immutable(secret("creds", map[string]string{"password": "new"})),
+			live:     immutable(secret("creds", map[string]string{"password": "old"})),
+			recreate: true,
+		},
+		{
+			name: "stringData unchanged",
+			local: func() manifest.Manifest {
+				m := immutable(secret("creds", nil))
+				delete(m, "data")
+				m["stringData"] = map[string]interface{}{"password": "hunter2"}
+				m.Metadata()["labels"] = map[string]interface{}{"team": "a"}
+				return m
+			}(),
+			live: immutable(secret("creds", map[string]string{"password": "hunter2"})),
+		},
+		{
+			name: "stringData changed",
+			local: func() manifest.Manifest {
+				m := immutable(secret("creds", nil))
+				delete(m, "data")
+				m["stringData"] = map[string]interface{}{"password": "new"}
+				return m
+			}(),
+			live:     immutable(secret("creds", map[string]string{"password": "hunter2"})),
+			recreate: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := DiffAgainst(manifest.List{c.local}, manifest.List{c.live}, SubsetDiffOpts{})
+			require.NoError(t, err)
+			require.Len(t, r.Entries, 1)
+
+			e := r.Entries[0]
+			require.NotEmpty(t, e.Diff)
+			assert.Equal(t, c.recreate, e.Recreate)
+			if c.recreate {
+				assert.Contains(t, r.Render(DiffBudget{}), "# "+e.Name+": (requires recreate)\n")
+			} else {
+				assert.NotContains(t, r.Render(DiffBudget{}), "requires recreate")
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// normalizeJSONAnnotations replaces the annotations of live holding the same
+// JSON document as the respective annotation of local with the local value,
+// so they are considered equal regardless of key order and formatting. Only
+// JSON objects and arrays are considered, annotations that differ
+// structurally are left untouched.
+func normalizeJSONAnnotations(local, live manifest.Manifest) {
+	want, ok := local.Metadata()["annotations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	got, ok := live.Metadata()["annotations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for k, v := range want {
+		w, ok := v.(string)
+		if !ok {
+			continue
+		}
+		g, ok := got[k].(string)
+		if !ok || g == w {
+			continue
+		}
+
+		wd, ok := parseJSONDocument(w)
+		if !ok {
+			continue
+		}
+		gd, ok := parseJSONDocument(g)
+		if ok && reflect.DeepEqual(wd, gd) {
+			got[k] = w
+		}
+	}
+}
+
+// parseJSONDocument decodes s, if it holds a single JSON object or array.
+// Numbers are kept as json.Number, so no precision is lost comparing them
+func parseJSONDocument(s string) (interface{}, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return nil, false
+	}
+
+	d := json.NewDecoder(bytes.NewReader([]byte(trimmed)))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, false
+	}
+	// trailing data means this is not a single document
+	if d.More() {
+		return nil, false
+	}
+	return v, true
+}
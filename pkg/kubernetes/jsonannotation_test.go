@@ -0,0 +1,64 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func annotated(annotations map[string]interface{}) manifest.Manifest {
+	m := configMap("config", "default", map[string]interface{}{"foo": "bar"})
+	m.Metadata()["annotations"] = annotations
+	return m
+}
+
+func TestNormalizeJSONAnnotations(t *testing.T) {
+	const blob = `{"rules": [{"name": "a", "port": 80}], "enabled": true}`
+
+	cases := []struct {
+		name   string
+		local  string
+		live   string
+		differ bool
+	}{
+		{name: "reordered", local: blob, live: `{"enabled":true,"rules":[{"port":80,"name":"a"}]}`},
+		{name: "indented", local: blob, live: "{\n  \"enabled\": true,\n  \"rules\": [{\"name\": \"a\", \"port\": 80}]\n}\n"},
+		{name: "array", local: `[{"a": 1, "b": 2}]`, live: `[{"b":2,"a":1}]`},
+		{name: "changed", local: blob, live: `{"enabled":false,"rules":[{"port":80,"name":"a"}]}`, differ: true},
+		// lists are ordered in JSON
+		{name: "list order", local: `[1, 2]`, live: `[2, 1]`, differ: true},
+		{name: "precision", local: `{"n": 10000000000000001}`, live: `{"n": 10000000000000000}`, differ: true},
+		{name: "not json", local: "a b", live: "a  b", differ: true},
+		{name: "scalar", local: `"x"`, live: ` "x"`, differ: true},
+		{name: "trailing data", local: `{"a": 1}`, live: `{"a": 1} {"b": 2}`, differ: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			state := manifest.List{annotated(map[string]interface{}{"example.com/config": c.local})}
+			live := manifest.List{annotated(map[string]interface{}{"example.com/config": c.live})}
+
+			result, err := DiffAgainst(state, live, SubsetDiffOpts{NormalizeJSONAnnotations: true})
+			require.NoError(t, err)
+			require.Len(t, result.Entries, 1)
+			if c.differ {
+				assert.NotEmpty(t, result.Entries[0].Diff)
+			} else {
+				assert.Empty(t, result.Entries[0].Diff)
+			}
+		})
+	}
+}
+
+func TestNormalizeJSONAnnotationsDisabled(t *testing.T) {
+	state := manifest.List{annotated(map[string]interface{}{"example.com/config": `{"a": 1, "b": 2}`})}
+	live := manifest.List{annotated(map[string]interface{}{"example.com/config": `{"b":2,"a":1}`})}
+
+	result, err := DiffAgainst(state, live, SubsetDiffOpts{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+	assert.Contains(t, result.Entries[0].Diff, `+    example.com/config: '{"a": 1, "b": 2}'`)
+}
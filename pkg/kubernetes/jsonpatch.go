@@ -0,0 +1,163 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operations of a JSONPatch
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+)
+
+// JSONPatch is a RFC 6902 JSON Patch, a list of operations to be applied in
+// order. The operations are derived deterministically from the compared
+// states, so patches of unchanged objects are equal across runs.
+type JSONPatch []PatchOp
+
+// PatchOp is a single operation of a JSONPatch. Path is a RFC 6901 JSON
+// Pointer, e.g. `/spec/template/spec/containers/0/image`
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON omits the value of remove operations, as they have none
+func (o PatchOp) MarshalJSON() ([]byte, error) {
+	if o.Op == PatchRemove {
+		return json.Marshal(map[string]string{"op": o.Op, "path": o.Path})
+	}
+
+	type plain PatchOp
+	return json.Marshal(plain(o))
+}
+
+// newJSONPatch returns the operations turning live into desired. If live is
+// nil, desired is added as a whole. Both are normalized to plain JSON values
+// first, so e.g. integers and floats of the same value are equal.
+func newJSONPatch(live, desired map[string]interface{}) (JSONPatch, error) {
+	want, err := plainJSON(desired)
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		return JSONPatch{{Op: PatchAdd, Path: "", Value: want}}, nil
+	}
+
+	got, err := plainJSON(live)
+	if err != nil {
+		return nil, err
+	}
+	return patchOps(nil, "", got, want), nil
+}
+
+// plainJSON returns v as decoded by encoding/json
+func plainJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// patchOps appends the operations turning live into desired at path to ops.
+// Maps are descended into in the order of their keys, lists by index.
+func patchOps(ops JSONPatch, path string, live, desired interface{}) JSONPatch {
+	switch want := desired.(type) {
+	case map[string]interface{}:
+		if got, ok := live.(map[string]interface{}); ok {
+			return patchMaps(ops, path, got, want)
+		}
+	case []interface{}:
+		if got, ok := live.([]interface{}); ok {
+			return patchLists(ops, path, got, want)
+		}
+	}
+
+	if !reflect.DeepEqual(live, desired) {
+		ops = append(ops, PatchOp{Op: PatchReplace, Path: path, Value: desired})
+	}
+	return ops
+}
+
+func patchMaps(ops JSONPatch, path string, live, desired map[string]interface{}) JSONPatch {
+	keys := make([]string, 0, len(live)+len(desired))
+	for k := range live {
+		keys = append(keys, k)
+	}
+	for k := range desired {
+		if _, ok := live[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapePointer(k)
+		got, inLive := live[k]
+		want, inDesired := desired[k]
+		switch {
+		case !inDesired:
+			ops = append(ops, PatchOp{Op: PatchRemove, Path: p})
+		case !inLive:
+			ops = append(ops, PatchOp{Op: PatchAdd, Path: p, Value: want})
+		default:
+			ops = patchOps(ops, p, got, want)
+		}
+	}
+	return ops
+}
+
+// patchLists pairs the items of both lists by index. Surplus live items are
+// removed starting from the end, so the indices of the others stay valid.
+// Missing ones are appended.
+func patchLists(ops JSONPatch, path string, live, desired []interface{}) JSONPatch {
+	for i := 0; i < len(live) && i < len(desired); i++ {
+		ops = patchOps(ops, path+"/"+strconv.Itoa(i), live[i], desired[i])
+	}
+	for i := len(live) - 1; i >= len(desired); i-- {
+		ops = append(ops, PatchOp{Op: PatchRemove, Path: path + "/" + strconv.Itoa(i)})
+	}
+	for i := len(live); i < len(desired); i++ {
+		ops = append(ops, PatchOp{Op: PatchAdd, Path: path + "/" + strconv.Itoa(i), Value: desired[i]})
+	}
+	return ops
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escapePointer escapes k for use as a reference token of a JSON Pointer
+func escapePointer(k string) string {
+	return pointerEscaper.Replace(k)
+}
+
+// JSONPatches returns the JSONPatch of every changed entry, keyed by its
+// name. Requires SubsetDiffOpts.JSONPatch
+func (r DiffResult) JSONPatches() map[string]JSONPatch {
+	patches := make(map[string]JSONPatch)
+	for _, e := range r.Entries {
+		if len(e.JSONPatch) > 0 {
+			patches[e.Name] = e.JSONPatch
+		}
+	}
+	return patches
+}
+
+// WriteJSONPatches writes the JSONPatches of r to w as an indented JSON
+// object. Names are sorted, so the output of equal results is identical.
+func (r DiffResult) WriteJSONPatches(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.JSONPatches())
+}
@@ -0,0 +1,245 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestJSONPatch(t *testing.T) {
+	cases := []struct {
+		name          string
+		live, desired map[string]interface{}
+		want          JSONPatch
+	}{
+		{
+			name:    "equal",
+			live:    map[string]interface{}{"a": 1, "b": []interface{}{"x"}},
+			desired: map[string]interface{}{"a": 1.0, "b": []interface{}{"x"}},
+			want:    nil,
+		},
+		{
+			name:    "fields",
+			live:    map[string]interface{}{"changed": "old", "removed": true, "nested": map[string]interface{}{"x": 1}},
+			desired: map[string]interface{}{"changed": "new", "added": nil, "nested": map[string]interface{}{"x": 2}},
+			want: JSONPatch{
+				{Op: PatchAdd, Path: "/added", Value: nil},
+				{Op: PatchReplace, Path: "/changed", Value: "new"},
+				{Op: PatchReplace, Path: "/nested/x", Value: 2.0},
+				{Op: PatchRemove, Path: "/removed"},
+			},
+		},
+		{
+			name:    "escaped",
+			live:    map[string]interface{}{"annotations": map[string]interface{}{"example.com/a~b": "old"}},
+			desired: map[string]interface{}{"annotations": map[string]interface{}{"example.com/a~b": "new"}},
+			want: JSONPatch{
+				{Op: PatchReplace, Path: "/annotations/example.com~1a~0b", Value: "new"},
+			},
+		},
+		{
+			name:    "type changed",
+			live:    map[string]interface{}{"a": map[string]interface{}{"x": 1}, "b": []interface{}{1}},
+			desired: map[string]interface{}{"a": []interface{}{"x"}, "b": "1"},
+			want: JSONPatch{
+				{Op: PatchReplace, Path: "/a", Value: []interface{}{"x"}},
+				{Op: PatchReplace, Path: "/b", Value: "1"},
+			},
+		},
+		{
+			name:    "list shrunk",
+			live:    map[string]interface{}{"l": []interface{}{"a", "b", "c", "d"}},
+			desired: map[string]interface{}{"l": []interface{}{"a", "x"}},
+			want: JSONPatch{
+				{Op: PatchReplace, Path: "/l/1", Value: "x"},
+				{Op: PatchRemove, Path: "/l/3"},
+				{Op: PatchRemove, Path: "/l/2"},
+			},
+		},
+		{
+			name:    "list grown",
+			live:    map[string]interface{}{"l": []interface{}{"a"}},
+			desired: map[string]interface{}{"l": []interface{}{"a", "b", "c"}},
+			want: JSONPatch{
+				{Op: PatchAdd, Path: "/l/1", Value: "b"},
+				{Op: PatchAdd, Path: "/l/2", Value: "c"},
+			},
+		},
+		{
+			name: "list of maps",
+			live: map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:1", "args": []interface{}{"-v"}},
+				map[string]interface{}{"name": "sidecar", "image": "sidecar:1"},
+			}},
+			desired: map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:2"},
+			}},
+			want: JSONPatch{
+				{Op: PatchRemove, Path: "/containers/0/args"},
+				{Op: PatchReplace, Path: "/containers/0/image", Value: "app:2"},
+				{Op: PatchRemove, Path: "/containers/1"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := newJSONPatch(c.live, c.desired)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got)
+
+			assert.Equal(t, plain(t, c.desired), applyJSONPatch(t, plain(t, c.live), got))
+		})
+	}
+}
+
+func TestJSONPatchCreated(t *testing.T) {
+	desired := map[string]interface{}{"a": 1}
+	got, err := newJSONPatch(nil, desired)
+	require.NoError(t, err)
+	assert.Equal(t, JSONPatch{{Op: PatchAdd, Path: "", Value: map[string]interface{}{"a": 1.0}}}, got)
+	assert.Equal(t, plain(t, desired), applyJSONPatch(t, nil, got))
+}
+
+func TestSubsetDifferJSONPatch(t *testing.T) {
+	a := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "old", "bar": "removed"}),
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		deploymentWithImage("app:1"),
+	}
+	b := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "new", "baz": "added"}),
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		deploymentWithImage("app:2"),
+		configMap("created", "default", map[string]interface{}{"foo": "bar"}),
+	}
+
+	result, err := DiffRenders(a, b, SubsetDiffOpts{JSONPatch: true})
+	require.NoError(t, err)
+
+	patches := result.JSONPatches()
+	assert.Len(t, patches, 3)
+	for _, e := range result.Entries {
+		if e.Prune {
+			continue
+		}
+
+		if e.Diff == "" {
+			assert.Empty(t, e.JSONPatch, e.Name)
+			continue
+		}
+		require.NotEmpty(t, e.JSONPatch, e.Name)
+
+		var live, merged interface{}
+		if e.Live != "" {
+			require.NoError(t, yaml.Unmarshal([]byte(e.Live), &live))
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(e.Merged), &merged))
+		assert.Equal(t, merged, applyJSONPatch(t, live, e.JSONPatch), e.Name)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, result.WriteJSONPatches(&buf))
+	var written map[string][]map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &written))
+	assert.Equal(t, []map[string]interface{}{
+		{"op": "remove", "path": "/data/bar"},
+		{"op": "add", "path": "/data/baz", "value": "added"},
+		{"op": "replace", "path": "/data/foo", "value": "new"},
+	}, written["v1.ConfigMap.default.changed"])
+
+	var again bytes.Buffer
+	result, err = DiffRenders(a, b, SubsetDiffOpts{JSONPatch: true})
+	require.NoError(t, err)
+	require.NoError(t, result.WriteJSONPatches(&again))
+	assert.Equal(t, buf.String(), again.String())
+}
+
+func TestPatchOpMarshal(t *testing.T) {
+	data, err := json.Marshal(JSONPatch{
+		{Op: PatchRemove, Path: "/a"},
+		{Op: PatchAdd, Path: "/b", Value: nil},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `[{"op":"remove","path":"/a"},{"op":"add","path":"/b","value":null}]`, string(data))
+}
+
+func plain(t *testing.T, v map[string]interface{}) interface{} {
+	p, err := plainJSON(v)
+	require.NoError(t, err)
+	return p
+}
+
+// applyJSONPatch applies the add, remove and replace operations of patch to
+// doc, as RFC 6902 describes
+func applyJSONPatch(t *testing.T, doc interface{}, patch JSONPatch) interface{} {
+	for _, op := range patch {
+		if op.Path == "" {
+			require.NotEqual(t, PatchRemove, op.Op)
+			doc = op.Value
+			continue
+		}
+
+		tokens := strings.Split(op.Path[1:], "/")
+		for i, tok := range tokens {
+			tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		}
+		doc = applyOp(t, doc, tokens, op)
+	}
+	return doc
+}
+
+func applyOp(t *testing.T, doc interface{}, tokens []string, op PatchOp) interface{} {
+	tok := tokens[0]
+	last := len(tokens) == 1
+
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		if !last {
+			require.Contains(t, d, tok, op.Path)
+			d[tok] = applyOp(t, d[tok], tokens[1:], op)
+			return d
+		}
+		if op.Op != PatchAdd {
+			require.Contains(t, d, tok, op.Path)
+		}
+		if op.Op == PatchRemove {
+			delete(d, tok)
+		} else {
+			d[tok] = op.Value
+		}
+		return d
+	case []interface{}:
+		i, err := strconv.Atoi(tok)
+		require.NoError(t, err, op.Path)
+		if !last {
+			require.True(t, i < len(d), op.Path)
+			d[i] = applyOp(t, d[i], tokens[1:], op)
+			return d
+		}
+		switch op.Op {
+		case PatchAdd:
+			require.True(t, i <= len(d), op.Path)
+			d = append(d[:i], append([]interface{}{op.Value}, d[i:]...)...)
+		case PatchRemove:
+			require.True(t, i < len(d), op.Path)
+			d = append(d[:i], d[i+1:]...)
+		case PatchReplace:
+			require.True(t, i < len(d), op.Path)
+			d[i] = op.Value
+		}
+		return d
+	}
+
+	require.Fail(t, fmt.Sprintf("%s: cannot descend into %T", op.Path, doc))
+	return nil
+}
@@ -0,0 +1,26 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// filterKinds returns the objects of state that are of one of the given kinds,
+// compared case-insensitively. All objects are returned if kinds is empty.
+func filterKinds(state manifest.List, kinds []string) manifest.List {
+	if len(kinds) == 0 {
+		return state
+	}
+
+	out := make(manifest.List, 0, len(state))
+	for _, m := range state {
+		for _, k := range kinds {
+			if strings.EqualFold(m.Kind(), k) {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
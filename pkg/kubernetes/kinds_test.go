@@ -0,0 +1,65 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/spec/v1alpha1"
+)
+
+func TestSubsetDifferKinds(t *testing.T) {
+	state := manifest.List{
+		deploymentWithImage("grafana/grafana"),
+		configMap("foo", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("bar", "other", map[string]interface{}{"foo": "bar"}),
+	}
+
+	t.Run("selected", func(t *testing.T) {
+		c := newFakeClient()
+		_, err := SubsetDiffer(c, SubsetDiffOpts{Kinds: []string{"configmap"}})(state)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"get default ConfigMap foo",
+			"get other ConfigMap bar",
+		}, c.CallsWith("get "))
+	})
+
+	t.Run("none", func(t *testing.T) {
+		c := newFakeClient()
+		_, err := SubsetDiffer(c, SubsetDiffOpts{Kinds: []string{"Service"}})(state)
+		assert.Equal(t, ErrorNoObjects{}, err)
+		assert.Empty(t, c.Calls())
+	})
+
+	t.Run("all", func(t *testing.T) {
+		c := newFakeClient()
+		_, err := SubsetDiffer(c, SubsetDiffOpts{})(state)
+		require.NoError(t, err)
+		assert.Equal(t, 3, c.Gets())
+	})
+}
+
+// TestKubernetesDiffEmpty asserts that `tk diff` reports no differences for
+// empty or fully filtered environments, instead of failing
+func TestKubernetesDiffEmpty(t *testing.T) {
+	c := newFakeClient()
+	c.info = client.Info{ClientVersion: semver.MustParse("1.20.0"), ServerVersion: semver.MustParse("1.20.0")}
+
+	env := v1alpha1.New()
+	env.Spec.DiffStrategy = "subset"
+	k := newKubernetes(*env, c)
+
+	d, err := k.Diff(manifest.List{}, DiffOpts{})
+	require.NoError(t, err)
+	assert.Nil(t, d)
+
+	d, err = k.Diff(manifest.List{configMap("foo", "default", nil)}, DiffOpts{Kinds: []string{"Service"}})
+	require.NoError(t, err)
+	assert.Nil(t, d)
+	assert.Empty(t, c.Calls())
+}
@@ -3,10 +3,9 @@ package kubernetes
 import (
 	"fmt"
 
-	"github.com/Masterminds/semver"
-
 	"github.com/grafana/tanka/pkg/kubernetes/client"
 	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
 	"github.com/grafana/tanka/pkg/spec/v1alpha1"
 )
 
@@ -18,7 +17,8 @@ type Kubernetes struct {
 	ctl client.Client
 
 	// Diffing
-	differs map[string]Differ // List of diff strategies
+	differs    map[string]Differ // List of diff strategies
+	subsetOpts SubsetDiffOpts    // used by the subset and exact differs
 }
 
 // Differ is responsible for comparing the given manifests to the cluster and
@@ -27,31 +27,47 @@ type Differ func(manifest.List) (*string, error)
 
 // New creates a new Kubernetes with an initialized client
 func New(env v1alpha1.Environment) (*Kubernetes, error) {
+	return NewWithOpts(env, client.Opts{})
+}
+
+// NewWithOpts is like New, but configures the client using opts
+func NewWithOpts(env v1alpha1.Environment, opts client.Opts) (*Kubernetes, error) {
 	// setup client
-	ctl, err := client.New(env.Spec.APIServer)
+	ctl, err := client.NewWithOpts(env.Spec.APIServer, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// setup diffing
-	if env.Spec.DiffStrategy == "" {
-		env.Spec.DiffStrategy = "native"
+	// discovery is reused by all differs
+	discovery := client.NewCachedDiscovery(ctl, opts.DiscoveryCache, opts.DiscoveryTTL)
+	return newKubernetes(env, discovery), nil
+}
+
+// newKubernetes sets up diffing for the given client
+func newKubernetes(env v1alpha1.Environment, ctl client.Client) *Kubernetes {
+	env.Spec.DiffStrategy = resolveDiffStrategy(env.Spec.DiffStrategy, ctl.Info().ServerVersion)
 
-		if ctl.Info().ServerVersion.LessThan(semver.MustParse("1.13.0")) {
-			env.Spec.DiffStrategy = "subset"
+	subsetOpts := SubsetDiffOpts{
+		KindStrategies: env.Spec.KindDiffStrategies,
+	}
+	if env.Spec.InjectLabels {
+		subsetOpts.Selector = map[string]string{
+			process.LabelEnvironment: env.Metadata.NameLabel(),
 		}
 	}
 
-	k := Kubernetes{
-		Env: env,
-		ctl: ctl,
+	return &Kubernetes{
+		Env:        env,
+		ctl:        ctl,
+		subsetOpts: subsetOpts,
 		differs: map[string]Differ{
-			"native": ctl.DiffServerSide,
-			"subset": SubsetDiffer(ctl),
+			"server": ServerSideDiffer(ctl),
+			"native": LastAppliedDiffer(ctl),
+			"subset": SubsetDiffer(ctl, subsetOpts),
+			"exact":  ExactDiffer(ctl, subsetOpts),
+			"mixed":  MixedDiffer(ctl, subsetOpts),
 		},
 	}
-
-	return &k, nil
 }
 
 // Close runs final cleanup
@@ -68,6 +84,27 @@ type DiffOpts struct {
 
 	// Set the diff-strategy. If unset, the value set in the spec is used
 	Strategy string
+
+	// Only diff objects of these kinds. All kinds are diffed if empty
+	Kinds []string
+
+	// Order the objects by their number of changed lines, the largest first
+	SortByChanges bool
+	// Hide objects with less changed lines than this
+	MinChanges int
+
+	// Only report objects that do not exist in the cluster yet, as created.
+	// Changes to existing objects are not diffed at all
+	OnlyNew bool
+
+	// Show the replica counts of the status of workloads. Only supported by
+	// the subset and exact strategies, see SubsetDiffOpts.ShowStatusReplicas
+	ShowStatusReplicas bool
+
+	// Report custom resources of kinds declared by a CRD of the state, that
+	// is not established in the cluster yet, as created instead of failing.
+	// See SubsetDiffOpts.AwaitCRDs
+	AwaitCRDs bool
 }
 
 // Info about the client, etc.
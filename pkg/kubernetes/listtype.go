@@ -0,0 +1,218 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// ListType describes how the items of a list are identified, as declared by
+// `x-kubernetes-list-type` in the OpenAPI schema
+type ListType struct {
+	// Type is one of "atomic", "set" or "map"
+	Type string
+	// MapKeys are the fields identifying the items of "map" lists
+	MapKeys []string
+}
+
+// ListTypes holds the ListType of lists by object type ("<apiVersion>/<kind>")
+// and dotted path of the list, e.g. "v1/Service" and "spec.ports"
+type ListTypes map[string]map[string]ListType
+
+// rbacRules treats the rules of RBAC roles as unordered, as apply and
+// generators frequently reorder them. The schema declares them as atomic.
+var rbacRules = map[string]ListType{"rules": {Type: "set"}}
+
+// webhooks are identified by their name, which is required to be unique. The
+// schema declares them as atomic.
+var webhooks = map[string]ListType{"webhooks": {Type: "map", MapKeys: []string{"name"}}}
+
+// DefaultListTypes are used in addition to SubsetDiffOpts.ListTypes, for lists
+// known to be reordered without meaning. ListTypes take precedence.
+var DefaultListTypes = ListTypes{
+	"rbac.authorization.k8s.io/v1/Role":             rbacRules,
+	"rbac.authorization.k8s.io/v1/ClusterRole":      rbacRules,
+	"rbac.authorization.k8s.io/v1beta1/Role":        rbacRules,
+	"rbac.authorization.k8s.io/v1beta1/ClusterRole": rbacRules,
+
+	"admissionregistration.k8s.io/v1/ValidatingWebhookConfiguration":      webhooks,
+	"admissionregistration.k8s.io/v1/MutatingWebhookConfiguration":        webhooks,
+	"admissionregistration.k8s.io/v1beta1/ValidatingWebhookConfiguration": webhooks,
+	"admissionregistration.k8s.io/v1beta1/MutatingWebhookConfiguration":   webhooks,
+}
+
+// merge returns the ListTypes of both l and other. Those of other take
+// precedence. Neither is modified.
+func (l ListTypes) merge(other ListTypes) ListTypes {
+	out := make(ListTypes, len(l)+len(other))
+	for _, types := range []ListTypes{l, other} {
+		for kind, lists := range types {
+			merged := make(map[string]ListType, len(out[kind])+len(lists))
+			for path, lt := range out[kind] {
+				merged[path] = lt
+			}
+			for path, lt := range lists {
+				merged[path] = lt
+			}
+			out[kind] = merged
+		}
+	}
+	return out
+}
+
+// lookup returns the ListTypes of the lists of m
+func (l ListTypes) lookup(m manifest.Manifest) map[string]ListType {
+	return l[m.APIVersion()+"/"+m.Kind()]
+}
+
+// FetchListTypes retrieves the ListTypes from the OpenAPI schema of the cluster
+func FetchListTypes(c client.Client) (ListTypes, error) {
+	data, err := c.OpenAPISchema()
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching OpenAPI schema")
+	}
+	return ParseListTypes(data)
+}
+
+// openAPISchema is the subset of an OpenAPI v2 schema required for ListTypes
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Properties map[string]*openAPISchema `json:"properties"`
+	Items      *openAPISchema            `json:"items"`
+
+	ListType    string   `json:"x-kubernetes-list-type"`
+	ListMapKeys []string `json:"x-kubernetes-list-map-keys"`
+
+	GroupVersionKind []struct {
+		Group   string `json:"group"`
+		Version string `json:"version"`
+		Kind    string `json:"kind"`
+	} `json:"x-kubernetes-group-version-kind"`
+}
+
+// ParseListTypes extracts the ListTypes of all kinds defined in the given
+// OpenAPI v2 document
+func ParseListTypes(data []byte) (ListTypes, error) {
+	var doc struct {
+		Definitions map[string]*openAPISchema `json:"definitions"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "parsing OpenAPI schema")
+	}
+
+	types := make(ListTypes)
+	for name, def := range doc.Definitions {
+		if len(def.GroupVersionKind) == 0 {
+			continue
+		}
+
+		lists := make(map[string]ListType)
+		walkListTypes(doc.Definitions, def, "", map[string]bool{name: true}, lists)
+		if len(lists) == 0 {
+			continue
+		}
+
+		for _, gvk := range def.GroupVersionKind {
+			apiVersion := gvk.Version
+			if gvk.Group != "" {
+				apiVersion = gvk.Group + "/" + gvk.Version
+			}
+			types[apiVersion+"/"+gvk.Kind] = lists
+		}
+	}
+	return types, nil
+}
+
+// walkListTypes records the ListTypes of s and all of its children in lists.
+// seen holds the definitions on the current path, as schemas may be recursive.
+func walkListTypes(defs map[string]*openAPISchema, s *openAPISchema, path string, seen map[string]bool, lists map[string]ListType) {
+	if s == nil {
+		return
+	}
+
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		walkListTypes(defs, defs[name], path, seen, lists)
+		delete(seen, name)
+		return
+	}
+
+	if s.ListType != "" {
+		lists[path] = ListType{Type: s.ListType, MapKeys: s.ListMapKeys}
+	}
+	if s.Items != nil {
+		walkListTypes(defs, s.Items, path, seen, lists)
+	}
+	for k, p := range s.Properties {
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+		walkListTypes(defs, p, child, seen, lists)
+	}
+}
+
+// matchList reorders the items of big to follow the order of their
+// counterparts in small, so lists of type "set" and "map" are compared
+// regardless of their order. Items without counterpart are moved to the end.
+// The returned pairs hold the index of the counterpart in small of each item
+// of the result, or -1.
+func matchList(lt ListType, small, big []interface{}) ([]interface{}, []int) {
+	same := func(a, b interface{}) bool {
+		switch lt.Type {
+		case "map":
+			am, ok := a.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			bm, ok := b.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			for _, k := range lt.MapKeys {
+				// keys omitted locally are defaulted by the server
+				if _, ok := am[k]; !ok {
+					continue
+				}
+				if !reflect.DeepEqual(am[k], bm[k]) {
+					return false
+				}
+			}
+			return true
+		default:
+			return reflect.DeepEqual(a, b)
+		}
+	}
+
+	used := make([]bool, len(big))
+	out := make([]interface{}, 0, len(big))
+	pairs := make([]int, 0, len(big))
+
+	for i, a := range small {
+		for j, b := range big {
+			if used[j] || !same(a, b) {
+				continue
+			}
+			used[j] = true
+			out = append(out, b)
+			pairs = append(pairs, i)
+			break
+		}
+	}
+	for j, b := range big {
+		if !used[j] {
+			out = append(out, b)
+			pairs = append(pairs, -1)
+		}
+	}
+	return out, pairs
+}
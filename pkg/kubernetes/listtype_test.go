@@ -0,0 +1,201 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestParseListTypes(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/openapi/service.json")
+	require.NoError(t, err)
+
+	types, err := ParseListTypes(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, ListTypes{
+		"v1/Service": {
+			"spec.ports":       {Type: "map", MapKeys: []string{"port", "protocol"}},
+			"spec.externalIPs": {Type: "set"},
+		},
+		"example.com/v1/Recursive": {
+			"required": {Type: "set"},
+		},
+	}, types)
+}
+
+func TestFetchListTypes(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/openapi/service.json")
+	require.NoError(t, err)
+
+	c := newFakeClient()
+	c.schema = data
+
+	types, err := FetchListTypes(c)
+	require.NoError(t, err)
+	assert.Contains(t, types, "v1/Service")
+}
+
+func service(ports []interface{}, ips []interface{}) manifest.Manifest {
+	return manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      "grafana",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"ports":       ports,
+			"externalIPs": ips,
+		},
+	}
+}
+
+func port(name string, port float64, protocol string) map[string]interface{} {
+	p := map[string]interface{}{"name": name, "port": port}
+	if protocol != "" {
+		p["protocol"] = protocol
+	}
+	return p
+}
+
+func TestSubsetListTypes(t *testing.T) {
+	types := ListTypes{
+		"v1/Service": {
+			"spec.ports":       {Type: "map", MapKeys: []string{"port", "protocol"}},
+			"spec.externalIPs": {Type: "set"},
+		},
+	}
+
+	cases := []struct {
+		name        string
+		local, live manifest.Manifest
+		diff        []string
+	}{
+		{
+			name: "reordered",
+			local: service(
+				[]interface{}{port("http", 80, "TCP"), port("dns", 53, "UDP"), port("dns-tcp", 53, "TCP")},
+				[]interface{}{"10.0.0.1", "10.0.0.2"},
+			),
+			live: service(
+				[]interface{}{port("dns-tcp", 53, "TCP"), port("dns", 53, "UDP"), port("http", 80, "TCP")},
+				[]interface{}{"10.0.0.2", "10.0.0.1"},
+			),
+		},
+		{
+			name:  "defaulted-key",
+			local: service([]interface{}{port("http", 80, ""), port("https", 443, "")}, nil),
+			live:  service([]interface{}{port("https", 443, "TCP"), port("http", 80, "TCP")}, nil),
+		},
+		{
+			name:  "changed",
+			local: service([]interface{}{port("http", 80, "TCP"), port("metrics", 9090, "TCP")}, nil),
+			live:  service([]interface{}{port("metrics", 9090, "TCP"), port("web", 80, "TCP")}, nil),
+			diff:  []string{"-  - name: web", "+  - name: http"},
+		},
+		{
+			name:  "removed",
+			local: service([]interface{}{port("http", 80, "TCP")}, nil),
+			live:  service([]interface{}{port("old", 8080, "TCP"), port("http", 80, "TCP")}, nil),
+			diff:  []string{"-  - name: old"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := DiffAgainst(manifest.List{c.local}, manifest.List{c.live}, SubsetDiffOpts{ListTypes: types})
+			require.NoError(t, err)
+
+			d := result.Entries[0].Diff
+			if len(c.diff) == 0 {
+				assert.Empty(t, d)
+			}
+			for _, l := range c.diff {
+				assert.Contains(t, d, l)
+			}
+		})
+	}
+
+	// without the schema, reordering causes a diff
+	reordered := cases[0]
+	result, err := DiffAgainst(manifest.List{reordered.local}, manifest.List{reordered.live}, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Entries[0].Diff)
+}
+
+func clusterRole(rules ...interface{}) manifest.Manifest {
+	return manifest.Manifest{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "ClusterRole",
+		"metadata":   map[string]interface{}{"name": "reader"},
+		"rules":      rules,
+	}
+}
+
+func rule(group, resource string, verbs ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiGroups": []interface{}{group},
+		"resources": []interface{}{resource},
+		"verbs":     verbs,
+	}
+}
+
+func TestSubsetRBACRules(t *testing.T) {
+	pods := rule("", "pods", "get", "list")
+	deploys := rule("apps", "deployments", "get")
+	secrets := rule("", "secrets", "get")
+
+	cases := []struct {
+		name        string
+		local, live manifest.Manifest
+		diff        []string
+	}{
+		{
+			name:  "reordered",
+			local: clusterRole(pods, deploys, secrets),
+			live:  clusterRole(secrets, pods, deploys),
+		},
+		{
+			name:  "changed",
+			local: clusterRole(pods, rule("apps", "deployments", "get", "watch")),
+			live:  clusterRole(deploys, pods),
+			diff:  []string{"+  - watch"},
+		},
+		{
+			name:  "removed",
+			local: clusterRole(pods),
+			live:  clusterRole(secrets, pods),
+			diff:  []string{"-  - secrets"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := DiffAgainst(manifest.List{c.local}, manifest.List{c.live}, SubsetDiffOpts{})
+			require.NoError(t, err)
+
+			d := result.Entries[0].Diff
+			if len(c.diff) == 0 {
+				assert.Empty(t, d)
+			}
+			for _, l := range c.diff {
+				assert.Contains(t, d, l)
+			}
+		})
+	}
+
+	// the schema takes precedence
+	atomic := ListTypes{"rbac.authorization.k8s.io/v1/ClusterRole": {"rules": {Type: "atomic"}}}
+	result, err := DiffAgainst(manifest.List{cases[0].local}, manifest.List{cases[0].live}, SubsetDiffOpts{ListTypes: atomic})
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Entries[0].Diff)
+
+	// the defaults are not modified by merging
+	assert.Len(t, DefaultListTypes["rbac.authorization.k8s.io/v1/ClusterRole"], 1)
+	assert.Equal(t, ListType{Type: "set"}, DefaultListTypes["rbac.authorization.k8s.io/v1/ClusterRole"]["rules"])
+}
@@ -0,0 +1,57 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// LabelManagedBy is the recommended label naming the tool managing an object
+const LabelManagedBy = "app.kubernetes.io/managed-by"
+
+// foreignPrefixes are the label and annotation prefixes used by other
+// deployment tools to mark the objects they manage
+var foreignPrefixes = []struct{ prefix, tool string }{
+	{"argocd.argoproj.io/", "ArgoCD"},
+	{"kustomize.toolkit.fluxcd.io/", "Flux"},
+	{"helm.toolkit.fluxcd.io/", "Flux"},
+	{"meta.helm.sh/", "Helm"},
+}
+
+// managedBy returns the tool managing the live object, if it is not Tanka
+// itself, e.g. `Helm`, or `Tanka environment <label>` for objects of
+// another environment. Empty if there are no such markers.
+func managedBy(local, live manifest.Manifest) string {
+	labels, _ := live.Metadata()["labels"].(map[string]interface{})
+
+	if env, ok := labels[process.LabelEnvironment].(string); ok {
+		want, _ := local.Metadata()["labels"].(map[string]interface{})
+		if other, ok := want[process.LabelEnvironment].(string); ok && other != env {
+			return fmt.Sprintf("Tanka environment %s", env)
+		}
+	}
+
+	if tool, _ := labels[LabelManagedBy].(string); tool != "" && !strings.EqualFold(tool, "tanka") {
+		return tool
+	}
+
+	annotations, _ := live.Metadata()["annotations"].(map[string]interface{})
+	for _, f := range foreignPrefixes {
+		if hasPrefixedKey(labels, f.prefix) || hasPrefixedKey(annotations, f.prefix) {
+			return f.tool
+		}
+	}
+	return ""
+}
+
+// hasPrefixedKey returns whether any key of m starts with prefix
+func hasPrefixedKey(m map[string]interface{}, prefix string) bool {
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+func TestSubsetDifferAnnotateManaged(t *testing.T) {
+	helm := configMap("helm", "default", map[string]interface{}{"foo": "old"})
+	helm.Metadata()["labels"] = map[string]interface{}{LabelManagedBy: "Helm"}
+	helm.Metadata()["annotations"] = map[string]interface{}{"meta.helm.sh/release-name": "grafana"}
+	free := configMap("free", "default", map[string]interface{}{"foo": "old"})
+
+	c := newFakeClient(helm, free)
+	state := manifest.List{
+		configMap("helm", "default", map[string]interface{}{"foo": "new"}),
+		configMap("free", "default", map[string]interface{}{"foo": "new"}),
+	}
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{AnnotateManaged: true})(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Contains(t, *diff, "# v1.ConfigMap.default.helm: (managed by Helm)\n")
+	assert.NotContains(t, *diff, "# v1.ConfigMap.default.free")
+
+	diff, err = SubsetDiffer(c, SubsetDiffOpts{})(state)
+	require.NoError(t, err)
+	assert.NotContains(t, *diff, "managed by")
+}
+
+func TestManagedBy(t *testing.T) {
+	withMeta := func(labels, annotations map[string]interface{}) manifest.Manifest {
+		m := configMap("config", "default", nil)
+		if labels != nil {
+			m.Metadata()["labels"] = labels
+		}
+		if annotations != nil {
+			m.Metadata()["annotations"] = annotations
+		}
+		return m
+	}
+	env := func(name string) map[string]interface{} {
+		return map[string]interface{}{process.LabelEnvironment: name}
+	}
+
+	cases := []struct {
+		name        string
+		local, live manifest.Manifest
+		want        string
+	}{
+		{name: "none", local: withMeta(nil, nil), live: withMeta(nil, nil)},
+		{
+			name:  "managed-by",
+			local: withMeta(nil, nil),
+			live:  withMeta(map[string]interface{}{LabelManagedBy: "Helm"}, nil),
+			want:  "Helm",
+		},
+		{
+			name:  "tanka",
+			local: withMeta(nil, nil),
+			live:  withMeta(map[string]interface{}{LabelManagedBy: "tanka"}, nil),
+		},
+		{
+			name:  "argocd",
+			local: withMeta(nil, nil),
+			live:  withMeta(nil, map[string]interface{}{"argocd.argoproj.io/tracking-id": "app:/ConfigMap:default/config"}),
+			want:  "ArgoCD",
+		},
+		{
+			name:  "flux",
+			local: withMeta(nil, nil),
+			live:  withMeta(map[string]interface{}{"kustomize.toolkit.fluxcd.io/name": "apps"}, nil),
+			want:  "Flux",
+		},
+		{name: "same environment", local: withMeta(env("prod"), nil), live: withMeta(env("prod"), nil)},
+		{
+			name:  "other environment",
+			local: withMeta(env("prod"), nil),
+			live:  withMeta(env("dev"), nil),
+			want:  "Tanka environment dev",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, managedBy(c.local, c.live))
+		})
+	}
+}
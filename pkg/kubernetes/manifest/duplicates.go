@@ -0,0 +1,74 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ErrorDuplicateKey means that an object of a JSON document has the same key
+// more than once. JSON parsers silently keep only one of the values, which
+// leads to confusing diffs.
+type ErrorDuplicateKey struct {
+	// Path of the duplicate key, e.g. `metadata.labels.app`
+	Path string
+	// Line the second occurrence of the key ends on
+	Line int
+}
+
+func (e ErrorDuplicateKey) Error() string {
+	return fmt.Sprintf("duplicate key '%s' in line %d", e.Path, e.Line)
+}
+
+// CheckDuplicateKeys returns an ErrorDuplicateKey for the first key that
+// occurs more than once in the same object of the JSON document data
+func CheckDuplicateKeys(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return checkDuplicates(d, data, "")
+}
+
+func checkDuplicates(d *json.Decoder, data []byte, path string) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		seen := make(map[string]bool)
+		for d.More() {
+			tok, err := d.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if seen[key] {
+				line := bytes.Count(data[:d.InputOffset()], []byte("\n")) + 1
+				return ErrorDuplicateKey{Path: child, Line: line}
+			}
+			seen[key] = true
+
+			if err := checkDuplicates(d, data, child); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; d.More(); i++ {
+			if err := checkDuplicates(d, data, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	// closing delimiter
+	_, err = d.Token()
+	return err
+}
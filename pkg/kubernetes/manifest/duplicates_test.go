@@ -0,0 +1,42 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDuplicateKeys(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{
+			name: "valid",
+			data: `{"metadata": {"name": "a", "labels": {"name": "a"}}, "data": [{"name": "a"}, {"name": "b"}]}`,
+		},
+		{
+			name: "root",
+			data: "{\n  \"kind\": \"ConfigMap\",\n  \"kind\": \"Secret\"\n}",
+			err:  ErrorDuplicateKey{Path: "kind", Line: 3},
+		},
+		{
+			name: "nested",
+			data: "{\"metadata\": {\n  \"labels\": {\"app\": \"a\", \"app\": \"b\"}}}",
+			err:  ErrorDuplicateKey{Path: "metadata.labels.app", Line: 2},
+		},
+		{
+			name: "list",
+			data: "[{\"a\": 1}, {\"b\": [{\"c\": 1, \"c\": 1}]}]",
+			err:  ErrorDuplicateKey{Path: "[1].b[0].c", Line: 1},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckDuplicateKeys([]byte(c.data))
+			assert.Equal(t, c.err, err)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Encoder serializes manifests to YAML deterministically: the output only
+// depends on the contents, but never on map ordering, the locale or the Go
+// version. It is used to prepare manifests for diffing.
+type Encoder struct {
+	// Less orders map keys. Defaults to byte-wise ordering
+	Less func(a, b string) bool
+	// KeyOrder lists keys to be rendered first, in this order, at every level
+	// of nesting (e.g. `apiVersion`, `kind`, `metadata`). The remaining keys
+	// follow, ordered using Less
+	KeyOrder []string
+
+	// QuoteMultiline renders multiline strings as double-quoted scalars instead
+	// of literal blocks
+	QuoteMultiline bool
+
+	// FloatFormat is the strconv.FormatFloat format used for non-integral
+	// numbers. Defaults to 'g'. Integral numbers are always rendered as such.
+	FloatFormat byte
+
+	// astral enables substituting characters outside of the Basic Multilingual
+	// Plane (emoji, ...), which the YAML emitter would escape otherwise
+	astral bool
+}
+
+// Marshal returns the YAML representation of m
+func (e Encoder) Marshal(m map[string]interface{}) (string, error) {
+	// the substitutes must not occur in the input, or they could not be told
+	// apart when restoring
+	e.astral = !containsSubstitutes(m)
+
+	node, err := e.node(m)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	if e.astral {
+		return restoreAstral(buf.String()), nil
+	}
+	return buf.String(), nil
+}
+
+func (e Encoder) node(v interface{}) (*yaml.Node, error) {
+	switch t := v.(type) {
+	case nil:
+		return scalar("!!null", "null"), nil
+	case bool:
+		return scalar("!!bool", strconv.FormatBool(t)), nil
+	case string:
+		return e.str(t), nil
+	case int:
+		return scalar("!!int", strconv.Itoa(t)), nil
+	case int64:
+		return scalar("!!int", strconv.FormatInt(t, 10)), nil
+	case float64:
+		return e.float(t), nil
+	case map[string]interface{}:
+		return e.mapping(t)
+	case []interface{}:
+		return e.sequence(t)
+	case Manifest:
+		return e.mapping(t)
+	case Metadata:
+		return e.mapping(t)
+	}
+
+	// anything else (typed maps, slices, other numbers) is converted to its
+	// plain JSON form first
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encoding value of type %T: %w", v, err)
+	}
+	var plain interface{}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return nil, err
+	}
+	return e.node(plain)
+}
+
+func (e Encoder) mapping(m map[string]interface{}) (*yaml.Node, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	less := e.Less
+	if less == nil {
+		less = func(a, b string) bool { return a < b }
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if ri, rj := e.rank(keys[i]), e.rank(keys[j]); ri != rj {
+			return ri < rj
+		}
+		return less(keys[i], keys[j])
+	})
+
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, k := range keys {
+		val, err := e.node(m[k])
+		if err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, e.str(k), val)
+	}
+	return node, nil
+}
+
+// rank returns the position of k in KeyOrder, or len(KeyOrder) if absent
+func (e Encoder) rank(k string) int {
+	for i, o := range e.KeyOrder {
+		if o == k {
+			return i
+		}
+	}
+	return len(e.KeyOrder)
+}
+
+func (e Encoder) sequence(s []interface{}) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, v := range s {
+		val, err := e.node(v)
+		if err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, val)
+	}
+	return node, nil
+}
+
+func (e Encoder) str(s string) *yaml.Node {
+	if e.astral {
+		s = substituteAstral(s)
+	}
+
+	node := scalar("!!str", s)
+	switch {
+	case strings.Contains(s, "\n"):
+		node.Style = yaml.LiteralStyle
+		if e.QuoteMultiline {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	case yaml11Bools[s]:
+		// YAML 1.1 parsers (like kubectl's) would read these as booleans
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	return node
+}
+
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true,
+	"off": true, "Off": true, "OFF": true,
+}
+
+// maxExactInt is the largest integer a float64 can represent exactly
+const maxExactInt = 1 << 53
+
+func (e Encoder) float(f float64) *yaml.Node {
+	// JSON numbers are always float64. Render integral ones as integers,
+	// instead of exponent notation (1e+06)
+	if f == math.Trunc(f) && math.Abs(f) <= maxExactInt {
+		return scalar("!!int", strconv.FormatInt(int64(f), 10))
+	}
+
+	format := e.FloatFormat
+	if format == 0 {
+		format = 'g'
+	}
+	return scalar("!!float", strconv.FormatFloat(f, format, -1, 64))
+}
+
+func scalar(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+// The YAML emitter escapes all characters outside of the Basic Multilingual
+// Plane (e.g. "\U0001F680" instead of 🚀), making them unreadable in diffs.
+// Before emitting, they are substituted by a pair of private use characters,
+// akin to UTF-16 surrogates, which are restored afterwards.
+const (
+	substHigh = 0xE000 // + upper 10 bits
+	substLow  = 0xE400 // + lower 10 bits
+	substEnd  = 0xE800
+)
+
+func substituteAstral(s string) string {
+	if strings.IndexFunc(s, func(r rune) bool { return r > 0xFFFF }) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		if r <= 0xFFFF {
+			b.WriteRune(r)
+			continue
+		}
+		r -= 0x10000
+		b.WriteRune(substHigh + (r >> 10))
+		b.WriteRune(substLow + (r & 0x3FF))
+	}
+	return b.String()
+}
+
+func restoreAstral(s string) string {
+	if strings.IndexFunc(s, isSubstitute) < 0 {
+		return s
+	}
+
+	runes := []rune(s)
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r >= substHigh && r < substLow && i+1 < len(runes) && runes[i+1] >= substLow && runes[i+1] < substEnd {
+			b.WriteRune(0x10000 + (r-substHigh)<<10 + (runes[i+1] - substLow))
+			i++
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isSubstitute(r rune) bool {
+	return r >= substHigh && r < substEnd
+}
+
+// containsSubstitutes reports whether any string of v contains characters
+// used by substituteAstral
+func containsSubstitutes(v interface{}) bool {
+	switch t := v.(type) {
+	case string:
+		return strings.IndexFunc(t, isSubstitute) >= 0
+	case map[string]interface{}:
+		for k, v := range t {
+			if containsSubstitutes(k) || containsSubstitutes(v) {
+				return true
+			}
+		}
+	case Manifest:
+		return containsSubstitutes(map[string]interface{}(t))
+	case Metadata:
+		return containsSubstitutes(map[string]interface{}(t))
+	case []interface{}:
+		for _, v := range t {
+			if containsSubstitutes(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
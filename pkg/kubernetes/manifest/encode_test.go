@@ -0,0 +1,146 @@
+package manifest
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestEncoderGolden asserts the Encoder output is byte-stable across runs and
+// matches the golden files in testdata/encode
+func TestEncoderGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/encode/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(file)
+			require.NoError(t, err)
+
+			golden := strings.TrimSuffix(file, ".json") + ".golden.yaml"
+			if *update {
+				out := encodeJSON(t, data)
+				require.NoError(t, ioutil.WriteFile(golden, []byte(out), 0644))
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			require.NoError(t, err)
+
+			// fresh maps each run, so the map iteration order differs
+			for i := 0; i < 20; i++ {
+				require.Equal(t, string(want), encodeJSON(t, data))
+			}
+		})
+	}
+}
+
+func encodeJSON(t *testing.T, data []byte) string {
+	var m Manifest
+	require.NoError(t, json.Unmarshal(data, &m))
+
+	out, err := Encoder{}.Marshal(m)
+	require.NoError(t, err)
+	return out
+}
+
+func TestEncoderOpts(t *testing.T) {
+	m := map[string]interface{}{
+		"b":      "multi\nline\n",
+		"a":      float64(1) / 3,
+		"typed":  map[string]string{"y": "1", "x": "2"},
+		"number": float64(1e6),
+	}
+
+	cases := []struct {
+		name string
+		enc  Encoder
+		want string
+	}{
+		{
+			name: "default",
+			want: `a: 0.3333333333333333
+b: |
+  multi
+  line
+number: 1000000
+typed:
+  x: "2"
+  "y": "1"
+`,
+		},
+		{
+			name: "custom",
+			enc: Encoder{
+				Less:           func(a, b string) bool { return a > b },
+				QuoteMultiline: true,
+				FloatFormat:    'e',
+			},
+			want: `typed:
+  "y": "1"
+  x: "2"
+number: 1000000
+b: "multi\nline\n"
+a: 3.333333333333333e-01
+`,
+		},
+		{
+			name: "keyOrder",
+			enc:  Encoder{KeyOrder: []string{"typed", "y", "number"}},
+			want: `typed:
+  "y": "1"
+  x: "2"
+number: 1000000
+a: 0.3333333333333333
+b: |
+  multi
+  line
+`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.enc.Marshal(m)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestEncoderAstral(t *testing.T) {
+	cases := []struct {
+		name string
+		m    map[string]interface{}
+		want string
+	}{
+		{
+			name: "emoji",
+			m:    map[string]interface{}{"rocket": "🚀 launch", "multi": "🛸\nufo\n"},
+			want: "multi: |\n  🛸\n  ufo\nrocket: 🚀 launch\n",
+		},
+		{
+			// input already using the substitutes falls back to escaping
+			name: "collision",
+			m:    map[string]interface{}{"a": "\ue000", "b": "🚀"},
+			want: "a: \ue000\nb: \"\\U0001F680\"\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Encoder{}.Marshal(c.m)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
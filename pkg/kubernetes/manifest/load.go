@@ -0,0 +1,121 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// exportIndex is written by `tk export` to map files to their environment. It
+// is not a manifest itself
+const exportIndex = "manifest.json"
+
+// LoadDir reads all manifests from the YAML and JSON files (.yaml, .yml,
+// .json) of dir and its subdirectories, e.g. the output of `tk export`. Files
+// may hold multiple documents, empty ones are skipped. Manifests are returned
+// in lexical order of their files.
+func LoadDir(dir string) (List, error) {
+	var list List
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Join(dir, exportIndex) == path {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		ms, err := loadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "loading %s", path)
+		}
+		list = append(list, ms...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// LoadExport is like LoadDir, but if dir holds the index written by `tk
+// export`, only the files of the given environment (its
+// `metadata.namespace`) are loaded. This allows to use the output of
+// exporting multiple environments at once.
+func LoadExport(dir, env string) (List, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, exportIndex))
+	if os.IsNotExist(err) {
+		return LoadDir(dir)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var index map[string]string
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", exportIndex)
+	}
+
+	var files []string
+	for file, e := range index {
+		if e == env {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s lists no files of environment '%s'", filepath.Join(dir, exportIndex), env)
+	}
+	sort.Strings(files)
+
+	var list List
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		ms, err := loadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading %s", path)
+		}
+		list = append(list, ms...)
+	}
+	return list, nil
+}
+
+// loadFile returns all manifests of the YAML stream at path
+func loadFile(path string) (List, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list List
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw map[string]interface{}
+		if err := d.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		m, err := New(raw)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDir(t *testing.T) {
+	list, err := LoadDir("testdata/rendered")
+	require.NoError(t, err)
+
+	var got []string
+	for _, m := range list {
+		got = append(got, m.KindName())
+	}
+	assert.Equal(t, []string{
+		"Deployment/grafana",
+		"ConfigMap/grafana-config",
+		"ConfigMap/grafana-dashboards",
+	}, got)
+	assert.Equal(t, "monitoring", list[0].Metadata().Namespace())
+}
+
+func TestLoadExport(t *testing.T) {
+	list, err := LoadExport("testdata/multi", "environments/logging/main.jsonnet")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "ConfigMap/loki-config", list[0].KindName())
+
+	// without index, all files are loaded
+	list, err = LoadExport("testdata/multi/monitoring", "environments/logging/main.jsonnet")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "ConfigMap/grafana-config", list[0].KindName())
+
+	_, err = LoadExport("testdata/multi", "environments/unknown/main.jsonnet")
+	assert.EqualError(t, err, "testdata/multi/manifest.json lists no files of environment 'environments/unknown/main.jsonnet'")
+}
+
+func TestLoadDirInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rendered")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "broken.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("metadata:\n  name: foo\n"), 0644))
+
+	_, err = LoadDir(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "loading "+path)
+	assert.IsType(t, &SchemaError{}, errors.Cause(err))
+}
@@ -30,7 +30,9 @@ func NewFromObj(raw objx.Map) (Manifest, error) {
 	return New(map[string]interface{}(raw))
 }
 
-// String returns the Manifest in yaml representation
+// String returns the Manifest in yaml representation. Map keys are always
+// sorted, so maps with equal contents (e.g. labels) serialize identically,
+// regardless of their type or insertion order.
 func (m Manifest) String() string {
 	y, err := yaml.Marshal(m)
 	if err != nil {
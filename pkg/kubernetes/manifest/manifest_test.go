@@ -91,6 +91,39 @@ spec:
 	}
 }
 
+// TestStringSortedKeys asserts that String() sorts map keys, so labels and
+// selectors never cause churn in diffs, regardless of how the maps were built.
+func TestStringSortedKeys(t *testing.T) {
+	keys := []string{"app", "component", "tier", "version"}
+
+	forward := make(map[string]interface{})
+	for _, k := range keys {
+		forward[k] = k + "-value"
+	}
+	backward := make(map[string]string)
+	for i := len(keys) - 1; i >= 0; i-- {
+		backward[keys[i]] = keys[i] + "-value"
+	}
+
+	a := Manifest(deployment("foo"))
+	a.Metadata()["labels"] = forward
+	a["spec"] = map[string]interface{}{"selector": map[string]interface{}{"matchLabels": forward}}
+
+	b := Manifest(deployment("foo"))
+	b.Metadata()["labels"] = backward
+	b["spec"] = map[string]interface{}{"selector": map[string]interface{}{"matchLabels": backward}}
+
+	require.Equal(t, a.String(), b.String())
+
+	want := `  labels:
+    app: app-value
+    component: component-value
+    tier: tier-value
+    version: version-value
+`
+	require.Contains(t, a.String(), want)
+}
+
 func TestListAsMap(t *testing.T) {
 	cases := []struct {
 		name       string
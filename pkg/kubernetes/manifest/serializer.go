@@ -0,0 +1,30 @@
+package manifest
+
+import "encoding/json"
+
+// Serializer renders manifests as text to be diffed. Implementations must be
+// deterministic, or the diff would show spurious changes. Encoder (YAML) is
+// the default, JSONEncoder renders JSON.
+type Serializer interface {
+	Marshal(m map[string]interface{}) (string, error)
+}
+
+// JSONEncoder serializes manifests to indented JSON. Map keys are sorted.
+type JSONEncoder struct {
+	// Indent used for nested values. Defaults to two spaces
+	Indent string
+}
+
+// Marshal returns the JSON representation of m
+func (e JSONEncoder) Marshal(m map[string]interface{}) (string, error) {
+	indent := e.Indent
+	if indent == "" {
+		indent = "  "
+	}
+
+	data, err := json.MarshalIndent(m, "", indent)
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
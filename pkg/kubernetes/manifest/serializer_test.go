@@ -0,0 +1,37 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	m := map[string]interface{}{
+		"kind":     "ConfigMap",
+		"metadata": map[string]interface{}{"name": "foo"},
+		"data":     map[string]interface{}{"b": "2", "a": "1"},
+	}
+
+	got, err := JSONEncoder{}.Marshal(m)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "data": {
+    "a": "1",
+    "b": "2"
+  },
+  "kind": "ConfigMap",
+  "metadata": {
+    "name": "foo"
+  }
+}
+`, got)
+
+	got, err = JSONEncoder{Indent: "\t"}.Marshal(map[string]interface{}{"a": []interface{}{1}})
+	require.NoError(t, err)
+	assert.Equal(t, "{\n\t\"a\": [\n\t\t1\n\t]\n}\n", got)
+}
+
+// both built-in serializers satisfy the interface
+var _ = []Serializer{Encoder{}, JSONEncoder{}}
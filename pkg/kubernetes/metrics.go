@@ -0,0 +1,140 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the metrics recorded by the diff, if SubsetDiffOpts.Metrics is set
+const (
+	// MetricObjects counts the compared objects
+	MetricObjects = "objects"
+	// MetricChanged counts the objects having differences
+	MetricChanged = "changed"
+	// MetricDiffBytes is the total size of the differences
+	MetricDiffBytes = "diff_bytes"
+	// MetricFetch times retrieving the live state of each object
+	MetricFetch = "fetch"
+	// MetricCompare times comparing each object
+	MetricCompare = "compare"
+)
+
+// DiffMetrics accumulates counters and timings of a diff, as recorded by
+// concurrent workers. The zero value is ready to use. A DiffMetrics must not be
+// copied after first use.
+type DiffMetrics struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	timings  map[string]Timing
+}
+
+// Timing summarizes the observed durations of an operation
+type Timing struct {
+	Count      int
+	Total, Max time.Duration
+}
+
+// Mean returns the average duration
+func (t Timing) Mean() time.Duration {
+	if t.Count == 0 {
+		return 0
+	}
+	return t.Total / time.Duration(t.Count)
+}
+
+// Add increments the counter name by n
+func (m *DiffMetrics) Add(name string, n int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.counters == nil {
+		m.counters = make(map[string]int64)
+	}
+	m.counters[name] += n
+}
+
+// Observe records a single duration of the operation name
+func (m *DiffMetrics) Observe(name string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.timings == nil {
+		m.timings = make(map[string]Timing)
+	}
+	t := m.timings[name]
+	t.Count++
+	t.Total += d
+	if d > t.Max {
+		t.Max = d
+	}
+	m.timings[name] = t
+}
+
+// Since records the duration since start for the operation name, e.g.
+// `defer m.Since(MetricFetch, time.Now())`
+func (m *DiffMetrics) Since(name string, start time.Time) {
+	m.Observe(name, time.Since(start))
+}
+
+// MetricsSummary is a snapshot of DiffMetrics
+type MetricsSummary struct {
+	Counters map[string]int64
+	Timings  map[string]Timing
+}
+
+// Summary returns a copy of the current state, that is not affected by
+// further updates
+func (m *DiffMetrics) Summary() MetricsSummary {
+	s := MetricsSummary{
+		Counters: make(map[string]int64),
+		Timings:  make(map[string]Timing),
+	}
+	if m == nil {
+		return s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.counters {
+		s.Counters[k] = v
+	}
+	for k, v := range m.timings {
+		s.Timings[k] = v
+	}
+	return s
+}
+
+// String renders all counters, then all timings, one per line, each sorted by
+// name
+func (s MetricsSummary) String() string {
+	var b strings.Builder
+
+	counters := make([]string, 0, len(s.Counters))
+	for k := range s.Counters {
+		counters = append(counters, k)
+	}
+	sort.Strings(counters)
+	for _, k := range counters {
+		fmt.Fprintf(&b, "%s: %d\n", k, s.Counters[k])
+	}
+
+	names := make([]string, 0, len(s.Timings))
+	for k := range s.Timings {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		t := s.Timings[k]
+		fmt.Fprintf(&b, "%s: %d in %s (mean %s, max %s)\n", k, t.Count, t.Total, t.Mean(), t.Max)
+	}
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// Meant to be run using `go test -race`
+func TestDiffMetricsConcurrent(t *testing.T) {
+	var m DiffMetrics
+
+	const workers, updates = 20, 100
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				m.Add(MetricObjects, 1)
+				m.Observe(MetricFetch, time.Duration(i+1)*time.Millisecond)
+				_ = m.Summary()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	s := m.Summary()
+	assert.Equal(t, int64(workers*updates), s.Counters[MetricObjects])
+
+	fetch := s.Timings[MetricFetch]
+	assert.Equal(t, workers*updates, fetch.Count)
+	assert.Equal(t, workers*time.Millisecond, fetch.Max)
+	assert.Equal(t, time.Duration(updates*workers*(workers+1)/2)*time.Millisecond, fetch.Total)
+
+	// the summary is a snapshot
+	m.Add(MetricObjects, 1)
+	assert.Equal(t, int64(workers*updates), s.Counters[MetricObjects])
+}
+
+func TestDiffMetricsSummary(t *testing.T) {
+	var m DiffMetrics
+	m.Add(MetricObjects, 3)
+	m.Add(MetricChanged, 1)
+	m.Observe(MetricFetch, 100*time.Millisecond)
+	m.Observe(MetricFetch, 300*time.Millisecond)
+
+	assert.Equal(t, `changed: 1
+objects: 3
+fetch: 2 in 400ms (mean 200ms, max 300ms)
+`, m.Summary().String())
+
+	// nil disables recording
+	var disabled *DiffMetrics
+	disabled.Add(MetricObjects, 1)
+	disabled.Observe(MetricFetch, time.Second)
+	assert.Empty(t, disabled.Summary().Counters)
+}
+
+func TestSubsetDifferMetrics(t *testing.T) {
+	c := newFakeClient(
+		configMap("a", "default", map[string]interface{}{"foo": "old"}),
+		configMap("b", "default", map[string]interface{}{"foo": "bar"}),
+	)
+
+	var m DiffMetrics
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{Metrics: &m})(manifest.List{
+		configMap("a", "default", map[string]interface{}{"foo": "new"}),
+		configMap("b", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("c", "default", nil),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+
+	s := m.Summary()
+	assert.Equal(t, int64(3), s.Counters[MetricObjects])
+	assert.Equal(t, int64(2), s.Counters[MetricChanged])
+	// the rendered diff additionally separates the objects
+	assert.Equal(t, int64(len(*diff)-1), s.Counters[MetricDiffBytes])
+	assert.Equal(t, 3, s.Timings[MetricFetch].Count)
+	assert.Equal(t, 3, s.Timings[MetricCompare].Count)
+}
@@ -0,0 +1,96 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// Limits of Kubernetes object names, see
+// https://kubernetes.io/docs/concepts/overview/working-with-objects/names/
+const (
+	maxNameLength      = 253
+	maxNamespaceLength = 63
+)
+
+var (
+	// dns1123Subdomain is the format of the names of most kinds
+	dns1123Subdomain = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	// dns1123Label is the format of namespaces
+	dns1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// pathSegmentKinds only require their names to be usable in an URL path,
+// allowing names like `system:controller:job-controller`
+var pathSegmentKinds = map[string]bool{
+	"Role":               true,
+	"ClusterRole":        true,
+	"RoleBinding":        true,
+	"ClusterRoleBinding": true,
+}
+
+// ErrorInvalidName occurs when an object of the desired state has a name or
+// namespace the API server would reject. Usually caused by a template bug.
+type ErrorInvalidName struct {
+	Ref ObjectRef
+	// Field is either metadata.name or metadata.namespace
+	Field  string
+	Reason string
+}
+
+func (e ErrorInvalidName) Error() string {
+	return fmt.Sprintf("%s has an invalid %s: %s", e.Ref, e.Field, e.Reason)
+}
+
+// validateNames checks the names and namespaces of all objects of state
+// against the naming rules of Kubernetes, so they fail early and clearly,
+// instead of when getting them from the cluster
+func validateNames(state manifest.List) error {
+	for _, m := range state {
+		// generated names are only known once created
+		if name := m.Metadata().Name(); name != "" {
+			if reason := invalidName(m.Kind(), name); reason != "" {
+				return ErrorInvalidName{Ref: RefOf(m), Field: "metadata.name", Reason: reason}
+			}
+		}
+		if ns := m.Metadata().Namespace(); ns != "" {
+			if reason := invalidNamespace(ns); reason != "" {
+				return ErrorInvalidName{Ref: RefOf(m), Field: "metadata.namespace", Reason: reason}
+			}
+		}
+	}
+	return nil
+}
+
+// invalidName returns why name is not a valid name for objects of kind, or
+// an empty string if it is
+func invalidName(kind, name string) string {
+	switch {
+	case len(name) > maxNameLength:
+		return fmt.Sprintf("must be no more than %d characters, but has %d", maxNameLength, len(name))
+	case pathSegmentKinds[kind]:
+		if name == "." || name == ".." {
+			return fmt.Sprintf("may not be '%s'", name)
+		}
+		if strings.ContainsAny(name, "/%") {
+			return "may not contain '/' or '%'"
+		}
+	case !dns1123Subdomain.MatchString(name):
+		return "must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character"
+	}
+	return ""
+}
+
+// invalidNamespace returns why ns is not a valid namespace, or an empty
+// string if it is
+func invalidNamespace(ns string) string {
+	switch {
+	case len(ns) > maxNamespaceLength:
+		return fmt.Sprintf("must be no more than %d characters, but has %d", maxNamespaceLength, len(ns))
+	case !dns1123Label.MatchString(ns):
+		return "must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character"
+	}
+	return ""
+}
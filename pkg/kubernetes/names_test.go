@@ -0,0 +1,72 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestValidateNames(t *testing.T) {
+	role := clusterRole()
+	role.Metadata()["name"] = "system:controller:job-controller"
+
+	generated := configMap("", "default", nil)
+	generated.Metadata()["generateName"] = "config-"
+
+	cases := []struct {
+		name string
+		m    manifest.Manifest
+		err  string
+	}{
+		{name: "valid", m: configMap("grafana.config-1", "default", nil)},
+		{name: "rbac", m: role},
+		{name: "generated", m: generated},
+		{
+			name: "too-long",
+			m:    configMap(strings.Repeat("a", 254), "default", nil),
+			err:  "metadata.name: must be no more than 253 characters, but has 254",
+		},
+		{
+			name: "invalid-character",
+			m:    configMap("grafana_config", "default", nil),
+			err:  "v1/ConfigMap/default/grafana_config has an invalid metadata.name: must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character",
+		},
+		{
+			name: "uppercase",
+			m:    configMap("Grafana", "default", nil),
+			err:  "must consist of lower case alphanumeric characters",
+		},
+		{
+			name: "namespace",
+			m:    configMap("config", "my.namespace", nil),
+			err:  "v1/ConfigMap/my.namespace/config has an invalid metadata.namespace: must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateNames(manifest.List{c.m})
+			if c.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.IsType(t, ErrorInvalidName{}, err)
+			assert.Contains(t, err.Error(), c.err)
+		})
+	}
+}
+
+func TestSubsetDifferInvalidName(t *testing.T) {
+	c := newFakeClient()
+
+	_, err := SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{configMap("grafana_config", "default", nil)})
+	assert.IsType(t, ErrorInvalidName{}, err)
+
+	// the cluster is not contacted at all
+	assert.Empty(t, c.Calls())
+}
@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Action returns what applying the desired state does to the object of e
+func (e DiffEntry) Action() PlanAction {
+	switch {
+	case e.Unknown:
+		return PlanUnknown
+	case e.Unmodified:
+		return PlanUnchanged
+	case e.Prune, e.Merged == "" && e.Live != "":
+		return PlanPrune
+	case e.Live == "":
+		return PlanCreate
+	case e.Diff != "":
+		return PlanUpdate
+	}
+	return PlanUnchanged
+}
+
+// NDJSONEncoder writes DiffEntries as newline-delimited JSON, one object per
+// line. Each entry is written as soon as it is encoded, so consumers can
+// process the diff as a stream.
+type NDJSONEncoder struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONEncoder returns a NDJSONEncoder writing to w
+func NewNDJSONEncoder(w io.Writer) *NDJSONEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &NDJSONEncoder{enc: enc}
+}
+
+// ndjsonEntry is a single line written by NDJSONEncoder
+type ndjsonEntry struct {
+	Ref    ndjsonRef  `json:"ref"`
+	Action PlanAction `json:"action"`
+	// Patch holds the differences in `diff(1)` format. Empty if there are none
+	Patch string   `json:"patch"`
+	Notes []string `json:"notes,omitempty"`
+}
+
+type ndjsonRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// Encode writes e as a single line
+func (n *NDJSONEncoder) Encode(e DiffEntry) error {
+	return n.enc.Encode(ndjsonEntry{
+		Ref: ndjsonRef{
+			APIVersion: e.Ref.APIVersion(),
+			Kind:       e.Ref.Kind,
+			Namespace:  e.Ref.Namespace,
+			Name:       e.Ref.Name,
+		},
+		Action: e.Action(),
+		Patch:  e.Diff,
+		Notes:  e.Notes,
+	})
+}
+
+// WriteNDJSON writes all entries of r to w, one line per entry
+func (r DiffResult) WriteNDJSON(w io.Writer) error {
+	enc := NewNDJSONEncoder(w)
+	for _, e := range r.Entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestWriteNDJSON(t *testing.T) {
+	a := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "old"}),
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("removed", "default", nil),
+	}
+	b := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "new"}),
+		configMap("same", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("created", "default", nil),
+	}
+
+	result, err := DiffRenders(a, b, SubsetDiffOpts{})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, result.WriteNDJSON(&buf))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+
+	type line struct {
+		Ref struct {
+			APIVersion, Kind, Namespace, Name string
+		}
+		Action string
+		Patch  string
+	}
+	got := make([]line, len(lines))
+	for i, l := range lines {
+		require.NoError(t, json.Unmarshal([]byte(l), &got[i]), l)
+		assert.Equal(t, "v1", got[i].Ref.APIVersion)
+		assert.Equal(t, "ConfigMap", got[i].Ref.Kind)
+		assert.Equal(t, "default", got[i].Ref.Namespace)
+	}
+
+	actions := map[string]string{}
+	for _, l := range got {
+		actions[l.Ref.Name] = l.Action
+	}
+	assert.Equal(t, map[string]string{
+		"changed": "update",
+		"same":    "unchanged",
+		"created": "create",
+		"removed": "prune",
+	}, actions)
+
+	assert.Contains(t, got[0].Patch, "+  foo: new")
+	assert.Empty(t, got[1].Patch)
+}
+
+func TestNDJSONEncoderClusterWide(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewNDJSONEncoder(&buf)
+	require.NoError(t, enc.Encode(DiffEntry{
+		Ref:    ObjectRef{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "view"},
+		Live:   "live",
+		Merged: "merged",
+		Diff:   "<diff>",
+	}))
+
+	assert.Equal(t, `{"ref":{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole","name":"view"},"action":"update","patch":"<diff>"}`+"\n", buf.String())
+}
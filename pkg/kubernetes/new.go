@@ -0,0 +1,26 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// NewObjects returns the objects of state that do not exist in the cluster,
+// i.e. the ones an apply would create. Unlike a diff, this takes only a single
+// request.
+func NewObjects(c client.Client, state manifest.List) (manifest.List, error) {
+	existing, err := resourceVersions(c, state, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding existing objects")
+	}
+
+	var created manifest.List
+	for _, m := range state {
+		if _, ok := existing[objectKey(m)]; !ok {
+			created = append(created, m)
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,58 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/spec/v1alpha1"
+)
+
+func TestNewObjects(t *testing.T) {
+	c := newFakeClient(configMap("existing", "default", map[string]interface{}{"foo": "old"}))
+
+	state := manifest.List{
+		configMap("existing", "default", map[string]interface{}{"foo": "new"}),
+		configMap("created", "default", nil),
+		configMap("existing", "other", nil),
+	}
+
+	created, err := NewObjects(c, state)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.List{state[1], state[2]}, created)
+
+	// a single request, no individual gets
+	assert.Equal(t, []string{"getByState 3 map[]"}, c.Calls())
+}
+
+func TestDiffOnlyNew(t *testing.T) {
+	c := newFakeClient(
+		configMap("existing", "default", map[string]interface{}{"foo": "old"}),
+		manifest.Manifest{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "default"}},
+	)
+	c.info = client.Info{ClientVersion: semver.MustParse("1.20.0"), ServerVersion: semver.MustParse("1.20.0")}
+	c.resources = client.Resources{{Kind: "ConfigMap", Namespaced: true, Verbs: "[get list]"}}
+
+	env := v1alpha1.New()
+	env.Spec.DiffStrategy = "subset"
+	k := newKubernetes(*env, c)
+
+	state := manifest.List{
+		configMap("existing", "default", map[string]interface{}{"foo": "new"}),
+		configMap("created", "default", map[string]interface{}{"foo": "bar"}),
+	}
+
+	d, err := k.Diff(state, DiffOpts{OnlyNew: true})
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Contains(t, *d, "v1.ConfigMap.default.created")
+	assert.Contains(t, *d, "+  foo: bar")
+	assert.NotContains(t, *d, "existing")
+
+	// existing objects are not fetched individually
+	assert.Empty(t, c.Gets())
+}
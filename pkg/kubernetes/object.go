@@ -0,0 +1,55 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// DiffObject compares the object of the desired state referenced by ref to the
+// cluster, without fetching any other object. Useful for targeted checks of
+// large environments. The state must be rendered already, see
+// tanka.DiffObject for evaluating only what is needed. Pruning does not apply
+// to single objects, so opts.PruneSelector is ignored.
+func DiffObject(c client.Client, state manifest.List, ref ObjectRef, opts SubsetDiffOpts) (*DiffEntry, error) {
+	var m manifest.Manifest
+	for _, s := range state {
+		if ref.Matches(s) {
+			m = s
+			break
+		}
+	}
+	if m == nil {
+		return nil, ErrorObjectNotFound{Ref: ref}
+	}
+
+	opts.PruneSelector = nil
+	result, err := diffState(c, manifest.List{m}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// e.g. because of ObjectStrategyNone
+	if len(result.Entries) == 0 {
+		return nil, ErrorNoObjects{}
+	}
+	return &result.Entries[0], nil
+}
+
+// DiffObject is like the package level DiffObject, using the client and the
+// diff options of k
+func (k *Kubernetes) DiffObject(state manifest.List, ref ObjectRef) (*DiffEntry, error) {
+	opts := k.subsetOpts
+	opts.exact = k.Env.Spec.DiffStrategy == "exact"
+	return DiffObject(k.ctl, state, ref, opts)
+}
+
+// ErrorObjectNotFound occurs when the desired state has no object matching Ref
+type ErrorObjectNotFound struct {
+	Ref ObjectRef
+}
+
+func (e ErrorObjectNotFound) Error() string {
+	return fmt.Sprintf("no object %s in the desired state", e.Ref)
+}
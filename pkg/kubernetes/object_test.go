@@ -0,0 +1,58 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/spec/v1alpha1"
+)
+
+func TestDiffObject(t *testing.T) {
+	state := loadList(t, "testdata/whatif/environment.yaml")
+
+	c := newFakeClient(loadList(t, "testdata/whatif/cluster.yaml")...)
+	c.resources = client.Resources{
+		{Kind: "Deployment", APIGroup: "apps", Namespaced: true, Verbs: "[get list]"},
+	}
+
+	ref := ObjectRef{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "monitoring", Name: "grafana"}
+	entry, err := DiffObject(c, state, ref, SubsetDiffOpts{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "apps-v1.Deployment.monitoring.grafana", entry.Name)
+	assert.Contains(t, entry.Diff, "-  replicas: 1")
+	assert.Contains(t, entry.Diff, "+  replicas: 2")
+	assert.Equal(t, "1042", entry.ResourceVersion)
+
+	// no other object is fetched
+	assert.Equal(t, []string{"get monitoring Deployment grafana"}, c.CallsWith("get"))
+
+	// the apiVersion is optional
+	ref = ObjectRef{Kind: "Namespace", Name: "monitoring"}
+	entry, err = DiffObject(c, state, ref, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.Empty(t, entry.Diff)
+
+	ref = ObjectRef{Version: "v1", Kind: "ConfigMap", Namespace: "monitoring", Name: "missing"}
+	_, err = DiffObject(c, state, ref, SubsetDiffOpts{})
+	assert.Equal(t, ErrorObjectNotFound{Ref: ref}, err)
+	assert.EqualError(t, err, "no object v1/ConfigMap/monitoring/missing in the desired state")
+}
+
+func TestKubernetesDiffObject(t *testing.T) {
+	state := loadList(t, "testdata/whatif/environment.yaml")
+	c := newFakeClient(loadList(t, "testdata/whatif/cluster.yaml")...)
+
+	env := v1alpha1.New()
+	env.Spec.DiffStrategy = "exact"
+	k := newKubernetes(*env, c)
+
+	ref := ObjectRef{Kind: "Namespace", Name: "monitoring"}
+	entry, err := k.DiffObject(state, ref)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.Namespace..monitoring", entry.Name)
+	assert.Equal(t, []string{"get  Namespace monitoring"}, c.CallsWith("get"))
+}
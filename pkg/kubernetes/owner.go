@@ -0,0 +1,38 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// ownedBy returns whether owner is listed in the ownerReferences of m
+func ownedBy(m, owner manifest.Manifest) bool {
+	refs, _ := m.Metadata()["ownerReferences"].([]interface{})
+	for _, r := range refs {
+		ref, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if ref["uid"] == owner.Metadata().UID() {
+			return true
+		}
+	}
+	return false
+}
+
+// controllerOf returns the managing controller of m in `<kind>/<name>` format,
+// as recorded in its ownerReferences. Empty if there is none.
+func controllerOf(m manifest.Manifest) string {
+	refs, _ := m.Metadata()["ownerReferences"].([]interface{})
+	for _, r := range refs {
+		ref, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if controller, _ := ref["controller"].(bool); controller {
+			return fmt.Sprintf("%s/%s", ref["kind"], ref["name"])
+		}
+	}
+	return ""
+}
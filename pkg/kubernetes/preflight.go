@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// Permission is the right to perform Verb on objects of Kind in Namespace.
+// Namespace is empty for cluster-wide access
+type Permission struct {
+	Verb, Kind, Namespace string
+}
+
+func (p Permission) String() string {
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s %s (cluster-wide)", p.Verb, p.Kind)
+	}
+	return fmt.Sprintf("%s %s in namespace %s", p.Verb, p.Kind, p.Namespace)
+}
+
+// ErrorMissingPermissions occurs when the preflight finds that the current
+// identity lacks permissions required for diffing
+type ErrorMissingPermissions struct {
+	Missing []Permission
+}
+
+func (e ErrorMissingPermissions) Error() string {
+	s := "missing permissions required for diffing:"
+	for _, p := range e.Missing {
+		s += "\n  - " + p.String()
+	}
+	return s
+}
+
+// preflight checks that objects of all kinds of state may be read from the
+// namespaces they are requested from, before any of them are fetched. All
+// missing permissions are reported at once, instead of as a single forbidden
+// error per object.
+func preflight(c client.Client, state manifest.List) error {
+	sc := newScopes(c, state)
+	seen := make(map[Permission]bool)
+	var required []Permission
+	for _, m := range state {
+		p := Permission{Verb: "get", Kind: m.Kind(), Namespace: sc.namespace(m)}
+		if !seen[p] {
+			seen[p] = true
+			required = append(required, p)
+		}
+	}
+
+	var missing []Permission
+	for _, p := range required {
+		ok, err := c.CanI(p.Verb, p.Kind, p.Namespace)
+		if err != nil {
+			return errors.Wrap(err, "preflight")
+		}
+		if !ok {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Slice(missing, func(i, j int) bool {
+		return strings.Compare(missing[i].String(), missing[j].String()) < 0
+	})
+	return ErrorMissingPermissions{Missing: missing}
+}
@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestPreflight(t *testing.T) {
+	c := newFakeClient(configMap("a", "default", nil))
+	c.denied = map[string]bool{
+		"get Secret default": true,
+		"get ConfigMap prod": true,
+		"get ClusterRole ":   true,
+	}
+
+	state := manifest.List{
+		configMap("a", "default", nil),
+		configMap("b", "default", nil),
+		secret("creds", nil),
+		secret("token", nil),
+		configMap("a", "prod", nil),
+		clusterRole(),
+	}
+
+	_, err := diffState(c, state, SubsetDiffOpts{Preflight: true})
+	require.Error(t, err)
+	assert.Equal(t, ErrorMissingPermissions{Missing: []Permission{
+		{Verb: "get", Kind: "ClusterRole"},
+		{Verb: "get", Kind: "ConfigMap", Namespace: "prod"},
+		{Verb: "get", Kind: "Secret", Namespace: "default"},
+	}}, err)
+	assert.Equal(t, `missing permissions required for diffing:
+  - get ClusterRole (cluster-wide)
+  - get ConfigMap in namespace prod
+  - get Secret in namespace default`, err.Error())
+
+	// each kind is checked once per namespace, nothing is fetched
+	assert.Len(t, c.CallsWith("can-i "), 4)
+	assert.Equal(t, 0, c.Gets())
+}
+
+func TestPreflightAllowed(t *testing.T) {
+	c := newFakeClient(configMap("a", "default", map[string]interface{}{"foo": "old"}))
+
+	result, err := diffState(c, manifest.List{configMap("a", "default", map[string]interface{}{"foo": "new"})}, SubsetDiffOpts{Preflight: true})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+	assert.Contains(t, result.Entries[0].Diff, "+  foo: new")
+	assert.Equal(t, []string{"can-i get ConfigMap default"}, c.CallsWith("can-i "))
+
+	// disabled by default
+	c = newFakeClient()
+	_, err = diffState(c, manifest.List{configMap("a", "default", nil)}, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.Empty(t, c.CallsWith("can-i "))
+}
+
+// TestPreflightScope asserts cluster-wide objects are checked cluster-wide,
+// even if a namespace was injected
+func TestPreflightScope(t *testing.T) {
+	c := newFakeClient()
+	c.resources = client.Resources{
+		{APIGroup: "example.com", Kind: "ClusterWidget", Namespaced: false},
+	}
+
+	state := manifest.List{m("example.com/v1", "ClusterWidget", "global", "default")}
+	_, err := diffState(c, state, SubsetDiffOpts{Preflight: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"can-i get ClusterWidget "}, c.CallsWith("can-i "))
+}
@@ -0,0 +1,81 @@
+package kubernetes
+
+import (
+	"sort"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// specFields are the fields kept by specOnly, besides the identity of the object
+var specFields = []string{"spec", "data", "binaryData", "stringData"}
+
+// specOnly reduces m to its spec (or data for ConfigMaps and Secrets). Of the
+// metadata only name and namespace are kept, to identify the object.
+func specOnly(m manifest.Manifest) manifest.Manifest {
+	meta := map[string]interface{}{}
+	if name, ok := m.Metadata()["name"]; ok {
+		meta["name"] = name
+	}
+	if ns, ok := m.Metadata()["namespace"]; ok {
+		meta["namespace"] = ns
+	}
+
+	out := manifest.Manifest{
+		"apiVersion": m["apiVersion"],
+		"kind":       m["kind"],
+		"metadata":   meta,
+	}
+	for _, f := range specFields {
+		if v, ok := m[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
+
+// projectedFields returns the top-level fields of the live counterpart of m
+// required for diffing it. These are the ones present in m, except for its
+// identity, which is always fetched.
+func projectedFields(m manifest.Manifest) []string {
+	fields := []string{}
+	for k := range m {
+		switch k {
+		case "apiVersion", "kind", "metadata":
+			continue
+		}
+		fields = append(fields, k)
+	}
+
+	// the cluster stores stringData of Secrets as data
+	if m.Kind() == "Secret" {
+		if _, ok := m["stringData"]; ok {
+			if _, ok := m["data"]; !ok {
+				fields = append(fields, "data")
+			}
+		}
+	}
+
+	sort.Strings(fields)
+	return fields
+}
+
+// projects returns whether only the projectedFields of the live counterpart
+// of m are to be fetched. Exact comparisons require the whole object, as they
+// report fields absent locally.
+func (opts SubsetDiffOpts) projects(m manifest.Manifest) bool {
+	if !opts.Project || opts.exact {
+		return false
+	}
+	strategy, err := objectStrategy(m, opts.KindStrategies)
+	return err == nil && strategy != ObjectStrategyExact
+}
+
+// clusterKeys returns the top-level fields of the given paths (see
+// SubsetDiffOpts.KeepClusterKeys)
+func clusterKeys(paths []string) []string {
+	var keys []string
+	for _, p := range paths {
+		keys = append(keys, splitPath(p)[0])
+	}
+	return keys
+}
@@ -0,0 +1,131 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSpecOnly(t *testing.T) {
+	local := deploymentWithImage("grafana/grafana:7.3.0")
+	local.Metadata()["labels"] = map[string]interface{}{"team": "new"}
+	local.Metadata()["annotations"] = map[string]interface{}{"note": "new"}
+
+	metaChanged := deploymentWithImage("grafana/grafana:7.3.0")
+	metaChanged.Metadata()["labels"] = map[string]interface{}{"team": "old"}
+	metaChanged.Metadata()["annotations"] = map[string]interface{}{"note": "old"}
+
+	specChanged := deploymentWithImage("grafana/grafana:7.2.0")
+
+	opts := SubsetDiffOpts{SpecOnly: true}
+
+	t.Run("metadata", func(t *testing.T) {
+		result, err := DiffAgainst(manifest.List{local}, manifest.List{metaChanged}, opts)
+		require.NoError(t, err)
+		assert.Empty(t, result.Entries[0].Diff)
+
+		// shown without SpecOnly
+		result, err = DiffAgainst(manifest.List{local}, manifest.List{metaChanged}, SubsetDiffOpts{})
+		require.NoError(t, err)
+		assert.Contains(t, result.Entries[0].Diff, "+    team: new")
+	})
+
+	t.Run("spec", func(t *testing.T) {
+		result, err := DiffAgainst(manifest.List{local}, manifest.List{specChanged}, opts)
+		require.NoError(t, err)
+		e := result.Entries[0]
+		assert.Contains(t, e.Diff, "+      - image: grafana/grafana:7.3.0")
+		assert.NotContains(t, e.Merged, "team")
+		assert.Contains(t, e.Merged, "name: grafana")
+	})
+
+	t.Run("configmap", func(t *testing.T) {
+		l := configMap("foo", "default", map[string]interface{}{"a": "new"})
+		l.Metadata()["labels"] = map[string]interface{}{"x": "y"}
+		result, err := DiffAgainst(manifest.List{l}, manifest.List{configMap("foo", "default", map[string]interface{}{"a": "old"})}, opts)
+		require.NoError(t, err)
+		assert.Contains(t, result.Entries[0].Diff, "+  a: new")
+		assert.NotContains(t, result.Entries[0].Diff, "x: y")
+	})
+}
+
+func TestProjectedFields(t *testing.T) {
+	assert.Equal(t, []string{"spec"}, projectedFields(deploymentWithImage("grafana/grafana:7.3.0")))
+	assert.Equal(t, []string{"data"}, projectedFields(configMap("foo", "default", nil)))
+
+	s := secret("creds", nil)
+	delete(s, "data")
+	s["stringData"] = map[string]interface{}{"password": "secret"}
+	assert.Equal(t, []string{"data", "stringData"}, projectedFields(s))
+
+	ns := manifest.Manifest{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "foo"}}
+	assert.Equal(t, []string{}, projectedFields(ns))
+}
+
+func TestSubsetDifferProject(t *testing.T) {
+	local := configMap("big", "default", map[string]interface{}{"key": "new"})
+
+	live := configMap("big", "default", map[string]interface{}{"key": "old"})
+	live["binaryData"] = map[string]interface{}{"blob": strings.Repeat("A", 1<<16)}
+	live.Metadata()["managedFields"] = []interface{}{
+		map[string]interface{}{"manager": "kubectl", "fieldsV1": map[string]interface{}{"f:data": map[string]interface{}{}}},
+	}
+
+	plain, err := diffState(newFakeClient(live), manifest.List{local}, SubsetDiffOpts{})
+	require.NoError(t, err)
+
+	c := newFakeClient(live)
+	projected, err := diffState(c, manifest.List{local}, SubsetDiffOpts{Project: true})
+	require.NoError(t, err)
+
+	// only the fields present locally are requested, and the result is the same
+	assert.Equal(t, []string{"getFields default ConfigMap big [data]"}, c.CallsWith("get"))
+	require.Len(t, projected.Entries, 1)
+	assert.Equal(t, plain.Entries[0].Live, projected.Entries[0].Live)
+	assert.Equal(t, plain.Entries[0].Merged, projected.Entries[0].Merged)
+	assert.Contains(t, projected.Entries[0].Diff, "+  key: new")
+
+	// the projection leaves out the fields that are not compared
+	full, err := json.Marshal(live)
+	require.NoError(t, err)
+	reduced, err := json.Marshal(client.Project(live, projectedFields(local)))
+	require.NoError(t, err)
+	assert.Less(t, len(reduced), len(full)/100)
+}
+
+func TestSubsetDifferProjectExact(t *testing.T) {
+	local := configMap("big", "default", map[string]interface{}{"key": "old"})
+	live := configMap("big", "default", map[string]interface{}{"key": "old"})
+	live["binaryData"] = map[string]interface{}{"blob": "AAAA"}
+
+	cases := []struct {
+		name string
+		opts SubsetDiffOpts
+	}{
+		{name: "exact", opts: SubsetDiffOpts{Project: true, exact: true}},
+		{name: "kind", opts: SubsetDiffOpts{Project: true, KindStrategies: map[string]string{"ConfigMap": ObjectStrategyExact}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newFakeClient(live)
+			result, err := diffState(c, manifest.List{local}, tc.opts)
+			require.NoError(t, err)
+
+			// the whole object is required to report the removal
+			assert.Equal(t, []string{"get default ConfigMap big"}, c.CallsWith("get"))
+			assert.Contains(t, result.Entries[0].Diff, "-binaryData:")
+		})
+	}
+
+	// fields kept from the cluster are fetched as well
+	c := newFakeClient(live)
+	_, err := diffState(c, manifest.List{local}, SubsetDiffOpts{Project: true, KeepClusterKeys: []string{"binaryData.blob"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"getFields default ConfigMap big [data binaryData]"}, c.CallsWith("get"))
+}
@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// prometheusActions are the actions reported by WritePrometheus, in this
+// order. Actions without objects are reported as 0, so series do not vanish
+var prometheusActions = []PlanAction{PlanCreate, PlanUpdate, PlanUnchanged, PlanPrune, PlanUnknown}
+
+// WritePrometheus writes r as metric samples in the Prometheus text exposition
+// format, e.g. for a Pushgateway or the textfile collector of the node
+// exporter:
+//
+//	tanka_drift_objects{action="update"} 2
+//
+// labels are added to every sample, e.g. the name of the environment.
+func (r DiffResult) WritePrometheus(w io.Writer, labels map[string]string) error {
+	counts := make(map[PlanAction]int)
+	for _, e := range r.Entries {
+		counts[e.Action()]++
+	}
+
+	base := prometheusLabels(labels)
+	sep := ""
+	if base != "" {
+		sep = ","
+	}
+
+	var b strings.Builder
+	b.WriteString("# HELP tanka_drift_objects Objects by the action applying the desired state takes.\n")
+	b.WriteString("# TYPE tanka_drift_objects gauge\n")
+	for _, a := range prometheusActions {
+		fmt.Fprintf(&b, "tanka_drift_objects{%s%saction=\"%s\"} %d\n", base, sep, a, counts[a])
+	}
+
+	b.WriteString("# HELP tanka_drift_ignored_objects Objects with drift in ignored fields.\n")
+	b.WriteString("# TYPE tanka_drift_ignored_objects gauge\n")
+	if base != "" {
+		base = "{" + base + "}"
+	}
+	fmt.Fprintf(&b, "tanka_drift_ignored_objects%s %d\n", base, r.IgnoredDrift())
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// prometheusLabels renders labels as `key="value"` pairs, sorted by key
+func prometheusLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, escapePrometheus(labels[k])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// escapePrometheus escapes a label value, as required by the text format
+var escapePrometheus = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
@@ -0,0 +1,38 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := DiffResult{Entries: []DiffEntry{
+		{Name: "created", Merged: "a", Diff: "+a"},
+		{Name: "changed", Live: "a", Merged: "b", Diff: "-a\n+b"},
+		{Name: "changed2", Live: "a", Merged: "c", Diff: "-a\n+c"},
+		{Name: "same", Live: "a", Merged: "a", IgnoredDrift: []string{"spec.replicas"}},
+		{Name: "pruned", Live: "a", Diff: "-a", Prune: true},
+	}}
+
+	var b strings.Builder
+	require.NoError(t, r.WritePrometheus(&b, map[string]string{"env": "prod", "cluster": `eu "west"`}))
+	assert.Equal(t, `# HELP tanka_drift_objects Objects by the action applying the desired state takes.
+# TYPE tanka_drift_objects gauge
+tanka_drift_objects{cluster="eu \"west\"",env="prod",action="create"} 1
+tanka_drift_objects{cluster="eu \"west\"",env="prod",action="update"} 2
+tanka_drift_objects{cluster="eu \"west\"",env="prod",action="unchanged"} 1
+tanka_drift_objects{cluster="eu \"west\"",env="prod",action="prune"} 1
+tanka_drift_objects{cluster="eu \"west\"",env="prod",action="unknown"} 0
+# HELP tanka_drift_ignored_objects Objects with drift in ignored fields.
+# TYPE tanka_drift_ignored_objects gauge
+tanka_drift_ignored_objects{cluster="eu \"west\"",env="prod"} 1
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, DiffResult{}.WritePrometheus(&b, nil))
+	assert.Contains(t, b.String(), "tanka_drift_objects{action=\"update\"} 0\n")
+	assert.Contains(t, b.String(), "tanka_drift_ignored_objects 0\n")
+}
@@ -0,0 +1,231 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/kubernetes/util"
+)
+
+// listableKinds joins the fully qualified names of all resources supporting
+// LIST into a comma separated string for kubectl
+func listableKinds(resources client.Resources) string {
+	kinds := ""
+	for _, r := range resources {
+		if !strings.Contains(r.Verbs, "list") {
+			continue
+		}
+
+		kinds += "," + r.FQN()
+	}
+	return strings.TrimPrefix(kinds, ",")
+}
+
+// ErrorPruneAllowlist occurs when an entry of SubsetDiffOpts.PruneAllowlist
+// is not of the form <group>/<version>/<kind>
+type ErrorPruneAllowlist struct {
+	Entry string
+}
+
+func (e ErrorPruneAllowlist) Error() string {
+	return fmt.Sprintf("invalid prune allowlist entry '%s'. Expected <group>/<version>/<kind>, e.g. core/v1/ConfigMap or apps/v1/Deployment", e.Entry)
+}
+
+// pruneAllowlist holds the group/version/kinds considered for pruning, in the
+// format of kubectl's --prune-allowlist. The core group is called "core"
+type pruneAllowlist map[string]bool
+
+// parsePruneAllowlist validates entries. A nil pruneAllowlist (allowing all
+// kinds) is returned if entries is empty
+func parsePruneAllowlist(entries []string) (pruneAllowlist, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	allow := make(pruneAllowlist, len(entries))
+	for _, e := range entries {
+		parts := strings.Split(e, "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, ErrorPruneAllowlist{Entry: e}
+		}
+		allow[e] = true
+	}
+	return allow, nil
+}
+
+// resource returns whether objects of r may be allowed. Resources lack a
+// version, so only the group and kind are compared
+func (a pruneAllowlist) resource(r client.Resource) bool {
+	if a == nil {
+		return true
+	}
+
+	group := r.APIGroup
+	if group == "" {
+		group = "core"
+	}
+	for e := range a {
+		parts := strings.Split(e, "/")
+		if parts[0] == group && parts[2] == r.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// object returns whether the group/version/kind of m is allowed
+func (a pruneAllowlist) object(m manifest.Manifest) bool {
+	if a == nil {
+		return true
+	}
+
+	gv := m.APIVersion()
+	if !strings.Contains(gv, "/") {
+		gv = "core/" + gv
+	}
+	return a[gv+"/"+m.Kind()]
+}
+
+// mergeLabels returns the union of the given label sets. Later sets take
+// precedence
+func mergeLabels(sets ...map[string]string) map[string]string {
+	out := make(map[string]string)
+	for _, set := range sets {
+		for k, v := range set {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// orphans returns the objects of the given kinds matching selector, that are
+// not known by their key. Only objects created using apply are considered.
+// Each returned object is added to known.
+func orphans(c client.Client, kinds string, selector map[string]string, known map[string]bool, key func(manifest.Manifest) string) (manifest.List, error) {
+	matched, err := c.GetByLabels("", kinds, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphaned manifest.List
+	for _, m := range matched {
+		// ignore known ones
+		if known[key(m)] {
+			continue
+		}
+
+		// skip objects not created explicitely
+		if _, ok := m.Metadata().Annotations()[AnnotationLastApplied]; !ok {
+			continue
+		}
+
+		// record and skip from now on
+		orphaned = append(orphaned, m)
+		known[key(m)] = true
+	}
+	return orphaned, nil
+}
+
+// ErrorPruneSelector occurs when pruning is requested without any labels to
+// select the objects by, which would report every object of the cluster
+type ErrorPruneSelector struct{}
+
+func (e ErrorPruneSelector) Error() string {
+	return "refusing to prune without a label selector, as all objects of the cluster would be pruned. Set SubsetDiffOpts.PruneSelector or Selector"
+}
+
+// pruneCandidates returns the objects of the cluster matching selector, that
+// are not part of state. Only objects created using apply are considered. If
+// allowlist is set, only objects of the group/version/kinds listed in it are
+// considered (see SubsetDiffOpts.PruneAllowlist).
+func pruneCandidates(c client.Client, state manifest.List, selector map[string]string, allowlist []string) (manifest.List, error) {
+	if len(selector) == 0 {
+		return nil, ErrorPruneSelector{}
+	}
+
+	allow, err := parsePruneAllowlist(allowlist)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := c.Resources()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing known api-resources")
+	}
+	var resources client.Resources
+	for _, r := range all {
+		if allow.resource(r) {
+			resources = append(resources, r)
+		}
+	}
+
+	kinds := listableKinds(resources)
+	if kinds == "" {
+		return nil, nil
+	}
+
+	known := make(map[string]bool, len(state))
+	for _, m := range state {
+		known[objectKey(m)] = true
+	}
+
+	orphaned, err := orphans(c, kinds, selector, known, objectKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing labeled objects")
+	}
+
+	var candidates manifest.List
+	for _, m := range orphaned {
+		if allow.object(m) {
+			candidates = append(candidates, m)
+		}
+	}
+	return candidates, nil
+}
+
+// pruneEntries returns the DiffEntries of objects that would be pruned
+func pruneEntries(candidates manifest.List, opts SubsetDiffOpts) ([]DiffEntry, error) {
+	entries, err := removalEntries(candidates, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Notes = []string{"(pruned)"}
+		entries[i].Prune = true
+	}
+	return entries, nil
+}
+
+// removalEntries returns DiffEntries showing the removal of the given objects
+func removalEntries(list manifest.List, opts SubsetDiffOpts) ([]DiffEntry, error) {
+	entries := make([]DiffEntry, 0, len(list))
+	for _, m := range list {
+		name := util.DiffName(m)
+		if opts.MaskSecrets && m.Kind() == "Secret" {
+			m = maskRemovedSecret(m)
+		}
+
+		is, err := opts.serializer().Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := opts.diff(m, is, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "invoking diff")
+		}
+
+		entries = append(entries, DiffEntry{
+			Name:   name,
+			Ref:    RefOf(m),
+			Labels: labelsOf(m),
+			Live:   is,
+			Diff:   d,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,194 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/kubernetes/util"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+func TestDiffStatePrune(t *testing.T) {
+	labeled := func(m manifest.Manifest, applied bool) manifest.Manifest {
+		m.Metadata().Labels()[process.LabelEnvironment] = "default"
+		if applied {
+			m.Metadata().Annotations()[AnnotationLastApplied] = "{}"
+		}
+		return m
+	}
+
+	c := newFakeClient(
+		labeled(configMap("kept", "default", map[string]interface{}{"foo": "old"}), true),
+		labeled(configMap("orphan", "default", map[string]interface{}{"foo": "bar"}), true),
+		// not created by apply
+		labeled(configMap("generated", "default", map[string]interface{}{"foo": "bar"}), false),
+		// not part of the environment
+		configMap("unrelated", "default", map[string]interface{}{"foo": "bar"}),
+	)
+	c.resources = client.Resources{
+		{Kind: "ConfigMap", Name: "configmaps", Namespaced: true, Verbs: "[create delete get list patch update watch]"},
+	}
+
+	state := manifest.List{
+		configMap("kept", "default", map[string]interface{}{"foo": "new"}),
+	}
+	opts := SubsetDiffOpts{PruneSelector: map[string]string{process.LabelEnvironment: "default"}}
+
+	result, err := diffState(c, state, opts)
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 2)
+
+	changed := result.Entries[0]
+	assert.False(t, changed.Prune)
+	assert.Contains(t, changed.Diff, "+  foo: new")
+
+	pruned := result.Entries[1]
+	assert.True(t, pruned.Prune)
+	assert.Equal(t, "v1.ConfigMap.default.orphan", pruned.Name)
+	assert.Empty(t, pruned.Merged)
+	assert.Contains(t, pruned.Diff, "-kind: ConfigMap")
+	assert.Contains(t, result.String(), "# v1.ConfigMap.default.orphan: (pruned)")
+
+	// disabled by default
+	result, err = diffState(c, state, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.Len(t, result.Entries, 1)
+}
+
+func TestDiffStatePruneSecret(t *testing.T) {
+	orphan := secret("creds", map[string]string{"password": "hunter2"})
+	orphan.Metadata().Labels()[process.LabelEnvironment] = "default"
+	orphan.Metadata().Annotations()[AnnotationLastApplied] = `{"data":{"password":"aHVudGVyMg=="}}`
+
+	c := newFakeClient(orphan)
+	c.resources = client.Resources{
+		{Kind: "Secret", Name: "secrets", Namespaced: true, Verbs: "[create delete get list patch update watch]"},
+	}
+
+	opts := SubsetDiffOpts{MaskSecrets: true, PruneSelector: map[string]string{process.LabelEnvironment: "default"}}
+	result, err := diffState(c, manifest.List{}, opts)
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+
+	pruned := result.Entries[0]
+	assert.True(t, pruned.Prune)
+	assert.Contains(t, pruned.Diff, "-  password: <redacted>")
+	for _, s := range []string{"hunter2", "aHVudGVyMg=="} {
+		assert.NotContains(t, pruned.Diff, s)
+		assert.NotContains(t, pruned.Live, s)
+	}
+
+	// the cluster state is not modified
+	assert.Equal(t, "aHVudGVyMg==", orphan["data"].(map[string]interface{})["password"])
+
+	// removed between renders
+	result, err = DiffRenders(manifest.List{orphan}, nil, SubsetDiffOpts{MaskSecrets: true})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+	assert.NotContains(t, result.Entries[0].Diff, "aHVudGVyMg==")
+}
+
+func TestDiffStatePruneAllowlist(t *testing.T) {
+	labeled := func(m manifest.Manifest) manifest.Manifest {
+		m.Metadata().Labels()[process.LabelEnvironment] = "default"
+		m.Metadata().Annotations()[AnnotationLastApplied] = "{}"
+		return m
+	}
+
+	c := newFakeClient(
+		labeled(configMap("orphan", "default", nil)),
+		labeled(secret("orphan", nil)),
+		labeled(deploymentWithImage("nginx")),
+	)
+	c.resources = client.Resources{
+		{Kind: "ConfigMap", Name: "configmaps", Namespaced: true, Verbs: "[get list]"},
+		{Kind: "Secret", Name: "secrets", Namespaced: true, Verbs: "[get list]"},
+		{Kind: "Deployment", APIGroup: "apps", Name: "deployments", Namespaced: true, Verbs: "[get list]"},
+	}
+
+	cases := []struct {
+		name      string
+		allowlist []string
+		pruned    []string
+		list      []string
+	}{
+		{
+			name:   "all",
+			pruned: []string{"apps-v1.Deployment.default.grafana", "v1.ConfigMap.default.orphan", "v1.Secret.default.orphan"},
+			list:   []string{"list  ConfigMap,Secret,Deployment.apps map[tanka.dev/environment:default]"},
+		},
+		{
+			name:      "core",
+			allowlist: []string{"core/v1/ConfigMap"},
+			pruned:    []string{"v1.ConfigMap.default.orphan"},
+			list:      []string{"list  ConfigMap map[tanka.dev/environment:default]"},
+		},
+		{
+			name:      "grouped",
+			allowlist: []string{"apps/v1/Deployment", "core/v1/Secret"},
+			pruned:    []string{"apps-v1.Deployment.default.grafana", "v1.Secret.default.orphan"},
+			list:      []string{"list  Secret,Deployment.apps map[tanka.dev/environment:default]"},
+		},
+		{
+			// kubectl lists the served version, which must match as well
+			name:      "version",
+			allowlist: []string{"apps/v1beta1/Deployment"},
+			list:      []string{"list  Deployment.apps map[tanka.dev/environment:default]"},
+		},
+		{
+			name:      "unknown",
+			allowlist: []string{"batch/v1/Job"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c.calls = nil
+			opts := SubsetDiffOpts{
+				PruneSelector:  map[string]string{process.LabelEnvironment: "default"},
+				PruneAllowlist: tc.allowlist,
+			}
+
+			candidates, err := pruneCandidates(c, nil, opts.PruneSelector, opts.PruneAllowlist)
+			require.NoError(t, err)
+
+			var pruned []string
+			for _, m := range candidates {
+				pruned = append(pruned, util.DiffName(m))
+			}
+			assert.ElementsMatch(t, tc.pruned, pruned)
+			assert.Equal(t, tc.list, c.CallsWith("list "))
+		})
+	}
+}
+
+func TestPruneAllowlistInvalid(t *testing.T) {
+	for _, entry := range []string{"ConfigMap", "v1/ConfigMap", "/v1/ConfigMap", "core/v1/"} {
+		_, err := pruneCandidates(newFakeClient(), nil, map[string]string{process.LabelEnvironment: "default"}, []string{entry})
+		assert.Equal(t, ErrorPruneAllowlist{Entry: entry}, err)
+	}
+}
+
+// TestDiffStatePruneEmptySelector asserts pruning is refused if no labels
+// select the objects, instead of reporting the whole cluster as pruned
+func TestDiffStatePruneEmptySelector(t *testing.T) {
+	m := configMap("orphan", "default", nil)
+	m.Metadata().Annotations()[AnnotationLastApplied] = "{}"
+	c := newFakeClient(m)
+	c.resources = client.Resources{
+		{Kind: "ConfigMap", Name: "configmaps", Namespaced: true, Verbs: "[create delete get list patch update watch]"},
+	}
+
+	_, err := diffState(c, manifest.List{configMap("kept", "default", nil)}, SubsetDiffOpts{PruneSelector: map[string]string{}})
+	assert.Equal(t, ErrorPruneSelector{}, err)
+	assert.Empty(t, c.CallsWith("list "))
+
+	// the selector of the diff is used as well
+	opts := SubsetDiffOpts{Selector: map[string]string{"team": "x"}, PruneSelector: map[string]string{}}
+	_, err = diffState(c, manifest.List{configMap("kept", "default", nil)}, opts)
+	require.NoError(t, err)
+}
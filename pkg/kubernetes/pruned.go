@@ -0,0 +1,60 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+)
+
+// prunedPaths returns the paths of all fields of big that subset() removes,
+// because they are absent from small. Lists are descended into the same way
+// subset() does, with elements addressed as `path[i]`.
+func prunedPaths(small, big map[string]interface{}, prefix string) []string {
+	keys := make([]string, 0, len(big))
+	for k := range big {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var paths []string
+	for _, k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+
+		if _, ok := small[k]; !ok {
+			paths = append(paths, path)
+			continue
+		}
+
+		switch b := big[k].(type) {
+		case map[string]interface{}:
+			if a, ok := small[k].(map[string]interface{}); ok {
+				paths = append(paths, prunedPaths(a, b, path)...)
+			}
+		case []map[string]interface{}:
+			if a, ok := small[k].([]map[string]interface{}); ok {
+				for i := 0; i < len(a) && i < len(b); i++ {
+					paths = append(paths, prunedPaths(a[i], b[i], fmt.Sprintf("%s[%d]", path, i))...)
+				}
+			}
+		case []interface{}:
+			a, ok := small[k].([]interface{})
+			if !ok {
+				continue
+			}
+			for i := 0; i < len(a) && i < len(b); i++ {
+				cShould, ok := a[i].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				cIs, ok := b[i].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				paths = append(paths, prunedPaths(cShould, cIs, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,37 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestRecordPruned(t *testing.T) {
+	local := deploymentWithImage("grafana/grafana:7.3.0")
+
+	live := deploymentWithImage("grafana/grafana:7.3.0")
+	live.Metadata()["uid"] = "1234"
+	live.Metadata()["resourceVersion"] = "42"
+	live["status"] = map[string]interface{}{"replicas": 1}
+	container := live["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})
+	container["terminationMessagePath"] = "/dev/termination-log"
+	container["imagePullPolicy"] = "IfNotPresent"
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{RecordPruned: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"metadata.resourceVersion",
+		"metadata.uid",
+		"spec.template.spec.containers[0].imagePullPolicy",
+		"spec.template.spec.containers[0].terminationMessagePath",
+		"status",
+	}, result.Entries[0].Pruned)
+
+	// off by default
+	result, err = DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.Nil(t, result.Entries[0].Pruned)
+}
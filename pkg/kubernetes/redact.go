@@ -0,0 +1,84 @@
+package kubernetes
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// AnnotationRedact can be set on any object to mask the values of the listed
+// fields in the diff, e.g. `spec.password,data.token`. Paths are in dotted
+// notation (see IgnoreRule.Paths), but do not descend into lists. The fields are still compared, but
+// rendered as masks (MaskUnchanged, MaskChanged, ...), like the values of
+// Secrets with SubsetDiffOpts.MaskSecrets. The annotation is removed from the
+// object before diffing.
+const AnnotationRedact = process.MetadataPrefix + "/redact"
+
+// redactPaths returns the paths listed in AnnotationRedact of m, or nil
+func redactPaths(m manifest.Manifest) []string {
+	annotations, _ := m.Metadata()["annotations"].(map[string]interface{})
+	s, _ := annotations[AnnotationRedact].(string)
+
+	var paths []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// redact replaces the fields at paths of local and its live counterpart with
+// masks, stating whether they differ without revealing their values.
+func (s subsetter) redact(local, live manifest.Manifest, paths []string, exact bool) {
+	for _, p := range paths {
+		keys := splitPath(p)
+		want, inLocal := lookupPath(local, p)
+		var got interface{}
+		var inLive bool
+		if live != nil {
+			got, inLive = lookupPath(live, p)
+		}
+
+		switch {
+		case inLocal && !inLive:
+			setPath(local, keys, MaskAdded)
+		case !inLocal && inLive:
+			setPath(live, keys, MaskRedacted)
+		case inLocal && s.redactedEqual(want, got, exact):
+			setPath(local, keys, MaskUnchanged)
+			setPath(live, keys, MaskUnchanged)
+		case inLocal:
+			setPath(local, keys, MaskChanged)
+			setPath(live, keys, MaskRedacted)
+		}
+	}
+}
+
+// redactedEqual returns whether the redacted field is unchanged. Unless the
+// comparison is exact, fields absent from want are not considered.
+func (s subsetter) redactedEqual(want, got interface{}, exact bool) bool {
+	if exact {
+		return equalJSON(want, got)
+	}
+
+	// wrapped, so subset() handles non-map fields too
+	sub, err := s.subset(map[string]interface{}{"v": want}, map[string]interface{}{"v": got}, "", 0)
+	return err == nil && reflect.DeepEqual(sub["v"], want)
+}
+
+// setPath replaces the existing field at keys of m with v
+func setPath(m map[string]interface{}, keys []string, v interface{}) {
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+	if _, ok := m[keys[len(keys)-1]]; ok {
+		m[keys[len(keys)-1]] = v
+	}
+}
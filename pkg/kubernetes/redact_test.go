@@ -0,0 +1,73 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestRedactAnnotation(t *testing.T) {
+	redacted := func(data map[string]interface{}) manifest.Manifest {
+		m := configMap("creds", "default", data)
+		m.Metadata()["annotations"] = map[string]interface{}{AnnotationRedact: `data.password, data.token,data.user`}
+		return m
+	}
+
+	local := redacted(map[string]interface{}{"password": "hunter3", "token": "new-token", "user": "admin", "url": "https://example.com"})
+	live := configMap("creds", "default", map[string]interface{}{"password": "hunter2", "user": "admin", "url": "https://example.org"})
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{})
+	require.NoError(t, err)
+
+	e := result.Entries[0]
+	assert.Contains(t, e.Diff, "-  password: <redacted>")
+	assert.Contains(t, e.Diff, "+  password: <changed>")
+	assert.Contains(t, e.Diff, "+  token: <added>")
+	assert.Contains(t, e.Diff, "   user: <unchanged>")
+
+	// other fields are shown as usual
+	assert.Contains(t, e.Diff, "-  url: https://example.org")
+	assert.Contains(t, e.Diff, "+  url: https://example.com")
+
+	for _, s := range []string{"hunter", "admin", "new-token", AnnotationRedact} {
+		assert.NotContains(t, e.Live, s)
+		assert.NotContains(t, e.Merged, s)
+	}
+
+	// inputs are not modified
+	assert.Equal(t, "hunter3", local["data"].(map[string]interface{})["password"])
+
+	t.Run("unchanged", func(t *testing.T) {
+		live := configMap("creds", "default", map[string]interface{}{"password": "hunter2"})
+		result, err := DiffAgainst(manifest.List{redacted(map[string]interface{}{"password": "hunter2"})}, manifest.List{live}, SubsetDiffOpts{})
+		require.NoError(t, err)
+		assert.Empty(t, result.Entries[0].Diff)
+		assert.Contains(t, result.Entries[0].Live, "password: <unchanged>")
+	})
+
+	t.Run("created", func(t *testing.T) {
+		result, err := DiffAgainst(manifest.List{local}, nil, SubsetDiffOpts{})
+		require.NoError(t, err)
+		assert.Contains(t, result.Entries[0].Diff, "password: <added>")
+		assert.NotContains(t, result.Entries[0].Diff, "hunter")
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		// data is compared exactly, so the live key is shown, but redacted
+		result, err := DiffAgainst(manifest.List{redacted(map[string]interface{}{"url": "https://example.org"})}, manifest.List{live}, SubsetDiffOpts{})
+		require.NoError(t, err)
+		assert.Contains(t, result.Entries[0].Diff, "-  password: <redacted>")
+		assert.NotContains(t, result.Entries[0].Diff, "hunter")
+	})
+}
+
+func TestRedactPaths(t *testing.T) {
+	m := configMap("config", "default", nil)
+	assert.Nil(t, redactPaths(m))
+
+	m.Metadata()["annotations"] = map[string]interface{}{AnnotationRedact: `spec.password, ,metadata.annotations.example\.com/token`}
+	assert.Equal(t, []string{"spec.password", `metadata.annotations.example\.com/token`}, redactPaths(m))
+}
@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// ObjectRef identifies a Kubernetes object
+type ObjectRef struct {
+	Group, Version, Kind string
+	Namespace, Name      string
+}
+
+// RefOf returns the ObjectRef of m
+func RefOf(m manifest.Manifest) ObjectRef {
+	ref := ObjectRef{
+		Version:   m.APIVersion(),
+		Kind:      m.Kind(),
+		Namespace: m.Metadata().Namespace(),
+		Name:      m.Metadata().Name(),
+	}
+	if parts := strings.SplitN(m.APIVersion(), "/", 2); len(parts) == 2 {
+		ref.Group, ref.Version = parts[0], parts[1]
+	}
+	return ref
+}
+
+// versionExpr matches Kubernetes API versions, like v1 or v2beta1
+var versionExpr = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// ParseObjectRef parses refs formatted by ObjectRef.String, e.g.
+// `apps/v1/Deployment/default/grafana` or `v1/Namespace/default`. As API groups
+// and namespaces both are optional, the first part is taken to be the version
+// of the core group if it looks like one (v1, v2beta1, ...)
+func ParseObjectRef(s string) (ObjectRef, error) {
+	parts := strings.Split(s, "/")
+	for _, p := range parts {
+		if p == "" {
+			return ObjectRef{}, ErrorInvalidRef{Ref: s, Reason: "empty part"}
+		}
+	}
+
+	var ref ObjectRef
+	if len(parts) > 0 && !versionExpr.MatchString(parts[0]) {
+		ref.Group, parts = parts[0], parts[1:]
+	}
+
+	switch len(parts) {
+	case 3:
+		ref.Version, ref.Kind, ref.Name = parts[0], parts[1], parts[2]
+	case 4:
+		ref.Version, ref.Kind, ref.Namespace, ref.Name = parts[0], parts[1], parts[2], parts[3]
+	default:
+		return ObjectRef{}, ErrorInvalidRef{Ref: s, Reason: "expected [<group>/]<version>/<kind>/[<namespace>/]<name>"}
+	}
+
+	if !versionExpr.MatchString(ref.Version) {
+		return ObjectRef{}, ErrorInvalidRef{Ref: s, Reason: fmt.Sprintf("'%s' is not an API version", ref.Version)}
+	}
+	return ref, nil
+}
+
+// ErrorInvalidRef occurs when a string cannot be parsed as an ObjectRef
+type ErrorInvalidRef struct {
+	Ref    string
+	Reason string
+}
+
+func (e ErrorInvalidRef) Error() string {
+	return fmt.Sprintf("invalid object reference '%s': %s", e.Ref, e.Reason)
+}
+
+// APIVersion returns the apiVersion of the referenced object
+func (r ObjectRef) APIVersion() string {
+	if r.Group == "" {
+		return r.Version
+	}
+	return r.Group + "/" + r.Version
+}
+
+// String returns the ref as `<apiVersion>/<kind>/<namespace>/<name>`. The
+// namespace is omitted for cluster-wide objects
+func (r ObjectRef) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s/%s", r.APIVersion(), r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", r.APIVersion(), r.Kind, r.Namespace, r.Name)
+}
+
+// Matches returns whether r references m. The apiVersion is only compared if
+// r has a Version
+func (r ObjectRef) Matches(m manifest.Manifest) bool {
+	if r.Version != "" && r.APIVersion() != m.APIVersion() {
+		return false
+	}
+	return r.Kind == m.Kind() &&
+		r.Namespace == m.Metadata().Namespace() &&
+		r.Name == m.Metadata().Name()
+}
@@ -0,0 +1,66 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestRefOf(t *testing.T) {
+	ref := RefOf(deploymentWithImage("grafana/grafana"))
+	assert.Equal(t, ObjectRef{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "default", Name: "grafana"}, ref)
+	assert.Equal(t, "apps/v1", ref.APIVersion())
+	assert.Equal(t, "apps/v1/Deployment/default/grafana", ref.String())
+
+	ref = RefOf(configMap("foo", "", nil))
+	assert.Equal(t, ObjectRef{Version: "v1", Kind: "ConfigMap", Name: "foo"}, ref)
+	assert.Equal(t, "v1/ConfigMap/foo", ref.String())
+}
+
+func TestParseObjectRef(t *testing.T) {
+	cases := []struct {
+		s   string
+		ref ObjectRef
+		err string
+	}{
+		{s: "apps/v1/Deployment/default/grafana", ref: ObjectRef{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "default", Name: "grafana"}},
+		{s: "v1/ConfigMap/default/foo", ref: ObjectRef{Version: "v1", Kind: "ConfigMap", Namespace: "default", Name: "foo"}},
+		{s: "v1/Namespace/default", ref: ObjectRef{Version: "v1", Kind: "Namespace", Name: "default"}},
+		{s: "rbac.authorization.k8s.io/v1/ClusterRole/reader", ref: ObjectRef{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "reader"}},
+		{s: "autoscaling/v2beta1/HorizontalPodAutoscaler/default/web", ref: ObjectRef{Group: "autoscaling", Version: "v2beta1", Kind: "HorizontalPodAutoscaler", Namespace: "default", Name: "web"}},
+		{s: "ConfigMap/foo", err: "invalid object reference 'ConfigMap/foo': expected [<group>/]<version>/<kind>/[<namespace>/]<name>"},
+		{s: "v1/ConfigMap//foo", err: "invalid object reference 'v1/ConfigMap//foo': empty part"},
+		{s: "apps/latest/Deployment/grafana", err: "invalid object reference 'apps/latest/Deployment/grafana': 'latest' is not an API version"},
+		{s: "", err: "invalid object reference '': empty part"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.s, func(t *testing.T) {
+			ref, err := ParseObjectRef(c.s)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.ref, ref)
+			assert.Equal(t, c.s, ref.String())
+		})
+	}
+}
+
+func TestObjectRefRoundTrip(t *testing.T) {
+	for _, m := range []manifest.Manifest{
+		deploymentWithImage("grafana/grafana"),
+		configMap("foo", "", nil),
+		configMap("foo", "default", nil),
+		clusterRole(),
+	} {
+		ref := RefOf(m)
+		parsed, err := ParseObjectRef(ref.String())
+		assert.NoError(t, err)
+		assert.Equal(t, ref, parsed)
+		assert.True(t, parsed.Matches(m))
+	}
+}
@@ -0,0 +1,61 @@
+package kubernetes
+
+import (
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// DiffRenders compares two renders of the desired state, e.g. of two revisions
+// of an environment, without contacting the cluster. Objects are matched by
+// kind, namespace and name. As both renders are complete, all fields are
+// compared, as if using ObjectStrategyExact. Objects only present in b are
+// reported as created, the ones only present in a as removed. Neither of the
+// lists is modified.
+func DiffRenders(a, b manifest.List, opts SubsetDiffOpts) (*DiffResult, error) {
+	a, err := skipNone(a, opts.KindStrategies)
+	if err != nil {
+		return nil, err
+	}
+	b, err = skipNone(b, opts.KindStrategies)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]manifest.Manifest, len(a))
+	for _, m := range a {
+		index[objectKey(m)] = m
+	}
+
+	comparisons := make([]comparison, 0, len(b))
+	kept := make(map[string]bool, len(b))
+	for _, m := range b {
+		c := comparison{local: m}
+		if prev, ok := index[objectKey(m)]; ok {
+			c.live = manifest.Manifest(copyMSI(prev))
+		}
+		comparisons = append(comparisons, c)
+		kept[objectKey(m)] = true
+	}
+
+	opts.exact = true
+	result, err := diffComparisons(comparisons, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed manifest.List
+	for _, m := range a {
+		if !kept[objectKey(m)] {
+			removed = append(removed, m)
+		}
+	}
+	entries, err := removalEntries(removed, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Notes = []string{"(removed)"}
+	}
+	result.Entries = append(result.Entries, entries...)
+
+	return result, nil
+}
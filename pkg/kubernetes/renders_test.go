@@ -0,0 +1,50 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestDiffRenders(t *testing.T) {
+	a := manifest.List{
+		configMap("kept", "default", map[string]interface{}{"key": "old", "dropped": "yes"}),
+		configMap("removed", "default", map[string]interface{}{"key": "value"}),
+	}
+	b := manifest.List{
+		configMap("kept", "default", map[string]interface{}{"key": "new"}),
+		configMap("added", "default", map[string]interface{}{"key": "value"}),
+	}
+
+	result, err := DiffRenders(a, b, SubsetDiffOpts{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 3)
+
+	kept, added, removed := result.Entries[0], result.Entries[1], result.Entries[2]
+
+	// fields removed from the render are shown, unlike in a subset diff
+	assert.Equal(t, "v1.ConfigMap.default.kept", kept.Name)
+	assert.Contains(t, kept.Diff, "-  dropped: \"yes\"")
+	assert.Contains(t, kept.Diff, "-  key: old")
+	assert.Contains(t, kept.Diff, "+  key: new")
+
+	assert.Equal(t, "v1.ConfigMap.default.added", added.Name)
+	assert.Empty(t, added.Live)
+	assert.Contains(t, added.Diff, "+  name: added")
+
+	assert.Equal(t, "v1.ConfigMap.default.removed", removed.Name)
+	assert.Equal(t, []string{"(removed)"}, removed.Notes)
+	assert.Empty(t, removed.Merged)
+	assert.Contains(t, removed.Diff, "-  name: removed")
+	assert.False(t, removed.Prune)
+
+	// the renders are not modified
+	assert.Equal(t, "yes", a[0]["data"].(map[string]interface{})["dropped"])
+
+	result, err = DiffRenders(b, b, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.Empty(t, result.Render(DiffBudget{}))
+}
@@ -0,0 +1,70 @@
+package kubernetes
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// ReportTimeFormat is the format of `.Time` in report path templates. It does
+// not contain characters that are problematic in filenames.
+const ReportTimeFormat = "20060102T150405Z"
+
+// ReportVars are available in report path templates
+type ReportVars struct {
+	// Env is the name of the environment
+	Env string
+	// Time the report was created, formatted using ReportTimeFormat
+	Time string
+}
+
+// NewReportVars returns ReportVars for the given environment at time t
+func NewReportVars(env string, t time.Time) ReportVars {
+	return ReportVars{
+		Env:  env,
+		Time: t.UTC().Format(ReportTimeFormat),
+	}
+}
+
+// ExpandReportPath expands the path template tmpl using vars, so parallel jobs
+// do not overwrite each other's reports, e.g. `reports/{{.Env}}-{{.Time}}.diff`.
+// Sprig functions are available as well, e.g. `{{ env "CI_JOB_ID" }}`.
+func ExpandReportPath(tmpl string, vars ReportVars) (string, error) {
+	t, err := template.New("").
+		Funcs(sprig.TxtFuncMap()).
+		Option("missingkey=error").
+		Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parsing report path: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("expanding report path: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// WriteReport writes the rendered result to the file at the expanded path
+// template. Missing parent directories are created. Returns the actual path.
+func (r DiffResult) WriteReport(tmpl string, vars ReportVars) (string, error) {
+	path, err := ExpandReportPath(tmpl, vars)
+	if err != nil {
+		return "", err
+	}
+
+	// the report holds rendered diffs, which may include Secrets
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(path, []byte(r.String()), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
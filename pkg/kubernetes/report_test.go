@@ -0,0 +1,65 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandReportPath(t *testing.T) {
+	os.Setenv("TANKA_TEST_JOB", "42")
+	defer os.Unsetenv("TANKA_TEST_JOB")
+
+	vars := NewReportVars("prod", time.Date(2020, 11, 3, 14, 5, 9, 0, time.FixedZone("CET", 3600)))
+
+	cases := []struct {
+		tmpl string
+		want string
+		err  bool
+	}{
+		{tmpl: "reports/diff.txt", want: "reports/diff.txt"},
+		{tmpl: "reports/{{.Env}}-{{.Time}}.html", want: "reports/prod-20201103T130509Z.html"},
+		{tmpl: `{{.Env}}/{{ env "TANKA_TEST_JOB" }}.json`, want: "prod/42.json"},
+		{tmpl: "{{.Unknown}}", err: true},
+		{tmpl: "{{.Env", err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tmpl, func(t *testing.T) {
+			got, err := ExpandReportPath(c.tmpl, vars)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	result := DiffResult{Entries: []DiffEntry{{Name: "a", Diff: "diff a\n"}}}
+	path, err := result.WriteReport(filepath.Join(dir, "{{.Env}}", "{{.Time}}.diff"), ReportVars{Env: "dev", Time: "now"})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "dev", "now.diff"), path)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "diff a\n", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
@@ -0,0 +1,27 @@
+package kubernetes
+
+import (
+	"reflect"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// reread fetches the referenced object again, until two consecutive reads
+// agree or max rereads were made. Objects that are mutated rapidly (e.g. by
+// a controller updating their status) may otherwise be compared mid-change.
+// The last read is returned, along with whether it was stable.
+func reread(c client.Client, ref ObjectRef, fields []string, live manifest.Manifest, max int) (manifest.Manifest, bool, error) {
+	for i := 0; i < max; i++ {
+		next, err := getLive(c, ref, fields)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if reflect.DeepEqual(live, next) {
+			return next, true, nil
+		}
+		live = next
+	}
+	return live, false, nil
+}
@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSubsetDifferRereads(t *testing.T) {
+	// hot changes on every read, warm on the first two, cold never
+	var mu sync.Mutex
+	reads := map[string]int{}
+
+	c := newFakeClient()
+	c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reads[name]++
+		n := reads[name]
+
+		switch name {
+		case "hot":
+		case "warm":
+			if n > 2 {
+				n = 2
+			}
+		case "cold":
+			n = 0
+		default:
+			return nil, client.ErrorNotFound{}
+		}
+
+		m := configMap(name, namespace, map[string]interface{}{"foo": fmt.Sprintf("v%d", n)})
+		m.Metadata()["resourceVersion"] = fmt.Sprint(n)
+		return m, nil
+	}
+
+	state := manifest.List{
+		configMap("hot", "default", map[string]interface{}{"foo": "v1"}),
+		configMap("warm", "default", map[string]interface{}{"foo": "v1"}),
+		configMap("cold", "default", map[string]interface{}{"foo": "v0"}),
+		configMap("created", "default", map[string]interface{}{"foo": "v0"}),
+	}
+
+	result, err := diffState(c, state, SubsetDiffOpts{Rereads: 3})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 4)
+
+	hot, warm, cold, created := result.Entries[0], result.Entries[1], result.Entries[2], result.Entries[3]
+
+	assert.True(t, hot.Unknown)
+	assert.Equal(t, []string{"changed on every read"}, hot.Notes)
+	assert.Equal(t, 4, reads["hot"])
+
+	// compared against the stable read, not the first one
+	assert.False(t, warm.Unknown)
+	assert.Contains(t, warm.Diff, "-  foo: v2")
+	assert.Equal(t, "2", warm.ResourceVersion)
+	assert.Equal(t, 3, reads["warm"])
+
+	assert.False(t, cold.Unknown)
+	assert.Empty(t, cold.Diff)
+	assert.Equal(t, 2, reads["cold"])
+
+	// absent objects are not read again
+	assert.False(t, created.Unknown)
+	assert.Equal(t, PlanCreate, created.Action())
+	assert.Equal(t, []string{"get default ConfigMap created"}, c.CallsWith("get default ConfigMap created"))
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{Rereads: 1})(state[:1])
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Equal(t, "# v1.ConfigMap.default.hot: unknown drift, changed on every read\n", *diff)
+}
+
+func TestSubsetDifferRereadsDisabled(t *testing.T) {
+	c := newFakeClient(configMap("config", "default", map[string]interface{}{"foo": "bar"}))
+
+	_, err := diffState(c, manifest.List{configMap("config", "default", nil)}, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Gets())
+}
@@ -0,0 +1,36 @@
+package kubernetes
+
+// podTemplateKinds are the workloads which roll out their pods again once
+// their pod template (spec.template) changes
+var podTemplateKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+}
+
+// restartsPods reports whether applying local over live changes the pod
+// template of a workload. Changes elsewhere, e.g. to the labels of the object
+// itself or spec.replicas, do not cause pods to be replaced.
+func (s subsetter) restartsPods(local, live map[string]interface{}) (bool, error) {
+	kind, _ := local["kind"].(string)
+	if !podTemplateKinds[kind] {
+		return false, nil
+	}
+
+	should, err := s.serializerFor(nil).Marshal(templateOf(local))
+	if err != nil {
+		return false, err
+	}
+	is, err := s.serializerFor(nil).Marshal(templateOf(live))
+	if err != nil {
+		return false, err
+	}
+	return should != is, nil
+}
+
+// templateOf returns spec.template of m, or nil
+func templateOf(m map[string]interface{}) map[string]interface{} {
+	spec, _ := m["spec"].(map[string]interface{})
+	template, _ := spec["template"].(map[string]interface{})
+	return template
+}
@@ -0,0 +1,63 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestAnnotateRestarts(t *testing.T) {
+	const marker = "(will restart pods)"
+
+	withLabel := func(m manifest.Manifest) manifest.Manifest {
+		m.Metadata()["labels"] = map[string]interface{}{"team": "observability"}
+		m["spec"].(map[string]interface{})["replicas"] = float64(3)
+		return m
+	}
+
+	cases := []struct {
+		name        string
+		local, live manifest.Manifest
+		restart     bool
+	}{
+		{
+			name:    "template",
+			local:   deploymentWithImage("grafana/grafana:7.3.0"),
+			live:    deploymentWithImage("grafana/grafana:7.2.0"),
+			restart: true,
+		},
+		{
+			name:  "metadata",
+			local: withLabel(deploymentWithImage("grafana/grafana:7.3.0")),
+			live:  deploymentWithImage("grafana/grafana:7.3.0"),
+		},
+		{
+			name:  "created",
+			local: deploymentWithImage("grafana/grafana:7.3.0"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var live manifest.List
+			if c.live != nil {
+				live = manifest.List{c.live}
+			}
+
+			result, err := DiffAgainst(manifest.List{c.local}, live, SubsetDiffOpts{AnnotateRestarts: true})
+			require.NoError(t, err)
+
+			e := result.Entries[0]
+			require.NotEmpty(t, e.Diff)
+			if c.restart {
+				assert.Contains(t, e.Notes, marker)
+				assert.Contains(t, result.String(), "# apps-v1.Deployment.default.grafana: "+marker)
+			} else {
+				assert.NotContains(t, e.Notes, marker)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffResult is the structured outcome of comparing the desired state to the
+// live state of the cluster. It holds one entry per compared object.
+type DiffResult struct {
+	Entries []DiffEntry
+}
+
+// DiffEntry is the comparison of a single object
+type DiffEntry struct {
+	// Name of the object, as computed by util.DiffName
+	Name string
+	// Ref identifies the object
+	Ref ObjectRef
+
+	// Live and Merged are the serialized states that were compared. Live is
+	// empty if the object does not exist in the cluster yet.
+	Live, Merged string
+
+	// Diff holds the differences in `diff(1)` format. Empty if there are none
+	Diff string
+
+	// Notes give reviewers additional context on the differences. They are
+	// rendered as comments above the diff.
+	Notes []string
+
+	// Pruned lists the live fields that were not compared, because they are
+	// absent from the desired state. Only set if SubsetDiffOpts.RecordPruned
+	Pruned []string
+
+	// JSONPatch holds the RFC 6902 operations turning Live into Merged. Only
+	// set if SubsetDiffOpts.JSONPatch
+	JSONPatch JSONPatch
+
+	// ResourceVersion of the live object at the time of the diff. Empty if the
+	// object does not exist. Allows a later apply to fail if the object was
+	// changed concurrently.
+	ResourceVersion string
+
+	// Recreate is set if the object is an immutable ConfigMap or Secret
+	// (`immutable: true` in the cluster) whose content changed. It cannot be
+	// updated in place, but must be deleted and created again.
+	Recreate bool
+
+	// RevisionMismatch is set if the live object does not reflect the revision
+	// of the desired state. Only checked if SubsetDiffOpts.RevisionAnnotation
+	RevisionMismatch *RevisionMismatch
+
+	// IgnoredDrift lists the ignored fields that differ from the cluster. Only
+	// set if SubsetDiffOpts.ReportIgnored
+	IgnoredDrift []string
+
+	// Prune is set if the object only exists in the cluster and would be
+	// removed by pruning
+	Prune bool
+
+	// Unknown is set if the object could not be compared, because the cluster
+	// was unreachable (SubsetDiffOpts.TolerateUnreachable) or the object
+	// changed on every read (SubsetDiffOpts.Rereads). The reason is given in
+	// Notes. Live, Merged and Diff are empty in that case
+	Unknown bool
+
+	// Unmodified is set if the object was not compared, as its live
+	// resourceVersion equals the one of SubsetDiffOpts.Baseline. Live, Merged
+	// and Diff are empty in that case
+	Unmodified bool
+
+	// Labels of the object, as used by DiffResult.GroupByLabel
+	Labels map[string]string
+
+	// Source is the file the object originates from, if known. Set using
+	// WithSources
+	Source string
+}
+
+// render returns the notes and the diff of the entry
+func (e DiffEntry) render() string {
+	s := ""
+	for _, n := range e.Notes {
+		s += fmt.Sprintf("# %s: %s\n", e.Name, n)
+	}
+	return s + e.Diff
+}
+
+// PostProcessor may modify a DiffResult before it is rendered, e.g. to add
+// notes or drop entries according to custom policies. Returning an error
+// fails the diff.
+type PostProcessor func(*DiffResult) error
+
+// ResourceVersions returns the ResourceVersion of every entry whose object
+// exists in the cluster, keyed by name
+func (r DiffResult) ResourceVersions() map[string]string {
+	versions := make(map[string]string)
+	for _, e := range r.Entries {
+		if e.ResourceVersion != "" {
+			versions[e.Name] = e.ResourceVersion
+		}
+	}
+	return versions
+}
+
+// HasDrift returns whether any of the entries has differences
+func (r DiffResult) HasDrift() bool {
+	for _, e := range r.Entries {
+		if e.Diff != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoredDrift returns the number of objects having drift in ignored fields
+func (r DiffResult) IgnoredDrift() int {
+	n := 0
+	for _, e := range r.Entries {
+		if len(e.IgnoredDrift) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// ignoredSummary states the number of objects with ignored drift, for
+// appending it to a diff. Empty if there are none.
+func (r DiffResult) ignoredSummary(separate bool) string {
+	n := r.IgnoredDrift()
+	if n == 0 {
+		return ""
+	}
+
+	s := fmt.Sprintf("# %d objects have ignored drift\n", n)
+	if n == 1 {
+		s = "# 1 object has ignored drift\n"
+	}
+	if separate {
+		s = "\n" + s
+	}
+	return s
+}
+
+// UnknownDrift returns the names of the entries that could not be compared
+// (see DiffEntry.Unknown)
+func (r DiffResult) UnknownDrift() []string {
+	var names []string
+	for _, e := range r.Entries {
+		if e.Unknown {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// unknownSummary states the objects of UnknownDrift below a diff, so they are
+// not mistaken for unchanged ones. Empty if there are no such objects.
+func (r DiffResult) unknownSummary(separate bool) string {
+	s := ""
+	for _, e := range r.Entries {
+		if e.Unknown {
+			s += fmt.Sprintf("# %s: unknown drift, %s\n", e.Name, strings.Join(e.Notes, ", "))
+		}
+	}
+	if s != "" && separate {
+		s = "\n" + s
+	}
+	return s
+}
+
+// String returns the differences of all entries in `diff(1)` format. It is
+// empty if there are no differences at all.
+func (r DiffResult) String() string {
+	return r.Render(DiffBudget{})
+}
+
+// DiffBudget limits the size of a rendered diff, e.g. to protect CI logs. Zero
+// values mean unlimited.
+type DiffBudget struct {
+	// MaxBytes is the maximum total size of the diff
+	MaxBytes int
+	// MaxObjects is the maximum number of changed objects shown
+	MaxObjects int
+}
+
+// Render is like String, but stops once the budget is exhausted. Only whole
+// objects are shown. If objects were omitted, a marker stating how many were
+// shown is appended.
+func (r DiffResult) Render(budget DiffBudget) string {
+	var diffs string
+	shown, total := 0, 0
+	for _, e := range r.Entries {
+		if e.Diff == "" {
+			continue
+		}
+		total++
+
+		if budget.MaxObjects > 0 && shown >= budget.MaxObjects {
+			continue
+		}
+		d := e.render()
+		if budget.MaxBytes > 0 && len(diffs)+len(d) > budget.MaxBytes {
+			// do not show any further objects, even if they would fit
+			budget.MaxObjects = shown
+			continue
+		}
+
+		diffs += d + "\n"
+		shown++
+	}
+	diffs = strings.TrimSuffix(diffs, "\n")
+
+	if shown < total {
+		diffs += fmt.Sprintf("\ndiff truncated, %d of %d objects shown\n", shown, total)
+	}
+	return diffs
+}
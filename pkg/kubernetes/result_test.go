@@ -0,0 +1,127 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestDiffResultRender(t *testing.T) {
+	result := DiffResult{Entries: []DiffEntry{
+		{Name: "a", Diff: "diff a\n"},
+		{Name: "unchanged"},
+		{Name: "b", Diff: "diff b\n"},
+		{Name: "c", Diff: "diff c\n"},
+	}}
+
+	cases := []struct {
+		name   string
+		budget DiffBudget
+		want   string
+	}{
+		{
+			name: "unlimited",
+			want: "diff a\n\ndiff b\n\ndiff c\n",
+		},
+		{
+			name:   "objects",
+			budget: DiffBudget{MaxObjects: 2},
+			want:   "diff a\n\ndiff b\n\ndiff truncated, 2 of 3 objects shown\n",
+		},
+		{
+			name:   "bytes",
+			budget: DiffBudget{MaxBytes: 10},
+			want:   "diff a\n\ndiff truncated, 1 of 3 objects shown\n",
+		},
+		{
+			name:   "fits",
+			budget: DiffBudget{MaxBytes: 1024, MaxObjects: 3},
+			want:   "diff a\n\ndiff b\n\ndiff c\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := result.Render(c.budget)
+			assert.Equal(t, c.want, got)
+			if c.budget.MaxBytes > 0 {
+				body := strings.Split(got, "\ndiff truncated")[0]
+				assert.LessOrEqual(t, len(body), c.budget.MaxBytes)
+			}
+		})
+	}
+}
+
+func TestDiffResultResourceVersions(t *testing.T) {
+	a := configMap("a", "default", map[string]interface{}{"key": "new"})
+	b := configMap("b", "default", map[string]interface{}{"key": "same"})
+	created := configMap("created", "default", nil)
+
+	liveA := configMap("a", "default", map[string]interface{}{"key": "old"})
+	liveA.Metadata()["resourceVersion"] = "41"
+	liveB := configMap("b", "default", map[string]interface{}{"key": "same"})
+	liveB.Metadata()["resourceVersion"] = "42"
+
+	c := newFakeClient(liveA, liveB)
+	result, err := diffState(c, manifest.List{a, b, created}, SubsetDiffOpts{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 3)
+
+	assert.Equal(t, "41", result.Entries[0].ResourceVersion)
+	assert.Equal(t, "42", result.Entries[1].ResourceVersion)
+	assert.Empty(t, result.Entries[2].ResourceVersion)
+
+	assert.Equal(t, map[string]string{
+		"v1.ConfigMap.default.a": "41",
+		"v1.ConfigMap.default.b": "42",
+	}, result.ResourceVersions())
+}
+
+// ErrorSecretChanged is returned by the post-processor of
+// TestSubsetDifferPostProcessors
+type ErrorSecretChanged struct {
+	Ref ObjectRef
+}
+
+func (e ErrorSecretChanged) Error() string {
+	return "secret changed: " + e.Ref.String()
+}
+
+func TestSubsetDifferPostProcessors(t *testing.T) {
+	noSecretChanges := func(r *DiffResult) error {
+		for _, e := range r.Entries {
+			if e.Ref.Kind == "Secret" && e.Diff != "" {
+				return ErrorSecretChanged{Ref: e.Ref}
+			}
+		}
+		return nil
+	}
+	note := func(r *DiffResult) error {
+		for i := range r.Entries {
+			r.Entries[i].Notes = append(r.Entries[i].Notes, "(reviewed by policy)")
+		}
+		return nil
+	}
+
+	c := newFakeClient(
+		configMap("foo", "default", map[string]interface{}{"key": "old"}),
+		secret("creds", map[string]string{"password": "old"}),
+	)
+	opts := SubsetDiffOpts{PostProcessors: []PostProcessor{noSecretChanges, note}}
+
+	diff, err := SubsetDiffer(c, opts)(manifest.List{configMap("foo", "default", map[string]interface{}{"key": "new"})})
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.True(t, strings.HasPrefix(*diff, "# v1.ConfigMap.default.foo: (reviewed by policy)\n"), *diff)
+
+	_, err = SubsetDiffer(c, opts)(manifest.List{secret("creds", map[string]string{"password": "new"})})
+	assert.Equal(t, ErrorSecretChanged{Ref: ObjectRef{Version: "v1", Kind: "Secret", Namespace: "default", Name: "creds"}}, err)
+
+	// unchanged Secrets pass
+	_, err = SubsetDiffer(c, opts)(manifest.List{secret("creds", map[string]string{"password": "old"})})
+	assert.NoError(t, err)
+}
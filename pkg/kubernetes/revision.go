@@ -0,0 +1,73 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// AnnotationDesiredRevision is the conventional annotation recording the
+// revision the desired state was rendered from, e.g. a commit. See
+// SubsetDiffOpts.RevisionAnnotation
+const AnnotationDesiredRevision = process.MetadataPrefix + "/revision"
+
+// RevisionMismatch records that a live object does not reflect the revision
+// of the desired state
+type RevisionMismatch struct {
+	// Annotation holding the revision
+	Annotation string
+	// Want is the revision of the desired state, Got the one of the cluster.
+	// Got is empty if the live object lacks the annotation
+	Want, Got string
+}
+
+func (r RevisionMismatch) String() string {
+	if r.Got == "" {
+		return fmt.Sprintf("revision mismatch: want %s, but %s is not set in the cluster", r.Want, r.Annotation)
+	}
+	return fmt.Sprintf("revision mismatch: want %s, but the cluster is at %s", r.Want, r.Got)
+}
+
+// revisionMismatch compares the revision recorded in the given annotation of
+// both objects. It returns nil if they match, or if the desired state records
+// no revision.
+func revisionMismatch(local, live manifest.Manifest, annotation string) *RevisionMismatch {
+	annotations, _ := local.Metadata()["annotations"].(map[string]interface{})
+	want, ok := annotations[annotation].(string)
+	if !ok {
+		return nil
+	}
+
+	annotations, _ = live.Metadata()["annotations"].(map[string]interface{})
+	got, _ := annotations[annotation].(string)
+	if got == want {
+		return nil
+	}
+	return &RevisionMismatch{Annotation: annotation, Want: want, Got: got}
+}
+
+// RevisionMismatches returns the entries whose live object does not reflect
+// the desired revision (see SubsetDiffOpts.RevisionAnnotation)
+func (r DiffResult) RevisionMismatches() []DiffEntry {
+	var entries []DiffEntry
+	for _, e := range r.Entries {
+		if e.RevisionMismatch != nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// revisionSummary lists the revision mismatches, for prepending them to a
+// diff. Empty if there are none.
+func (r DiffResult) revisionSummary(separate bool) string {
+	s := ""
+	for _, e := range r.RevisionMismatches() {
+		s += fmt.Sprintf("# %s: %s\n", e.Name, e.RevisionMismatch)
+	}
+	if s != "" && separate {
+		s += "\n"
+	}
+	return s
+}
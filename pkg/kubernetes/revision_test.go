@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSubsetDifferRevisionAnnotation(t *testing.T) {
+	withRevision := func(name, revision string, data map[string]interface{}) manifest.Manifest {
+		m := configMap(name, "default", data)
+		if revision != "" {
+			m.Metadata()["annotations"] = map[string]interface{}{AnnotationDesiredRevision: revision}
+		}
+		return m
+	}
+
+	c := newFakeClient(
+		withRevision("stale", "abc123", map[string]interface{}{"foo": "bar"}),
+		withRevision("current", "def456", map[string]interface{}{"foo": "old"}),
+		withRevision("unset", "", map[string]interface{}{"foo": "bar"}),
+	)
+	state := manifest.List{
+		withRevision("stale", "def456", map[string]interface{}{"foo": "bar"}),
+		withRevision("current", "def456", map[string]interface{}{"foo": "new"}),
+		withRevision("unset", "def456", map[string]interface{}{"foo": "bar"}),
+	}
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{RevisionAnnotation: AnnotationDesiredRevision})(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+
+	// listed above the diff
+	summary := "# v1.ConfigMap.default.stale: revision mismatch: want def456, but the cluster is at abc123\n" +
+		"# v1.ConfigMap.default.unset: revision mismatch: want def456, but tanka.dev/revision is not set in the cluster\n\n"
+	assert.True(t, strings.HasPrefix(*diff, summary), *diff)
+
+	// and noted on the objects
+	assert.Contains(t, *diff, "# v1.ConfigMap.default.stale: (revision mismatch: want def456, but the cluster is at abc123)\n")
+	assert.NotContains(t, *diff, "# v1.ConfigMap.default.current: (revision")
+
+	// not checked by default
+	diff, err = SubsetDiffer(c, SubsetDiffOpts{})(state)
+	require.NoError(t, err)
+	assert.NotContains(t, *diff, "revision mismatch")
+}
+
+func TestRevisionMismatch(t *testing.T) {
+	annotated := func(annotations map[string]interface{}) manifest.Manifest {
+		m := configMap("config", "default", nil)
+		if annotations != nil {
+			m.Metadata()["annotations"] = annotations
+		}
+		return m
+	}
+	rev := func(r string) map[string]interface{} {
+		return map[string]interface{}{AnnotationDesiredRevision: r}
+	}
+
+	cases := []struct {
+		name        string
+		local, live manifest.Manifest
+		want        *RevisionMismatch
+	}{
+		{name: "match", local: annotated(rev("a")), live: annotated(rev("a"))},
+		{name: "not recorded locally", local: annotated(nil), live: annotated(rev("a"))},
+		{
+			name:  "mismatch",
+			local: annotated(rev("b")),
+			live:  annotated(rev("a")),
+			want:  &RevisionMismatch{Annotation: AnnotationDesiredRevision, Want: "b", Got: "a"},
+		},
+		{
+			name:  "missing live",
+			local: annotated(rev("b")),
+			live:  annotated(nil),
+			want:  &RevisionMismatch{Annotation: AnnotationDesiredRevision, Want: "b"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, revisionMismatch(c.local, c.live, AnnotationDesiredRevision))
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// AnnotationRevision is set by the Deployment controller on Deployments and
+// their ReplicaSets to record the rollout revision
+const AnnotationRevision = "deployment.kubernetes.io/revision"
+
+// rolloutComparison compares the pod template of the local Deployment against
+// the one of the ReplicaSet currently active in the cluster. This catches
+// rollouts that are stuck. Returns nil if there is no active ReplicaSet.
+func rolloutComparison(c client.Client, local, live manifest.Manifest) (*comparison, error) {
+	rs, err := activeReplicaSet(c, live)
+	if err != nil {
+		return nil, errors.Wrapf(err, "finding active ReplicaSet of %s", live.KindName())
+	}
+	if rs == nil {
+		return nil, nil
+	}
+
+	spec, _ := local["spec"].(map[string]interface{})
+	template, ok := spec["template"]
+	if !ok {
+		return nil, nil
+	}
+
+	// the desired state of the ReplicaSet is the pod template of the Deployment
+	should := manifest.Manifest{
+		"apiVersion": rs.APIVersion(),
+		"kind":       rs.Kind(),
+		"metadata": map[string]interface{}{
+			"name":      rs.Metadata().Name(),
+			"namespace": rs.Metadata().Namespace(),
+		},
+		"spec": map[string]interface{}{
+			"template": template,
+		},
+	}
+
+	return &comparison{local: should, live: rs}, nil
+}
+
+// activeReplicaSet returns the ReplicaSet owned by the given live Deployment,
+// that matches the Deployment's current revision
+func activeReplicaSet(c client.Client, deploy manifest.Manifest) (manifest.Manifest, error) {
+	revision, ok := deploy.Metadata().Annotations()[AnnotationRevision]
+	if !ok {
+		return nil, nil
+	}
+
+	selector := map[string]string{}
+	if spec, ok := deploy["spec"].(map[string]interface{}); ok {
+		if sel, ok := spec["selector"].(map[string]interface{}); ok {
+			if labels, ok := sel["matchLabels"].(map[string]interface{}); ok {
+				for k, v := range labels {
+					selector[k], _ = v.(string)
+				}
+			}
+		}
+	}
+
+	list, err := c.GetByLabels(deploy.Metadata().Namespace(), "ReplicaSet", selector)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rs := range list {
+		if !ownedBy(rs, deploy) {
+			continue
+		}
+		if rs.Metadata().Annotations()[AnnotationRevision] == revision {
+			return rs, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,100 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSubsetDifferRollout(t *testing.T) {
+	local := deploymentWithImage("grafana/grafana:7.3.0")
+
+	live := deploymentWithImage("grafana/grafana:7.3.0")
+	live.Metadata()["uid"] = "d-uid"
+	live.Metadata()["annotations"] = map[string]interface{}{AnnotationRevision: "2"}
+
+	// previous revision, must be ignored
+	old := replicaSet("grafana-old", "grafana/grafana:7.2.0", "1", "d-uid")
+	// active revision, rollout is stuck on an old image
+	active := replicaSet("grafana-new", "grafana/grafana:7.1.0", "2", "d-uid")
+	// same labels, but not owned by the Deployment
+	foreign := replicaSet("grafana-foreign", "grafana/grafana:6.0.0", "2", "other-uid")
+
+	c := newFakeClient(live, old, active, foreign)
+
+	t.Run("disabled", func(t *testing.T) {
+		diff, err := SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{local})
+		require.NoError(t, err)
+		assert.Nil(t, diff)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		diff, err := SubsetDiffer(c, SubsetDiffOpts{WithRollout: true})(manifest.List{local})
+		require.NoError(t, err)
+		require.NotNil(t, diff)
+
+		assert.Contains(t, *diff, "apps-v1.ReplicaSet.default.grafana-new")
+		assert.Contains(t, *diff, "-      - image: grafana/grafana:7.1.0")
+		assert.Contains(t, *diff, "+      - image: grafana/grafana:7.3.0")
+		assert.NotContains(t, *diff, "grafana-old")
+		assert.NotContains(t, *diff, "grafana-foreign")
+		// controller-managed label must not show up
+		assert.NotContains(t, *diff, "pod-template-hash")
+	})
+}
+
+func deploymentWithImage(image string) manifest.Manifest {
+	return manifest.Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "grafana",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "grafana"},
+			},
+			"template": podTemplate(image),
+		},
+	}
+}
+
+func replicaSet(name, image, revision, ownerUID string) manifest.Manifest {
+	template := podTemplate(image)
+	template["metadata"].(map[string]interface{})["labels"].(map[string]interface{})["pod-template-hash"] = name
+
+	return manifest.Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "ReplicaSet",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   "default",
+			"labels":      map[string]interface{}{"app": "grafana"},
+			"annotations": map[string]interface{}{AnnotationRevision: revision},
+			"ownerReferences": []interface{}{
+				map[string]interface{}{"kind": "Deployment", "name": "grafana", "uid": ownerUID},
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": 1,
+			"template": template,
+		},
+	}
+}
+
+func podTemplate(image string) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"app": "grafana"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "grafana", "image": image},
+			},
+		},
+	}
+}
@@ -0,0 +1,24 @@
+package kubernetes
+
+import "github.com/grafana/tanka/pkg/kubernetes/manifest"
+
+// Sanitizer rewrites manifests before they are compared, allowing to implement
+// custom redaction policies (e.g. masking annotations or hashing tokens). It is
+// applied to both the desired and the live state. Sanitizers receive a copy
+// they may modify freely.
+type Sanitizer interface {
+	Sanitize(m manifest.Manifest) (manifest.Manifest, error)
+}
+
+// SanitizerFunc allows to use an ordinary function as a Sanitizer
+type SanitizerFunc func(m manifest.Manifest) (manifest.Manifest, error)
+
+// Sanitize calls f(m)
+func (f SanitizerFunc) Sanitize(m manifest.Manifest) (manifest.Manifest, error) {
+	return f(m)
+}
+
+// NopSanitizer returns all manifests unchanged. It is the default.
+var NopSanitizer Sanitizer = SanitizerFunc(func(m manifest.Manifest) (manifest.Manifest, error) {
+	return m, nil
+})
@@ -0,0 +1,43 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSanitizer(t *testing.T) {
+	// masks the token, but keeps everything else
+	mask := SanitizerFunc(func(m manifest.Manifest) (manifest.Manifest, error) {
+		if data, ok := m["data"].(map[string]interface{}); ok {
+			if _, ok := data["token"]; ok {
+				data["token"] = "<masked>"
+			}
+		}
+		return m, nil
+	})
+
+	local := configMap("creds", "default", map[string]interface{}{"token": "secret-new", "user": "admin"})
+	live := configMap("creds", "default", map[string]interface{}{"token": "secret-old", "user": "root"})
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{Sanitizer: mask})
+	require.NoError(t, err)
+	e := result.Entries[0]
+
+	assert.Contains(t, e.Live, "token: <masked>")
+	assert.Contains(t, e.Merged, "token: <masked>")
+	assert.NotContains(t, e.Diff, "secret")
+	assert.Contains(t, e.Diff, "-  user: root")
+	assert.Contains(t, e.Diff, "+  user: admin")
+
+	// the desired state is left untouched
+	assert.Equal(t, "secret-new", local["data"].(map[string]interface{})["token"])
+
+	// default is no-op
+	result, err = DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{})
+	require.NoError(t, err)
+	assert.Contains(t, result.Entries[0].Diff, "+  token: secret-new")
+}
@@ -0,0 +1,40 @@
+package kubernetes
+
+import (
+	"log"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+)
+
+// warnf reports degraded functionality to the user. Replaceable for testing
+var warnf = log.Printf
+
+// fetchSchema retrieves the OpenAPI schema of the cluster. All schema-aware
+// features must use it: if the schema is unavailable (missing RBAC
+// permissions, old clusters), they have to degrade to the plain subset
+// behavior instead of failing the diff. Failures are therefore only reported
+// as a warning, and nil is returned.
+func fetchSchema(c client.Client) []byte {
+	data, err := c.OpenAPISchema()
+	if err != nil {
+		warnf("Warning: fetching the OpenAPI schema failed, falling back to subset diff: %s", err)
+		return nil
+	}
+	return data
+}
+
+// detectListTypes returns the ListTypes of the cluster, or nil if they cannot
+// be obtained. Never fails, see fetchSchema.
+func detectListTypes(c client.Client) ListTypes {
+	data := fetchSchema(c)
+	if data == nil {
+		return nil
+	}
+
+	types, err := ParseListTypes(data)
+	if err != nil {
+		warnf("Warning: parsing the OpenAPI schema failed, falling back to subset diff: %s", err)
+		return nil
+	}
+	return types
+}
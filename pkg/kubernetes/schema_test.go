@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestDetectListTypesFallback(t *testing.T) {
+	var warnings []string
+	orig := warnf
+	warnf = func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+	defer func() { warnf = orig }()
+
+	data, err := ioutil.ReadFile("testdata/openapi/service.json")
+	require.NoError(t, err)
+
+	local := service([]interface{}{port("http", 80, "TCP"), port("dns", 53, "UDP")}, nil)
+	live := service([]interface{}{port("dns", 53, "UDP"), port("http", 80, "TCP")}, nil)
+	opts := SubsetDiffOpts{DetectListTypes: true}
+
+	cases := []struct {
+		name      string
+		schema    []byte
+		schemaErr error
+		changed   bool
+		warning   string
+	}{
+		{
+			name:   "available",
+			schema: data,
+		},
+		{
+			name:      "forbidden",
+			schemaErr: errors.New(`Error from server (Forbidden): forbidden: User "ci" cannot get path "/openapi/v2"`),
+			changed:   true,
+			warning:   "Warning: fetching the OpenAPI schema failed, falling back to subset diff: Error from server (Forbidden)",
+		},
+		{
+			name:    "invalid",
+			schema:  []byte("<html>"),
+			changed: true,
+			warning: "Warning: parsing the OpenAPI schema failed, falling back to subset diff",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			warnings = nil
+			f := newFakeClient(live)
+			f.schema, f.schemaErr = c.schema, c.schemaErr
+
+			diff, err := SubsetDiffer(f, opts)(manifest.List{local})
+			require.NoError(t, err)
+			assert.Equal(t, c.changed, diff != nil)
+			assert.Len(t, f.CallsWith("openapi"), 1)
+
+			if c.warning == "" {
+				assert.Empty(t, warnings)
+				return
+			}
+			require.Len(t, warnings, 1)
+			assert.Contains(t, warnings[0], c.warning)
+		})
+	}
+}
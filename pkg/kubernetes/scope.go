@@ -0,0 +1,83 @@
+package kubernetes
+
+import (
+	"sync"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// scopes determines whether objects are namespaced using API discovery instead
+// of a static list of kinds, so it is correct for arbitrary CRDs. Discovery is
+// performed once and then cached.
+type scopes struct {
+	c client.Client
+
+	once      sync.Once
+	resources client.Resources
+	err       error
+
+	// declared holds the namespaces created by the desired state
+	declared map[string]bool
+	// crds holds the kinds declared by the desired state, if
+	// SubsetDiffOpts.AwaitCRDs is set (see declaredKinds)
+	crds map[string]bool
+
+	nsOnce     sync.Once
+	namespaces map[string]bool
+	nsErr      error
+}
+
+func newScopes(c client.Client, state manifest.List) *scopes {
+	declared := make(map[string]bool)
+	for _, m := range state {
+		if m.Kind() == "Namespace" {
+			declared[m.Metadata().Name()] = true
+		}
+	}
+	return &scopes{c: c, declared: declared}
+}
+
+// namespace returns the namespace m needs to be requested from. It is empty for
+// cluster-wide objects, even if they have a namespace set. If discovery fails or
+// does not know the kind, the namespace of the object is used as is.
+func (s *scopes) namespace(m manifest.Manifest) string {
+	if s.discover() != nil {
+		return m.Metadata().Namespace()
+	}
+
+	res, ok := s.resources.Lookup(m)
+	if ok && !res.Namespaced {
+		return ""
+	}
+	return m.Metadata().Namespace()
+}
+
+// discover performs API discovery, unless already done
+func (s *scopes) discover() error {
+	s.once.Do(func() {
+		s.resources, s.err = s.c.Resources()
+	})
+	return s.err
+}
+
+// pending returns whether m is to be created in a namespace declared by the
+// desired state, that does not exist in the cluster yet, or is of a kind
+// whose CRD is not established yet (see awaitsCRD). Such objects cannot exist
+// either, and requesting them may fail. If the namespaces of the cluster
+// cannot be listed, false is returned.
+func (s *scopes) pending(m manifest.Manifest) bool {
+	if len(s.crds) > 0 && s.discover() == nil && awaitsCRD(m, s.crds, s.resources) {
+		return true
+	}
+
+	ns := s.namespace(m)
+	if ns == "" || !s.declared[ns] {
+		return false
+	}
+
+	s.nsOnce.Do(func() {
+		s.namespaces, s.nsErr = s.c.Namespaces()
+	})
+	return s.nsErr == nil && !s.namespaces[ns]
+}
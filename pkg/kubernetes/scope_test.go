@@ -0,0 +1,91 @@
+package kubernetes
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSubsetDifferScopeDiscovery(t *testing.T) {
+	// cluster-wide CRD, but a namespace was injected because the kind is
+	// unknown to Tanka's static list
+	clusterLocal := m("example.com/v1", "ClusterWidget", "global", "default")
+	clusterLive := m("example.com/v1", "ClusterWidget", "global", "")
+
+	// namespaced CRD
+	nsLocal := m("other.io/v1", "Widget", "local", "team")
+	nsLive := m("other.io/v1", "Widget", "local", "team")
+
+	c := newFakeClient(clusterLive, nsLive)
+	c.resources = client.Resources{
+		{APIGroup: "example.com", Kind: "ClusterWidget", Namespaced: false},
+		{APIGroup: "other.io", Kind: "Widget", Namespaced: true},
+	}
+
+	_, err := SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{clusterLocal, nsLocal})
+	require.NoError(t, err)
+
+	calls := c.Calls()
+	assert.Contains(t, calls, "get  ClusterWidget global")
+	assert.Contains(t, calls, "get team Widget local")
+
+	// discovery is cached
+	n := 0
+	for _, call := range calls {
+		if call == "resources" {
+			n++
+		}
+	}
+	assert.Equal(t, 1, n)
+}
+
+// TestSubsetDifferNewNamespace asserts objects in a namespace created by the
+// same environment are shown as created, instead of failing to fetch them
+func TestSubsetDifferNewNamespace(t *testing.T) {
+	ns := manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "new"},
+	}
+	cm := configMap("foo", "new", map[string]interface{}{"key": "value"})
+	existing := configMap("bar", "default", map[string]interface{}{"key": "value"})
+
+	c := newFakeClient(existing, manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "default"},
+	})
+	c.resources = client.Resources{
+		{Kind: "Namespace", Namespaced: false},
+		{Kind: "ConfigMap", Namespaced: true},
+	}
+	c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		if namespace == "new" {
+			return nil, errors.New(`namespaces "new" not found`)
+		}
+		if kind == "Namespace" {
+			return nil, client.ErrorNotFound{}
+		}
+		return manifest.Manifest(copyMSI(existing)), nil
+	}
+
+	result, err := diffState(c, manifest.List{ns, cm, existing}, SubsetDiffOpts{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 3)
+
+	for _, e := range result.Entries[:2] {
+		assert.Empty(t, e.Live, e.Name)
+		assert.NotEmpty(t, e.Diff, e.Name)
+	}
+	assert.Contains(t, result.Entries[1].Diff, "+  namespace: new")
+	assert.Empty(t, result.Entries[2].Diff)
+
+	// objects of the new namespace are not requested at all
+	assert.NotContains(t, c.Calls(), "get new ConfigMap foo")
+	assert.Len(t, c.CallsWith("namespaces"), 1)
+}
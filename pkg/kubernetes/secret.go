@@ -0,0 +1,91 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// Masks replacing the values of Secrets if SubsetDiffOpts.MaskSecrets is set
+const (
+	MaskUnchanged = "<unchanged>"
+	MaskChanged   = "<changed>"
+	MaskAdded     = "<added>"
+	MaskRedacted  = "<redacted>"
+)
+
+// maskSecret replaces the values of the Secret local and its live counterpart
+// with masks, so changes are visible without revealing any value. Values are
+// compared decoded, and `stringData` of local is taken into account.
+func maskSecret(local, live manifest.Manifest) {
+	should := secretValues(local)
+	delete(local, "stringData")
+
+	var is map[string]string
+	if live != nil {
+		is = secretValues(live)
+		delete(live, "stringData")
+	}
+
+	localData := make(map[string]interface{}, len(should))
+	liveData := make(map[string]interface{}, len(is))
+	for k, v := range should {
+		old, ok := is[k]
+		switch {
+		case !ok:
+			localData[k] = MaskAdded
+		case old == v:
+			localData[k], liveData[k] = MaskUnchanged, MaskUnchanged
+		default:
+			localData[k], liveData[k] = MaskChanged, MaskRedacted
+		}
+	}
+	for k := range is {
+		if _, ok := should[k]; !ok {
+			liveData[k] = MaskRedacted
+		}
+	}
+
+	if len(localData) > 0 || local["data"] != nil {
+		local["data"] = localData
+	}
+	if live != nil && (len(liveData) > 0 || live["data"] != nil) {
+		live["data"] = liveData
+	}
+}
+
+// maskRemovedSecret returns a copy of the Secret m, that is about to be
+// removed, with its values masked. The last-applied-configuration annotation
+// is masked as well, as it holds them too.
+func maskRemovedSecret(m manifest.Manifest) manifest.Manifest {
+	m = manifest.Manifest(copyMSI(m))
+	maskSecret(manifest.Manifest{}, m)
+
+	meta, _ := m["metadata"].(map[string]interface{})
+	annotations, _ := meta["annotations"].(map[string]interface{})
+	if _, ok := annotations[AnnotationLastApplied]; ok {
+		annotations[AnnotationLastApplied] = MaskRedacted
+	}
+	return m
+}
+
+// secretValues returns the decoded values of the Secret m. Values that are no
+// valid base64 are used as is.
+func secretValues(m manifest.Manifest) map[string]string {
+	values := make(map[string]string)
+	if data, ok := m["data"].(map[string]interface{}); ok {
+		for k, v := range data {
+			s, _ := v.(string)
+			if dec, err := base64.StdEncoding.DecodeString(s); err == nil {
+				s = string(dec)
+			}
+			values[k] = s
+		}
+	}
+	if data, ok := m["stringData"].(map[string]interface{}); ok {
+		for k, v := range data {
+			values[k], _ = v.(string)
+		}
+	}
+	return values
+}
@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func secret(name string, data map[string]string) manifest.Manifest {
+	encoded := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		encoded[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	return manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"data":       encoded,
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	local := secret("creds", map[string]string{
+		"username": "admin",
+		"password": "hunter3",
+		"token":    "new-token",
+	})
+	local["stringData"] = map[string]interface{}{"url": "https://example.com"}
+
+	live := secret("creds", map[string]string{
+		"username": "admin",
+		"password": "hunter2",
+		"url":      "https://example.com",
+	})
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{MaskSecrets: true})
+	require.NoError(t, err)
+
+	e := result.Entries[0]
+	assert.Contains(t, e.Diff, "-  password: <redacted>")
+	assert.Contains(t, e.Diff, "+  password: <changed>")
+	assert.Contains(t, e.Diff, "+  token: <added>")
+	assert.Contains(t, e.Diff, "   username: <unchanged>")
+	assert.Contains(t, e.Diff, "   url: <unchanged>")
+
+	for _, s := range []string{"hunter", "admin", "token: bmV3", "aHVudGVy", "example.com"} {
+		assert.NotContains(t, e.Diff, s)
+		assert.NotContains(t, e.Live, s)
+		assert.NotContains(t, e.Merged, s)
+	}
+
+	// inputs are not modified
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("hunter3")), local["data"].(map[string]interface{})["password"])
+
+	t.Run("unchanged", func(t *testing.T) {
+		result, err := DiffAgainst(manifest.List{live}, manifest.List{live}, SubsetDiffOpts{MaskSecrets: true})
+		require.NoError(t, err)
+		assert.Empty(t, result.Entries[0].Diff)
+	})
+
+	t.Run("created", func(t *testing.T) {
+		result, err := DiffAgainst(manifest.List{local}, nil, SubsetDiffOpts{MaskSecrets: true})
+		require.NoError(t, err)
+		assert.Contains(t, result.Entries[0].Diff, "+  password: <added>")
+		assert.NotContains(t, result.Entries[0].Diff, "aHVudGVy")
+	})
+}
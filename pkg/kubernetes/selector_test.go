@@ -0,0 +1,81 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+func TestSubsetDifferSelector(t *testing.T) {
+	env := func(m manifest.Manifest, name string) manifest.Manifest {
+		m.Metadata().Labels()[process.LabelEnvironment] = name
+		m.Metadata().Annotations()[AnnotationLastApplied] = "{}"
+		m.Metadata()["resourceVersion"] = "1"
+		return m
+	}
+
+	c := newFakeClient(
+		env(configMap("mine", "default", map[string]interface{}{"foo": "bar"}), "prod"),
+		env(configMap("shared", "default", map[string]interface{}{"foo": "bar"}), "dev"),
+		env(configMap("orphan-mine", "default", nil), "prod"),
+		env(configMap("orphan-other", "default", nil), "dev"),
+	)
+	c.resources = client.Resources{
+		{Kind: "ConfigMap", Namespaced: true, Verbs: "[get list]"},
+	}
+
+	selector := map[string]string{process.LabelEnvironment: "prod"}
+	opts := SubsetDiffOpts{
+		Selector:      selector,
+		PruneSelector: map[string]string{},
+		Cache:         FileDiffCache{Dir: t.TempDir()},
+	}
+	state := manifest.List{
+		configMap("mine", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("shared", "default", map[string]interface{}{"foo": "bar"}),
+	}
+
+	result, err := diffState(c, state, opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"getByState 2 map[tanka.dev/environment:prod]"}, c.CallsWith("getByState"))
+	assert.Equal(t, []string{"list  ConfigMap map[tanka.dev/environment:prod]"}, c.CallsWith("list"))
+
+	var pruned []string
+	for _, e := range result.Entries {
+		if e.Prune {
+			pruned = append(pruned, e.Name)
+		}
+	}
+	assert.Equal(t, []string{"v1.ConfigMap.default.orphan-mine"}, pruned)
+}
+
+func TestSubsetDifferSelectorCache(t *testing.T) {
+	// not labelled yet, so its resourceVersion is not returned by the
+	// selected query
+	live := configMap("unlabelled", "default", map[string]interface{}{"foo": "old"})
+	live.Metadata()["resourceVersion"] = "1"
+	c := newFakeClient(live)
+
+	opts := SubsetDiffOpts{
+		Selector: map[string]string{process.LabelEnvironment: "prod"},
+		Cache:    FileDiffCache{Dir: t.TempDir()},
+	}
+	state := manifest.List{configMap("unlabelled", "default", map[string]interface{}{"foo": "new"})}
+
+	diff, err := SubsetDiffer(c, opts)(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+
+	// changed in the cluster, the diff must reflect that
+	c.objects[0] = configMap("unlabelled", "default", map[string]interface{}{"foo": "new"})
+	c.objects[0].Metadata()["resourceVersion"] = "2"
+	diff, err = SubsetDiffer(c, opts)(state)
+	require.NoError(t, err)
+	assert.Nil(t, diff)
+}
@@ -0,0 +1,81 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// flatSerializer renders one `path=value` line per scalar
+type flatSerializer struct{}
+
+func (flatSerializer) Marshal(m map[string]interface{}) (string, error) {
+	var lines []string
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, v := range t {
+				walk(prefix+"."+k, v)
+			}
+		case manifest.Manifest:
+			walk(prefix, map[string]interface{}(t))
+		default:
+			lines = append(lines, fmt.Sprintf("%s=%v", strings.TrimPrefix(prefix, "."), t))
+		}
+	}
+	walk("", m)
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func TestSubsetDifferSerializer(t *testing.T) {
+	f := newFakeClient(configMap("foo", "default", map[string]interface{}{"a": "old"}))
+	state := manifest.List{
+		configMap("foo", "default", map[string]interface{}{"a": "new"}),
+		configMap("created", "default", map[string]interface{}{"b": "1"}),
+	}
+
+	cases := []struct {
+		name       string
+		serializer manifest.Serializer
+		contains   []string
+	}{
+		{
+			name:     "default",
+			contains: []string{"-  a: old\n", "+  a: new\n", "+  b: \"1\"\n"},
+		},
+		{
+			name:       "yaml",
+			serializer: manifest.Encoder{QuoteMultiline: true},
+			contains:   []string{"-  a: old\n", "+  a: new\n"},
+		},
+		{
+			name:       "json",
+			serializer: manifest.JSONEncoder{},
+			contains:   []string{`-    "a": "old"`, `+    "a": "new"`, `+    "b": "1"`},
+		},
+		{
+			name:       "custom",
+			serializer: flatSerializer{},
+			contains:   []string{"-data.a=old\n", "+data.a=new\n", "+data.b=1\n", " metadata.name=foo\n"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, err := SubsetDiffer(f, SubsetDiffOpts{Serializer: c.serializer})(state)
+			require.NoError(t, err)
+			require.NotNil(t, d)
+			for _, s := range c.contains {
+				assert.Contains(t, *d, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package kubernetes
+
+import (
+	"time"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// settle waits for opts.Settle and then fetches all objects of state that
+// show drift in perObject once more. Drift caused by controllers mutating
+// freshly applied objects is usually gone by then, so only drift persisting
+// across both reads is reported.
+func settle(c client.Client, state manifest.List, perObject [][]comparison, opts SubsetDiffOpts) ([][]comparison, error) {
+	s := opts.subsetter()
+	var drifted []int
+	for i, cs := range perObject {
+		for _, cmp := range cs {
+			if cmp.unknown() != "" {
+				continue
+			}
+
+			// compare() modifies live, which is compared again later
+			live := cmp.live
+			if live != nil {
+				live = manifest.Manifest(copyMSI(live))
+			}
+			entry, err := s.compare(cmp.local, live)
+			if err != nil {
+				return nil, ErrorDiff{Ref: RefOf(cmp.local), Phase: DiffPhaseRender, Err: err}
+			}
+			if entry.Live != entry.Merged {
+				drifted = append(drifted, i)
+				break
+			}
+		}
+	}
+
+	if len(drifted) == 0 {
+		return perObject, nil
+	}
+
+	time.Sleep(opts.Settle)
+
+	again := make(manifest.List, 0, len(drifted))
+	for _, i := range drifted {
+		again = append(again, state[i])
+	}
+	refetched, err := fetchLive(c, again, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range drifted {
+		perObject[i] = refetched[j]
+	}
+	return perObject, nil
+}
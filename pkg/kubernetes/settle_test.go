@@ -0,0 +1,106 @@
+package kubernetes
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// flappingClient returns first for the first Get of each object and then
+// always second
+func flappingClient(first, second manifest.Manifest) *fakeClient {
+	var mu sync.Mutex
+	seen := map[string]bool{}
+
+	f := newFakeClient()
+	f.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := kind + "/" + namespace + "/" + name
+		if !seen[key] {
+			seen[key] = true
+			return manifest.Manifest(copyMSI(first)), nil
+		}
+		return manifest.Manifest(copyMSI(second)), nil
+	}
+	return f
+}
+
+func TestSubsetDifferSettle(t *testing.T) {
+	local := configMap("foo", "default", map[string]interface{}{"a": "b"})
+	drifted := configMap("foo", "default", map[string]interface{}{"a": "transient"})
+
+	opts := SubsetDiffOpts{Settle: time.Millisecond}
+
+	t.Run("transient", func(t *testing.T) {
+		c := flappingClient(drifted, local)
+		d, err := SubsetDiffer(c, opts)(manifest.List{local})
+		require.NoError(t, err)
+		assert.Nil(t, d)
+		assert.Equal(t, 2, c.Gets())
+
+		// without settling, the transient drift is reported
+		c = flappingClient(drifted, local)
+		d, err = SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{local})
+		require.NoError(t, err)
+		require.NotNil(t, d)
+		assert.Equal(t, 1, c.Gets())
+	})
+
+	t.Run("persistent", func(t *testing.T) {
+		c := flappingClient(drifted, drifted)
+		d, err := SubsetDiffer(c, opts)(manifest.List{local})
+		require.NoError(t, err)
+		require.NotNil(t, d)
+		assert.Contains(t, *d, "-  a: transient")
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		c := flappingClient(drifted, local)
+		d, err := SubsetDiffer(c, SubsetDiffOpts{Settle: time.Millisecond, Cache: FileDiffCache{Dir: t.TempDir()}})(manifest.List{local})
+		require.NoError(t, err)
+		assert.Nil(t, d)
+	})
+
+	t.Run("no drift", func(t *testing.T) {
+		c := flappingClient(local, local)
+		d, err := SubsetDiffer(c, opts)(manifest.List{local})
+		require.NoError(t, err)
+		assert.Nil(t, d)
+		assert.Equal(t, 1, c.Gets())
+	})
+}
+
+func TestSettleLeavesComparisonsIntact(t *testing.T) {
+	local := configMap("foo", "default", map[string]interface{}{"a": "b"})
+	drifted := configMap("foo", "default", map[string]interface{}{"a": "transient"})
+	stable := configMap("bar", "default", map[string]interface{}{"a": "b"})
+	stable.Metadata()["resourceVersion"] = "11"
+
+	flapping := flappingClient(drifted, local)
+	c := newFakeClient()
+	c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		if name == "bar" {
+			return manifest.Manifest(copyMSI(stable)), nil
+		}
+		return flapping.getFunc(namespace, kind, name)
+	}
+
+	var metrics DiffMetrics
+	state := manifest.List{local, configMap("bar", "default", map[string]interface{}{"a": "b"})}
+	result, err := diffState(c, state, SubsetDiffOpts{Settle: time.Millisecond, RecordPruned: true, Metrics: &metrics})
+	require.NoError(t, err)
+
+	require.Len(t, result.Entries, 2)
+	bar := result.Entries[1]
+	require.Equal(t, "bar", bar.Ref.Name)
+	assert.Equal(t, "11", bar.ResourceVersion)
+	assert.Contains(t, bar.Pruned, "metadata.resourceVersion")
+	assert.Equal(t, int64(2), metrics.Summary().Counters[MetricObjects])
+}
@@ -0,0 +1,28 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// AnnotationSortKeys can be set on any object to render the listed keys
+// first, in the given order, e.g. `apiVersion,kind,metadata,spec`. Other keys
+// follow in their usual order. It applies to both states, so no changes are
+// introduced, and it is removed from the object before diffing.
+const AnnotationSortKeys = process.MetadataPrefix + "/sort-keys"
+
+// sortKeys returns the keys listed in AnnotationSortKeys of m, or nil
+func sortKeys(m manifest.Manifest) []string {
+	annotations, _ := m.Metadata()["annotations"].(map[string]interface{})
+	s, _ := annotations[AnnotationSortKeys].(string)
+
+	var keys []string
+	for _, k := range strings.Split(s, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
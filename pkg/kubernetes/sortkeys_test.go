@@ -0,0 +1,61 @@
+package kubernetes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSortKeys(t *testing.T) {
+	sorted := func(data map[string]interface{}) manifest.Manifest {
+		m := configMap("config", "default", data)
+		m.Metadata()["annotations"] = map[string]interface{}{
+			AnnotationSortKeys: "kind, apiVersion,metadata,name",
+		}
+		return m
+	}
+
+	// the annotation is only set locally, the ordering applies to both sides
+	r, err := DiffAgainst(
+		manifest.List{sorted(map[string]interface{}{"foo": "new"})},
+		manifest.List{configMap("config", "default", map[string]interface{}{"foo": "old"})},
+		SubsetDiffOpts{},
+	)
+	require.NoError(t, err)
+	require.Len(t, r.Entries, 1)
+
+	e := r.Entries[0]
+	want := `kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: config
+  namespace: default
+data:
+  foo: %s
+`
+	assert.Equal(t, fmt.Sprintf(want, "old"), e.Live)
+	assert.Equal(t, fmt.Sprintf(want, "new"), e.Merged)
+	assert.NotContains(t, e.Diff, "kind")
+	assert.NotContains(t, e.Diff, AnnotationSortKeys)
+
+	// no churn
+	r, err = DiffAgainst(
+		manifest.List{sorted(map[string]interface{}{"foo": "bar"})},
+		manifest.List{sorted(map[string]interface{}{"foo": "bar"})},
+		SubsetDiffOpts{},
+	)
+	require.NoError(t, err)
+	assert.False(t, r.HasDrift())
+}
+
+func TestSortKeysParse(t *testing.T) {
+	m := configMap("config", "default", nil)
+	assert.Nil(t, sortKeys(m))
+
+	m.Metadata()["annotations"] = map[string]interface{}{AnnotationSortKeys: " spec ,, kind,"}
+	assert.Equal(t, []string{"spec", "kind"}, sortKeys(m))
+}
@@ -0,0 +1,36 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// replicaKinds are the workloads reporting their replica counts in status
+var replicaKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"ReplicaSet":  true,
+}
+
+// statusReplicaFields are the replica counts of replicaKinds shown by
+// SubsetDiffOpts.ShowStatusReplicas, in this order
+var statusReplicaFields = []string{"replicas", "readyReplicas", "availableReplicas", "updatedReplicas"}
+
+// statusReplicas returns the replica counts of the live workload, like
+// `replicas=3, readyReplicas=2`. Empty for other kinds or if none are set.
+func statusReplicas(live manifest.Manifest) string {
+	if !replicaKinds[live.Kind()] {
+		return ""
+	}
+
+	status, _ := live["status"].(map[string]interface{})
+	var counts []string
+	for _, f := range statusReplicaFields {
+		if v, ok := status[f]; ok {
+			counts = append(counts, fmt.Sprintf("%s=%v", f, v))
+		}
+	}
+	return strings.Join(counts, ", ")
+}
@@ -0,0 +1,81 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/spec/v1alpha1"
+)
+
+func deploymentWithStatus() manifest.Manifest {
+	m := deploymentWithImage("grafana/grafana:7.0.0")
+	m["status"] = map[string]interface{}{
+		"replicas":           float64(3),
+		"readyReplicas":      float64(2),
+		"observedGeneration": float64(7),
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Available", "status": "True"},
+		},
+	}
+	return m
+}
+
+func TestSubsetDifferShowStatusReplicas(t *testing.T) {
+	c := newFakeClient(deploymentWithStatus())
+	state := manifest.List{deploymentWithImage("grafana/grafana:7.1.0")}
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{ShowStatusReplicas: true})(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Contains(t, *diff, "# apps-v1.Deployment.default.grafana: (status: replicas=3, readyReplicas=2)\n")
+	assert.NotContains(t, *diff, "observedGeneration")
+	assert.NotContains(t, *diff, "conditions")
+	assert.NotContains(t, *diff, "status:\n")
+
+	diff, err = SubsetDiffer(c, SubsetDiffOpts{})(state)
+	require.NoError(t, err)
+	assert.NotContains(t, *diff, "replicas=")
+
+	// counts are never compared
+	diff, err = SubsetDiffer(c, SubsetDiffOpts{ShowStatusReplicas: true})(manifest.List{deploymentWithImage("grafana/grafana:7.0.0")})
+	require.NoError(t, err)
+	assert.Nil(t, diff)
+}
+
+func TestStatusReplicas(t *testing.T) {
+	assert.Equal(t, "replicas=3, readyReplicas=2", statusReplicas(deploymentWithStatus()))
+	assert.Equal(t, "", statusReplicas(deploymentWithImage("grafana/grafana:7.0.0")))
+
+	cm := configMap("config", "default", nil)
+	cm["status"] = map[string]interface{}{"replicas": float64(1)}
+	assert.Equal(t, "", statusReplicas(cm))
+}
+
+func TestDiffShowStatusReplicas(t *testing.T) {
+	c := newFakeClient(
+		deploymentWithStatus(),
+		manifest.Manifest{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "default"}},
+	)
+	c.info = client.Info{ClientVersion: semver.MustParse("1.20.0"), ServerVersion: semver.MustParse("1.20.0")}
+	c.resources = client.Resources{{Kind: "Deployment", APIGroup: "apps", Namespaced: true, Verbs: "[get list]"}}
+
+	env := v1alpha1.New()
+	env.Spec.DiffStrategy = "subset"
+	k := newKubernetes(*env, c)
+
+	state := manifest.List{deploymentWithImage("grafana/grafana:7.1.0")}
+	d, err := k.Diff(state, DiffOpts{ShowStatusReplicas: true})
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Contains(t, *d, "(status: replicas=3, readyReplicas=2)")
+
+	d, err = k.Diff(state, DiffOpts{})
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.NotContains(t, *d, "(status:")
+}
@@ -0,0 +1,119 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// AnnotationDiffStrategy can be set on any object to change how it is diffed.
+// It is removed from the object before diffing.
+const AnnotationDiffStrategy = process.MetadataPrefix + "/diff-strategy"
+
+// Per-object diff strategies, as set using AnnotationDiffStrategy
+const (
+	// ObjectStrategyNone skips the object entirely
+	ObjectStrategyNone = "none"
+	// ObjectStrategySubset only compares the fields present locally (default)
+	ObjectStrategySubset = "subset"
+	// ObjectStrategyExact compares all fields, including cluster-only ones
+	ObjectStrategyExact = "exact"
+)
+
+// objectStrategies lists all valid per-object diff strategies
+var objectStrategies = []string{ObjectStrategyNone, ObjectStrategySubset, ObjectStrategyExact}
+
+// objectStrategy returns the diff strategy requested for m using
+// AnnotationDiffStrategy. Objects lacking the annotation use the default of
+// their kind, if one is set in defaults, or ObjectStrategySubset otherwise.
+func objectStrategy(m manifest.Manifest, defaults map[string]string) (string, error) {
+	annotations, _ := m.Metadata()["annotations"].(map[string]interface{})
+	s, ok := annotations[AnnotationDiffStrategy]
+	if !ok {
+		return kindStrategy(m.Kind(), defaults)
+	}
+
+	switch s {
+	case ObjectStrategyNone, ObjectStrategySubset, ObjectStrategyExact:
+		return s.(string), nil
+	}
+	return "", fmt.Errorf("%s: unknown value '%v' of annotation '%s'. Pick one of: %v",
+		m.KindName(), s, AnnotationDiffStrategy, objectStrategies,
+	)
+}
+
+// kindStrategy returns the default diff strategy of kind
+func kindStrategy(kind string, defaults map[string]string) (string, error) {
+	s, ok := defaults[kind]
+	if !ok {
+		return ObjectStrategySubset, nil
+	}
+
+	switch s {
+	case ObjectStrategyNone, ObjectStrategySubset, ObjectStrategyExact:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown diff strategy '%s' configured for kind '%s'. Pick one of: %v",
+		s, kind, objectStrategies,
+	)
+}
+
+// skipNone returns state without the objects using ObjectStrategyNone
+func skipNone(state manifest.List, defaults map[string]string) (manifest.List, error) {
+	out := make(manifest.List, 0, len(state))
+	for _, m := range state {
+		s, err := objectStrategy(m, defaults)
+		if err != nil {
+			return nil, err
+		}
+		if s == ObjectStrategyNone {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// removeAnnotation deletes the given annotation from m, if present
+func removeAnnotation(m manifest.Manifest, key string) {
+	meta, ok := m["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	annotations, ok := meta["annotations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	delete(annotations, key)
+	if len(annotations) == 0 {
+		delete(meta, "annotations")
+	}
+}
+
+// serverMetadata are the fields of metadata maintained by the API server
+var serverMetadata = []string{
+	"uid", "resourceVersion", "generation", "creationTimestamp", "selfLink", "managedFields",
+}
+
+// dropServerFields removes the fields maintained by the API server from live,
+// unless they are present in local as well
+func dropServerFields(local, live manifest.Manifest) {
+	if _, ok := local["status"]; !ok {
+		delete(live, "status")
+	}
+
+	meta, ok := live["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, f := range serverMetadata {
+		if _, ok := local.Metadata()[f]; !ok {
+			delete(meta, f)
+		}
+	}
+	if _, ok := local.Metadata().Annotations()[AnnotationLastApplied]; !ok {
+		removeAnnotation(live, AnnotationLastApplied)
+	}
+}
@@ -0,0 +1,148 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestObjectStrategyAnnotation(t *testing.T) {
+	annotated := func(name, strategy string) manifest.Manifest {
+		m := configMap(name, "default", map[string]interface{}{"foo": "new"})
+		m.Metadata()["annotations"] = map[string]interface{}{AnnotationDiffStrategy: strategy}
+		return m
+	}
+
+	c := newFakeClient(
+		configMap("skipped", "default", map[string]interface{}{"foo": "old"}),
+		configMap("subset", "default", map[string]interface{}{"foo": "old"}),
+	)
+
+	t.Run("none", func(t *testing.T) {
+		diff, err := SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{annotated("skipped", ObjectStrategyNone)})
+		require.NoError(t, err)
+		assert.Nil(t, diff)
+		assert.NotContains(t, c.Calls(), "get default ConfigMap skipped")
+	})
+
+	t.Run("subset", func(t *testing.T) {
+		diff, err := SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{annotated("subset", ObjectStrategySubset)})
+		require.NoError(t, err)
+		require.NotNil(t, diff)
+		assert.Contains(t, *diff, "+  foo: new")
+		assert.NotContains(t, *diff, AnnotationDiffStrategy)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{annotated("subset", "bogus")})
+		assert.Error(t, err)
+	})
+}
+
+func TestKindStrategies(t *testing.T) {
+	defaults := map[string]string{
+		"ConfigMap": ObjectStrategyExact,
+		"Secret":    ObjectStrategyNone,
+	}
+
+	annotated := configMap("annotated", "default", nil)
+	annotated.Metadata()["annotations"] = map[string]interface{}{AnnotationDiffStrategy: ObjectStrategySubset}
+
+	cases := []struct {
+		name string
+		m    manifest.Manifest
+		want string
+	}{
+		{name: "configured", m: configMap("config", "default", nil), want: ObjectStrategyExact},
+		{name: "none", m: secret("creds", nil), want: ObjectStrategyNone},
+		{name: "unconfigured", m: service(nil, nil), want: ObjectStrategySubset},
+		{name: "annotation", m: annotated, want: ObjectStrategySubset},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := objectStrategy(c.m, defaults)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := objectStrategy(configMap("config", "default", nil), map[string]string{"ConfigMap": "bogus"})
+		assert.EqualError(t, err, "unknown diff strategy 'bogus' configured for kind 'ConfigMap'. Pick one of: [none subset exact]")
+	})
+}
+
+func TestSubsetDifferKindStrategies(t *testing.T) {
+	live := configMap("config", "default", map[string]interface{}{"foo": "bar", "extra": "live"})
+	c := newFakeClient(live)
+
+	local := manifest.List{configMap("config", "default", map[string]interface{}{"foo": "bar"})}
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{SubsetData: true})(local)
+	require.NoError(t, err)
+	assert.Nil(t, diff)
+
+	// exact comparison reveals the field only present in the cluster
+	opts := SubsetDiffOpts{KindStrategies: map[string]string{"ConfigMap": ObjectStrategyExact}}
+	diff, err = SubsetDiffer(c, opts)(local)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Contains(t, *diff, "-  extra: live")
+}
+
+func TestExactStrategyServerFields(t *testing.T) {
+	withServerFields := func(m manifest.Manifest) manifest.Manifest {
+		meta := m.Metadata()
+		meta["uid"] = "1d7c2f0e"
+		meta["resourceVersion"] = "42"
+		meta["creationTimestamp"] = "2020-10-01T00:00:00Z"
+		meta["managedFields"] = []interface{}{map[string]interface{}{"manager": "kubectl"}}
+		meta["annotations"] = map[string]interface{}{AnnotationLastApplied: `{"data":{"password":"aHVudGVyMg=="}}`}
+		m["status"] = map[string]interface{}{"phase": "Active"}
+		return m
+	}
+	c := newFakeClient(
+		withServerFields(configMap("config", "default", map[string]interface{}{"foo": "old"})),
+		withServerFields(secret("creds", map[string]string{"password": "hunter2"})),
+	)
+
+	annotated := configMap("config", "default", map[string]interface{}{"foo": "new"})
+	annotated.Metadata()["annotations"] = map[string]interface{}{AnnotationDiffStrategy: ObjectStrategyExact}
+
+	cases := []struct {
+		name  string
+		state manifest.List
+		opts  SubsetDiffOpts
+	}{
+		{
+			name:  "annotation",
+			state: manifest.List{annotated},
+		},
+		{
+			name:  "kind",
+			state: manifest.List{configMap("config", "default", map[string]interface{}{"foo": "new"})},
+			opts:  SubsetDiffOpts{KindStrategies: map[string]string{"ConfigMap": ObjectStrategyExact}},
+		},
+		{
+			name:  "masked secret",
+			state: manifest.List{secret("creds", map[string]string{"password": "hunter3"})},
+			opts:  SubsetDiffOpts{MaskSecrets: true, KindStrategies: map[string]string{"Secret": ObjectStrategyExact}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := SubsetDiffer(c, tc.opts)(tc.state)
+			require.NoError(t, err)
+			require.NotNil(t, diff)
+
+			for _, f := range []string{"uid", "resourceVersion", "creationTimestamp", "managedFields", "status", AnnotationLastApplied, "aHVudGVyMg=="} {
+				assert.NotContains(t, *diff, f)
+			}
+		})
+	}
+}
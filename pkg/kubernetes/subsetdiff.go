@@ -1,7 +1,9 @@
 package kubernetes
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -10,108 +12,1181 @@ import (
 	"github.com/grafana/tanka/pkg/kubernetes/util"
 )
 
-type difference struct {
-	name         string
-	live, merged string
+// diffStr computes the differences of two serialized states. Replaceable for
+// testing
+var diffStr = util.DiffStr
+
+// comparison pairs an object of the desired state with its live counterpart.
+// live is nil if the object does not exist in the cluster.
+type comparison struct {
+	local, live manifest.Manifest
+
+	// unreachable is the connection error that prevented fetching live (see
+	// SubsetDiffOpts.TolerateUnreachable)
+	unreachable error
+	// unstable is set if live changed on every read (see
+	// SubsetDiffOpts.Rereads)
+	unstable bool
+}
+
+// unknown returns why the drift of the comparison is unknown, or an empty
+// string if it can be compared
+func (c comparison) unknown() string {
+	switch {
+	case c.unreachable != nil:
+		return "cluster unreachable"
+	case c.unstable:
+		return "changed on every read"
+	}
+	return ""
 }
 
 // SubsetDiffer returns a implementation of Differ that computes the diff by
 // comparing only the fields present in the desired state. This algorithm might
 // miss information, but is all that's possible on cluster versions lower than
 // 1.13.
-func SubsetDiffer(c client.Client) Differ {
+func SubsetDiffer(c client.Client, opts SubsetDiffOpts) Differ {
 	return func(state manifest.List) (*string, error) {
-		docs := []difference{}
-
-		errCh := make(chan error)
-		resultCh := make(chan difference)
+		state = filterKinds(state, opts.Kinds)
 
-		for _, rawShould := range state {
-			go parallelSubsetDiff(c, rawShould, resultCh, errCh)
+		// an empty state would otherwise yield no diff, which is
+		// indistinguishable from "no changes"
+		if len(state) == 0 {
+			return nil, ErrorNoObjects{}
 		}
 
-		var lastErr error
-		for i := 0; i < len(state); i++ {
-			select {
-			case d := <-resultCh:
-				docs = append(docs, d)
-			case err := <-errCh:
-				lastErr = err
-			}
+		opts := opts
+		if opts.DetectListTypes && opts.ListTypes == nil {
+			opts.ListTypes = detectListTypes(c)
 		}
-		close(resultCh)
-		close(errCh)
 
-		if lastErr != nil {
-			return nil, errors.Wrap(lastErr, "calculating subset")
+		result, err := diffState(c, state, opts)
+		partial, _ := err.(ErrorDiffs)
+		if err != nil && partial == nil {
+			return nil, err
 		}
 
-		var diffs string
-		for _, d := range docs {
-			diffStr, err := util.DiffStr(d.name, d.live, d.merged)
-			if err != nil {
-				return nil, errors.Wrap(err, "invoking diff")
-			}
-			if diffStr != "" {
-				diffStr += "\n"
+		for _, p := range opts.PostProcessors {
+			if err := p(result); err != nil {
+				return nil, err
 			}
-			diffs += diffStr
 		}
-		diffs = strings.TrimSuffix(diffs, "\n")
 
+		diffs := result.Render(opts.Budget)
+		switch {
+		case opts.GroupByLabel != "":
+			diffs = result.renderGroups(opts.GroupByLabel, opts.Budget)
+		case opts.Compact:
+			diffs = result.Compact()
+		case opts.GitHubAnnotations:
+			diffs = result.GitHubAnnotations()
+		}
+		diffs = result.revisionSummary(diffs != "") + diffs
+		if opts.ReportIgnored {
+			diffs += result.ignoredSummary(diffs != "")
+		}
+		diffs += result.unknownSummary(diffs != "")
+
+		// a nil ErrorDiffs must not become a non-nil error
+		var errs error
+		if partial != nil {
+			errs = partial
+		}
 		if diffs == "" {
-			return nil, nil
+			return nil, errs
 		}
+		return &diffs, errs
+	}
+}
+
+// DiffAgainst compares the desired state to already fetched live objects,
+// without contacting the cluster. Live objects are matched to local ones by
+// kind, namespace and name. Local objects lacking a live counterpart are
+// reported as created. Neither of the lists is modified.
+func DiffAgainst(local, live manifest.List, opts SubsetDiffOpts) (*DiffResult, error) {
+	local, err := skipNone(local, opts.KindStrategies)
+	if err != nil {
+		return nil, err
+	}
 
-		return &diffs, nil
+	index := make(map[string]manifest.Manifest, len(live))
+	for _, m := range live {
+		index[objectKey(m)] = m
 	}
+
+	comparisons := make([]comparison, 0, len(local))
+	for _, m := range local {
+		c := comparison{local: m}
+		if l, ok := index[objectKey(m)]; ok {
+			c.live = manifest.Manifest(copyMSI(l))
+		}
+		comparisons = append(comparisons, c)
+	}
+
+	return diffComparisons(comparisons, opts)
 }
 
-func parallelSubsetDiff(c client.Client, should manifest.Manifest, r chan difference, e chan error) {
-	diff, err := subsetDiff(c, should)
+// diffState retrieves the live counterparts of state from the cluster and
+// compares them
+func diffState(c client.Client, state manifest.List, opts SubsetDiffOpts) (*DiffResult, error) {
+	state, err := skipNone(state, opts.KindStrategies)
 	if err != nil {
-		e <- err
-		return
+		return nil, err
+	}
+	if err := validateNames(state); err != nil {
+		return nil, err
 	}
-	r <- *diff
+	if opts.Preflight {
+		if err := preflight(c, state); err != nil {
+			return nil, err
+		}
+	}
+
+	var unmodified []DiffEntry
+	if opts.Baseline != nil {
+		if state, unmodified, err = skipUnmodified(c, state, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	var result *DiffResult
+	switch {
+	case len(state) == 0:
+		result = &DiffResult{}
+	case opts.Cache != nil:
+		result, err = cachedDiffState(c, state, opts)
+	default:
+		var comparisons []comparison
+		comparisons, err = liveComparisons(c, state, opts)
+		if err == nil {
+			result, err = diffComparisons(comparisons, opts)
+		}
+	}
+	partial, _ := err.(ErrorDiffs)
+	if err != nil && partial == nil {
+		return nil, err
+	}
+	result.Entries = append(result.Entries, unmodified...)
+
+	if opts.PruneSelector != nil {
+		candidates, err := pruneCandidates(c, state, mergeLabels(opts.Selector, opts.PruneSelector), opts.PruneAllowlist)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := pruneEntries(candidates, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Entries = append(result.Entries, entries...)
+	}
+
+	if partial != nil {
+		return result, partial
+	}
+	return result, nil
 }
 
-func subsetDiff(c client.Client, m manifest.Manifest) (*difference, error) {
-	name := util.DiffName(m)
+// liveComparisons retrieves the live counterparts of state from the cluster
+func liveComparisons(c client.Client, state manifest.List, opts SubsetDiffOpts) ([]comparison, error) {
+	perObject, err := fetchLive(c, state, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "calculating subset")
+	}
 
-	// kubectl output -> current state
-	rawIs, err := c.Get(
-		m.Metadata().Namespace(),
-		m.Kind(),
-		m.Metadata().Name(),
-	)
+	if opts.Settle > 0 {
+		perObject, err = settle(c, state, perObject, opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "settling")
+		}
+	}
+
+	comparisons := make([]comparison, 0, len(state))
+	for _, cs := range perObject {
+		comparisons = append(comparisons, cs...)
+	}
+	return comparisons, nil
+}
+
+// fetchLive concurrently retrieves the live counterpart of each object of the
+// desired state. The result holds the comparisons of each object in the order
+// of state.
+func fetchLive(c client.Client, state manifest.List, opts SubsetDiffOpts) ([][]comparison, error) {
+	perObject := make([][]comparison, len(state))
+	errCh := make(chan error)
+	sc := newScopes(c, state)
+	if opts.AwaitCRDs {
+		sc.crds = declaredKinds(state)
+	}
+
+	for i, m := range state {
+		go func(i int, m manifest.Manifest) {
+			cs, err := fetchObject(c, sc, m, opts)
+			perObject[i] = cs
+			errCh <- err
+		}(i, m)
+	}
+
+	var lastErr error
+	for range state {
+		if err := <-errCh; err != nil {
+			lastErr = err
+		}
+	}
+	close(errCh)
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return perObject, nil
+}
+
+// fetchObject returns the comparisons required for diffing m
+func fetchObject(c client.Client, sc *scopes, m manifest.Manifest, opts SubsetDiffOpts) (cs []comparison, err error) {
+	// deferred first, so it also covers recovered panics
+	defer func() {
+		if err != nil {
+			err = ErrorDiff{Ref: RefOf(m), Phase: DiffPhaseFetch, Err: err}
+		}
+	}()
+
+	// malformed cluster responses must not crash the whole process
+	defer recoverObject(m, &err)
+	defer opts.Metrics.Since(MetricFetch, time.Now())
+
+	if sc.pending(m) {
+		return []comparison{{local: m}}, nil
+	}
+
+	var fields []string
+	if opts.projects(m) {
+		fields = append(projectedFields(m), clusterKeys(opts.KeepClusterKeys)...)
+	}
+	ref := RefOf(m)
+	ref.Namespace = sc.namespace(m)
+	live, err := getLive(c, ref, fields)
+	if _, ok := errors.Cause(err).(client.ErrorConnection); ok && opts.TolerateUnreachable {
+		return []comparison{{local: m, unreachable: err}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if opts.Rereads > 0 && live != nil {
+		var stable bool
+		live, stable, err = reread(c, ref, fields, live, opts.Rereads)
+		if err != nil {
+			return nil, err
+		}
+		if !stable {
+			return []comparison{{local: m, unstable: true}}, nil
+		}
+	}
+	cs = []comparison{{local: m, live: live}}
+
+	if opts.WithRollout && m.Kind() == "Deployment" && live != nil {
+		rc, err := rolloutComparison(c, m, live)
+		if err != nil {
+			return nil, err
+		}
+		if rc != nil {
+			cs = append(cs, *rc)
+		}
+	}
+
+	return cs, nil
+}
+
+// getLive returns the cluster state of the referenced object, or nil if it
+// does not exist. The namespace of ref must be the one of the object itself
+// (see scopes.namespace), never a shared default, so same-named objects in
+// different namespaces are kept apart. Unless fields is nil, only these fields
+// are fetched, besides the identity.
+func getLive(c client.Client, ref ObjectRef, fields []string) (manifest.Manifest, error) {
+	var live manifest.Manifest
+	var err error
+	if fields != nil {
+		live, err = c.GetFields(ref.Namespace, ref.Kind, ref.Name, fields)
+	} else {
+		live, err = c.Get(ref.Namespace, ref.Kind, ref.Name)
+	}
 
 	if _, ok := err.(client.ErrorNotFound); ok {
-		rawIs = map[string]interface{}{}
+		return nil, nil
 	} else if err != nil {
 		return nil, errors.Wrap(err, "getting state from cluster")
 	}
 
-	should := m.String()
+	if reason := malformed(live, ref.Kind); reason != "" {
+		return nil, ErrorUnexpectedResponse{Ref: ref, Reason: reason}
+	}
+
+	return live, nil
+}
+
+// malformed returns why live is not a single object of the given kind, or an
+// empty string if it is
+func malformed(live manifest.Manifest, kind string) string {
+	switch {
+	case live == nil:
+		return "got an empty response"
+	case live.IsList():
+		return "got a list instead of a single object"
+	}
+
+	got, ok := live["kind"].(string)
+	switch {
+	case !ok:
+		return fmt.Sprintf("expected an object of kind %s, but the response has no kind", kind)
+	case got != kind:
+		return fmt.Sprintf("expected an object of kind %s, but got %s", kind, got)
+	}
+	return ""
+}
+
+// ErrorUnexpectedResponse occurs when the cluster returns something other than
+// the requested object
+type ErrorUnexpectedResponse struct {
+	Ref    ObjectRef
+	Reason string
+}
+
+func (e ErrorUnexpectedResponse) Error() string {
+	return fmt.Sprintf("unexpected response from cluster for %s: %s", e.Ref, e.Reason)
+}
+
+// DiffPhase is the step of diffing an object
+type DiffPhase string
+
+const (
+	// DiffPhaseFetch retrieves the live state of the object from the cluster
+	DiffPhaseFetch DiffPhase = "fetch"
+	// DiffPhaseRender compares both states and renders the differences
+	DiffPhaseRender DiffPhase = "render"
+)
+
+// ErrorDiff occurs when diffing a single object fails. Err holds the cause,
+// which can be inspected using errors.As, e.g. for a client.ErrorNotFound.
+type ErrorDiff struct {
+	Ref   ObjectRef
+	Phase DiffPhase
+	Err   error
+}
+
+func (e ErrorDiff) Error() string {
+	return fmt.Sprintf("diffing %s (%s): %s", e.Ref, e.Phase, e.Err)
+}
+
+// Unwrap returns the cause
+func (e ErrorDiff) Unwrap() error {
+	return e.Err
+}
+
+// ErrorDiffs occurs when diffing some objects failed, but the others succeeded
+// (see SubsetDiffOpts.ContinueOnError)
+type ErrorDiffs []ErrorDiff
+
+func (e ErrorDiffs) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, "- "+err.Error())
+	}
+	return fmt.Sprintf("diffing %d objects failed:\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// diffComparisons computes the DiffResult of the given comparisons. If
+// opts.ContinueOnError is set, failed objects are skipped and the result is
+// returned alongside an ErrorDiffs.
+func diffComparisons(comparisons []comparison, opts SubsetDiffOpts) (*DiffResult, error) {
+	result := DiffResult{
+		Entries: make([]DiffEntry, 0, len(comparisons)),
+	}
+	var errs ErrorDiffs
+
+	s := opts.subsetter()
+	for _, c := range comparisons {
+		if reason := c.unknown(); reason != "" {
+			result.Entries = append(result.Entries, DiffEntry{
+				Name:    util.DiffName(c.local),
+				Ref:     RefOf(c.local),
+				Labels:  labelsOf(c.local),
+				Unknown: true,
+				Notes:   []string{reason},
+			})
+			continue
+		}
+
+		start := time.Now()
+		entry, err := s.compare(c.local, c.live)
+		if err != nil {
+			err := ErrorDiff{Ref: RefOf(c.local), Phase: DiffPhaseRender, Err: errors.Wrap(err, "calculating subset")}
+			if !opts.ContinueOnError {
+				return nil, err
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		if opts.DumpDir != "" {
+			if err := dumpInputs(opts.DumpDir, *entry); err != nil {
+				return nil, ErrorDiff{Ref: RefOf(c.local), Phase: DiffPhaseRender, Err: errors.Wrap(err, "dumping diff inputs")}
+			}
+		}
+
+		var d string
+		if c.live == nil {
+			d, err = opts.createdDiff(c.local, entry.Merged)
+		} else {
+			d, err = opts.diff(c.local, entry.Live, entry.Merged)
+		}
+		if err != nil {
+			err := ErrorDiff{Ref: RefOf(c.local), Phase: DiffPhaseRender, Err: errors.Wrap(err, "invoking diff")}
+			if !opts.ContinueOnError {
+				return nil, err
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if opts.AnnotatePaths {
+			d = annotateHunks(d, entry.Live, entry.Merged)
+		}
+		entry.Diff = d
+		entry.Labels = labelsOf(c.local)
+
+		opts.Metrics.Since(MetricCompare, start)
+		opts.Metrics.Add(MetricObjects, 1)
+		if d != "" {
+			opts.Metrics.Add(MetricChanged, 1)
+			opts.Metrics.Add(MetricDiffBytes, int64(len(d)))
+		}
+
+		result.Entries = append(result.Entries, *entry)
+	}
+
+	if len(errs) > 0 {
+		return &result, errs
+	}
+	return &result, nil
+}
+
+// compare reduces live to the fields present in local and serializes both
+func (s subsetter) compare(local, live manifest.Manifest) (entry *DiffEntry, err error) {
+	defer recoverObject(local, &err)
+
+	name := util.DiffName(local)
+
+	// captured before the live state is modified
+	var resourceVersion string
+	if live != nil {
+		resourceVersion, _ = live.Metadata()["resourceVersion"].(string)
+	}
+
+	// the sanitizer may modify its input, so local must be copied
+	local, err = s.sanitizer.Sanitize(manifest.Manifest(copyMSI(local)))
+	if err != nil {
+		return nil, errors.Wrap(err, "sanitizing desired state")
+	}
+	if live != nil {
+		if live, err = s.sanitizer.Sanitize(live); err != nil {
+			return nil, errors.Wrap(err, "sanitizing live state")
+		}
+	}
+
+	strategy, err := objectStrategy(local, s.kindStrategies)
+	if err != nil {
+		return nil, err
+	}
+	removeAnnotation(local, AnnotationDiffStrategy)
+	if live != nil {
+		removeAnnotation(live, AnnotationDiffStrategy)
+	}
+
+	serializer := s.serializerFor(sortKeys(local))
+	removeAnnotation(local, AnnotationSortKeys)
+	if live != nil {
+		removeAnnotation(live, AnnotationSortKeys)
+	}
+
+	redacted := redactPaths(local)
+	removeAnnotation(local, AnnotationRedact)
+	if live != nil {
+		removeAnnotation(live, AnnotationRedact)
+	}
+
+	var notes []string
+	var revision *RevisionMismatch
+	// noted first, as it is the most important information for reviewers
+	if s.revisionAnnotation != "" && live != nil {
+		if revision = revisionMismatch(local, live, s.revisionAnnotation); revision != nil {
+			notes = append(notes, fmt.Sprintf("(%s)", revision))
+		}
+	}
+	if s.annotateOwned && live != nil {
+		if owner := controllerOf(live); owner != "" {
+			notes = append(notes, fmt.Sprintf("(controlled by %s, drift may be caused by the controller)", owner))
+		}
+	}
+	if s.showStatusReplicas && live != nil {
+		if counts := statusReplicas(live); counts != "" {
+			notes = append(notes, fmt.Sprintf("(status: %s)", counts))
+		}
+	}
+	if s.annotateManaged && live != nil {
+		if tool := managedBy(local, live); tool != "" {
+			notes = append(notes, fmt.Sprintf("(managed by %s)", tool))
+		}
+	}
+
+	if s.specOnly {
+		local = specOnly(local)
+		if live != nil {
+			live = specOnly(live)
+		}
+	}
+
+	// only allowed fields are kept on both sides
+	if s.allow != nil {
+		paths := allowedPaths(s.allow, local)
+		local = keepPaths(local, paths)
+		if live != nil {
+			live = keepPaths(live, paths)
+		}
+	}
+
+	// ignored fields are removed from both sides
+	var ignoredDrift []string
+	if paths := ignoredPaths(s.ignores, local, false); len(paths) > 0 {
+		if s.reportIgnored && live != nil {
+			ignoredDrift = s.ignoredDrift(local, live, paths)
+		}
+		for _, p := range paths {
+			removePath(local, p)
+			if live != nil {
+				removePath(live, p)
+			}
+		}
+	}
+
+	// subset() drops fields absent locally anyway
+	if live != nil && (s.exact || strategy == ObjectStrategyExact) {
+		for _, p := range ignoredPaths(s.ignores, local, true) {
+			removeAssigned(local, live, splitPath(p))
+		}
+	}
+
+	if live != nil && (s.exact || strategy == ObjectStrategyExact) {
+		dropServerFields(local, live)
+	}
+
+	if s.maskSecrets && local.Kind() == "Secret" {
+		maskSecret(local, live)
+	}
+
+	if !s.keepLineEndings || s.trimTrailingSpace {
+		normalizeWhitespace(local, s.keepLineEndings, s.trimTrailingSpace)
+		if live != nil {
+			normalizeWhitespace(live, s.keepLineEndings, s.trimTrailingSpace)
+		}
+	}
+
+	if s.normalizeJSONAnnotations && live != nil {
+		normalizeJSONAnnotations(local, live)
+	}
+
+	if live != nil && !s.keepEmpty {
+		fillEmpty(local, live)
+	}
+	if live != nil && s.equateEmpty {
+		equateEmpty(local, live, s.emptyPaths, "")
+	}
 
-	sub := subset(m, rawIs)
-	is := manifest.Manifest(sub).String()
-	if is == "{}\n" {
-		is = ""
+	// last, so the masks reflect all normalizations
+	if len(redacted) > 0 {
+		s.redact(local, live, redacted, s.exact || strategy == ObjectStrategyExact)
 	}
 
-	return &difference{
-		name:   name,
-		live:   is,
-		merged: should,
+	is := ""
+	var pruned []string
+	var patch JSONPatch
+	recreate := false
+	if live != nil {
+		// checked before subset() modifies live
+		if recreate = requiresRecreate(local, live); recreate {
+			notes = append(notes, "(requires recreate)")
+		}
+
+		sub := map[string]interface{}(live)
+		if strategy != ObjectStrategyExact && !s.exact {
+			if s.recordPruned {
+				pruned = prunedPaths(local, live, "")
+			}
+
+			// captured before subset() modifies live
+			kept := make(map[string]interface{})
+			for _, p := range s.keepClusterKeys {
+				copyPath(kept, live, splitPath(p))
+			}
+			var data []string
+			if !s.subsetData {
+				data = exactDataFields(local, live)
+			}
+			for _, f := range data {
+				copyPath(kept, live, []string{f})
+			}
+
+			sub, err = s.subsetterFor(local).subset(local, live, "", 0)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range s.keepClusterKeys {
+				copyPath(sub, kept, splitPath(p))
+			}
+			for _, f := range data {
+				sub[f] = kept[f]
+			}
+			if len(data) > 0 {
+				dropStringData(local, sub)
+			}
+		}
+
+		if s.annotateRestarts {
+			restarts, err := s.restartsPods(local, sub)
+			if err != nil {
+				return nil, err
+			}
+			if restarts {
+				notes = append(notes, "(will restart pods)")
+			}
+		}
+
+		is, err = serializer.Marshal(sub)
+		if err != nil {
+			return nil, err
+		}
+		if is == "{}\n" {
+			is = ""
+		}
+
+		if s.jsonPatch && is != "" {
+			if patch, err = newJSONPatch(sub, local); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// created as a whole, like the diff shows
+	if s.jsonPatch && is == "" {
+		if patch, err = newJSONPatch(nil, local); err != nil {
+			return nil, err
+		}
+	}
+
+	should, err := serializer.Marshal(local)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiffEntry{
+		Name:   name,
+		Ref:    RefOf(local),
+		Live:   is,
+		Merged: should,
+		Notes:  notes,
+		Pruned: pruned,
+
+		JSONPatch: patch,
+
+		Recreate:         recreate,
+		RevisionMismatch: revision,
+		IgnoredDrift:     ignoredDrift,
+		ResourceVersion:  resourceVersion,
 	}, nil
 }
 
+// recoverObject converts a panic that occurred while processing m into an error
+// identifying m. Must be deferred.
+func recoverObject(m manifest.Manifest, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("unexpected error processing %s: %v", util.DiffName(m), r)
+	}
+}
+
+// objectKey identifies an object by kind, namespace and name
+func objectKey(m manifest.Manifest) string {
+	return fmt.Sprintf("%s/%s/%s", m.Kind(), m.Metadata().Namespace(), m.Metadata().Name())
+}
+
+// copyMSI returns a deep copy of the given JSON tree
+func copyMSI(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = copyValue(v)
+	}
+	return out
+}
+
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return copyMSI(t)
+	case []map[string]interface{}:
+		s := make([]map[string]interface{}, len(t))
+		for i := range t {
+			s[i] = copyMSI(t[i])
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i := range t {
+			s[i] = copyValue(t[i])
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// DefaultMaxDepth is the nesting depth subset() descends into by default
+const DefaultMaxDepth = 100
+
+// SubsetDiffOpts allow to tune the subset diff
+type SubsetDiffOpts struct {
+	// MaxDepth limits how deep subset() descends into nested objects, guarding
+	// against pathological inputs. Defaults to DefaultMaxDepth
+	MaxDepth int
+
+	// Ignore excludes additional fields from the diff
+	Ignore []IgnoreRule
+	// NoDefaultIgnores disables the built-in DefaultIgnores
+	NoDefaultIgnores bool
+	// KeepClusterKeys are dotted paths (see IgnoreRule.Paths) of fields
+	// that are compared even if absent from the desired state, e.g. an
+	// annotation recording the last sync. They show up as removed in the diff
+	KeepClusterKeys []string
+
+	// Allow restricts the diff to the listed fields, if set. Objects of kinds
+	// without a matching rule are only compared by their identity, so they
+	// never show drift
+	Allow []AllowRule
+
+	// Budget limits the size of the diff returned by SubsetDiffer
+	Budget DiffBudget
+
+	// PostProcessors are run by SubsetDiffer in order, once all objects are
+	// compared but before the result is rendered
+	PostProcessors []PostProcessor
+
+	// Metrics accumulates counters and timings of the diff, e.g. for
+	// identifying slow objects. Disabled if nil
+	Metrics *DiffMetrics
+
+	// Encoder serializes both states before comparing them
+	Encoder manifest.Encoder
+	// Serializer is used instead of Encoder if set, e.g. manifest.JSONEncoder
+	// or a custom one. AnnotationSortKeys only applies to Encoder
+	Serializer manifest.Serializer
+
+	// AnnotateOwned adds a note to the diff of objects that are controlled by
+	// another object, as their drift is often caused by the controller
+	AnnotateOwned bool
+	// AnnotateManaged adds a note to the diff of objects that are managed by
+	// another tool (Helm, ArgoCD, Flux, another Tanka environment, ...), as
+	// applying them likely conflicts with it. Detected using LabelManagedBy
+	// and the labels and annotations of these tools on the live object
+	AnnotateManaged bool
+
+	// Sanitizer is applied to both states before comparing them. Defaults to
+	// NopSanitizer
+	Sanitizer Sanitizer
+
+	// SpecOnly compares only the spec (or data for ConfigMaps and Secrets) of
+	// objects, ignoring all metadata
+	SpecOnly bool
+
+	// Cache allows to skip objects that did not change since a previous run.
+	// Entries are specific to the cluster (kubeconfig context) of the client.
+	// Disabled if nil
+	Cache DiffCache
+
+	// WithRollout additionally compares the pod template of Deployments
+	// against their active ReplicaSet, to catch stuck rollouts
+	WithRollout bool
+
+	// Settle re-fetches objects showing drift after the given delay and only
+	// reports drift that persists across both reads. Useful right after an
+	// apply, when controllers are still mutating objects. Only cache misses
+	// are settled if Cache is set. Disabled if zero
+	Settle time.Duration
+	// Rereads fetches every object again, until two consecutive reads agree,
+	// but at most this many times. Objects changing on every read are
+	// reported as unknown drift (DiffEntry.Unknown) instead of being compared
+	// mid-change. Unlike Settle, this does not wait. Disabled if zero
+	Rereads int
+
+	// RecordPruned stores the paths of the live fields that were removed by
+	// subset() in DiffEntry.Pruned. Meant for debugging, so disabled by default
+	RecordPruned bool
+
+	// JSONPatch stores the RFC 6902 operations turning the compared live state
+	// into the desired one in DiffEntry.JSONPatch, for machines to consume
+	JSONPatch bool
+
+	// ListTypes allows subset() to compare lists of type "set" and "map"
+	// regardless of their order. Usually obtained using FetchListTypes
+	ListTypes ListTypes
+	// DetectListTypes obtains ListTypes from the cluster on every diff, unless
+	// they are set already. If the OpenAPI schema is unavailable, a warning is
+	// printed and lists are compared in order
+	DetectListTypes bool
+
+	// PruneSelector additionally reports objects of the cluster matching these
+	// labels (and Selector), that are absent from the desired state, as
+	// pruned. Disabled if nil. Fails if both are empty, as every object of the
+	// cluster would match
+	PruneSelector map[string]string
+	// PruneAllowlist limits pruning to objects of these group/version/kinds,
+	// like kubectl's --prune-allowlist, e.g. core/v1/ConfigMap or
+	// apps/v1/Deployment. Other kinds are not even listed. All kinds are
+	// considered if empty
+	PruneAllowlist []string
+
+	// AnnotatePaths appends the dotted paths of the changed fields to the
+	// header of each hunk, e.g. `@@ -6,7 +6,7 @@ spec.replicas`
+	AnnotatePaths bool
+
+	// KeepEmpty reports empty maps and lists of the desired state as differences
+	// if the cluster omits them. By default, both are considered equal
+	KeepEmpty bool
+	// EquateEmpty considers empty strings, null and absent fields equal
+	// everywhere, as the API server sometimes returns "" where the desired
+	// state omits a field, e.g. `spec.template.spec.schedulerName`
+	EquateEmpty bool
+	// EquateEmptyPaths is like EquateEmpty, but only applies to the fields at
+	// these dotted paths (see IgnoreRule.Paths)
+	EquateEmptyPaths []string
+
+	// KeepLineEndings reports multiline strings that only differ in their line
+	// endings (CRLF and LF) as differences. By default, both are considered
+	// equal
+	KeepLineEndings bool
+	// TrimTrailingSpace ignores whitespace at the end of the lines of
+	// multiline strings, e.g. of ConfigMap values and inline scripts
+	TrimTrailingSpace bool
+	// NormalizeJSONAnnotations compares annotations holding JSON objects or
+	// arrays structurally, so differences in key order or formatting between
+	// the desired state and the cluster are not reported
+	NormalizeJSONAnnotations bool
+
+	// Kinds limits the diff to objects of these kinds. Other objects are not
+	// fetched at all. All kinds are diffed if empty
+	Kinds []string
+
+	// AnnotateRestarts adds a note to the diff of workloads whose pod template
+	// changes, as applying them restarts their pods
+	AnnotateRestarts bool
+	// ShowStatusReplicas adds a note with the replica counts of the live
+	// status (replicas, readyReplicas, ...) to the diff of Deployments,
+	// StatefulSets and ReplicaSets, to inspect their health. The rest of the
+	// status is not shown and no count is ever compared
+	ShowStatusReplicas bool
+
+	// RevisionAnnotation compares the revision recorded in this annotation
+	// (e.g. AnnotationDesiredRevision) of the desired state to the one of the
+	// cluster. Mismatches are recorded in DiffEntry.RevisionMismatch, noted
+	// first on the object and listed above the whole diff, so they stand out
+	// from the field differences. Objects lacking the annotation locally are
+	// not checked
+	RevisionAnnotation string
+
+	// Created controls how objects that do not exist in the cluster yet are
+	// rendered: in full (CreatedFull, the default), as a single line
+	// (CreatedCompact) or by their key fields only (CreatedSummary)
+	Created CreatedRendering
+
+	// GroupByLabel renders the changed objects grouped by the value of this
+	// label, e.g. `team`, each group headed by a comment. Objects lacking the
+	// label come last. Budget applies to every group separately
+	GroupByLabel string
+
+	// Compact renders every changed object as a single line, stating the
+	// number of changed fields instead of the differences (see
+	// DiffResult.Compact). Budget does not apply
+	Compact bool
+	// GitHubAnnotations renders every changed object as a GitHub Actions
+	// annotation instead (see DiffResult.GitHubAnnotations). Combine with
+	// WithSources to annotate the files the objects originate from
+	GitHubAnnotations bool
+
+	// DumpDir is a directory the serialized states compared for each object
+	// are written to, for debugging surprising diffs: `<ref>.local` holds the
+	// desired state, `<ref>.cluster` the live one after subsetting (see
+	// ObjectRef.String for the names). Objects served from Cache are not
+	// dumped
+	DumpDir string
+
+	// KubectlFormat renders the diff exactly like `kubectl diff` does,
+	// including the paths of the compared files
+	KubectlFormat bool
+
+	// MaskSecrets compares the decoded values of Secrets, but renders them as
+	// masks like <changed>, so no secret values are revealed
+	MaskSecrets bool
+
+	// ReportIgnored records the ignored fields that differ in
+	// DiffEntry.IgnoredDrift, and states the number of objects having such
+	// drift below the diff. The statement alone makes for a non-empty diff,
+	// so nothing is hidden silently
+	ReportIgnored bool
+
+	// Project fetches only the fields present in the desired state (plus the
+	// identity of the object and KeepClusterKeys) from the cluster, instead of
+	// the whole object. Fields absent locally are never compared by the subset
+	// diff anyway, so this only reduces the amount of data processed, e.g. for
+	// objects with large status. Objects compared exactly are always fetched
+	// as a whole
+	Project bool
+
+	// SubsetData compares the data of ConfigMaps and Secrets as a subset, like
+	// all other fields. By default, it is compared exactly, so keys removed
+	// from the desired state are shown as removals (see dataFields)
+	SubsetData bool
+
+	// KindStrategies sets the default diff strategy (ObjectStrategyNone,
+	// ObjectStrategySubset or ObjectStrategyExact) of all objects of a kind,
+	// e.g. {"ConfigMap": "exact"}. AnnotationDiffStrategy takes precedence
+	KindStrategies map[string]string
+
+	// Preflight checks that all kinds of the desired state may be read from
+	// their namespaces (`kubectl auth can-i get`), before any object is
+	// fetched. Missing permissions are reported at once, as
+	// ErrorMissingPermissions
+	Preflight bool
+
+	// AwaitCRDs reports custom resources of kinds declared by a
+	// CustomResourceDefinition of the desired state as created, if the API
+	// server does not know the kind yet, because the CRD is not applied. They
+	// cannot exist, and fetching them would fail otherwise
+	AwaitCRDs bool
+
+	// TolerateUnreachable reports objects that cannot be fetched because the
+	// cluster is unreachable (client.ErrorConnection) as unknown drift
+	// (DiffEntry.Unknown), instead of failing the whole diff. All other
+	// objects are diffed as usual. Errors returned by the cluster itself,
+	// e.g. missing permissions, still fail the diff
+	TolerateUnreachable bool
+
+	// ContinueOnError keeps diffing the remaining objects if rendering the
+	// diff of one fails. Their diffs are returned alongside an ErrorDiffs
+	// listing the failed objects. Fetching errors still fail the whole diff
+	ContinueOnError bool
+
+	// Baseline holds the resourceVersions of a previous diff of the same
+	// desired state, as returned by DiffResult.ResourceVersions. Objects whose
+	// live resourceVersion is unchanged since are not compared, as no drift is
+	// possible, but reported as DiffEntry.Unmodified. The desired state must
+	// not have changed since the baseline was taken
+	Baseline map[string]string
+
+	// Selector holds the labels of the environment. Queries for multiple
+	// objects (pruning, batched gets) are constrained to it, so objects of
+	// other environments sharing the cluster are never matched
+	Selector map[string]string
+
+	// exact compares all fields instead of a subset. Set by ExactDiffer and
+	// DiffRenders
+	exact bool
+}
+
+// diff computes the differences of the serialized states of m
+func (opts SubsetDiffOpts) diff(m manifest.Manifest, is, should string) (string, error) {
+	if opts.KubectlFormat {
+		return util.KubectlDiffStr(util.KubectlDiffName(m), is, should)
+	}
+	return diffStr(util.DiffName(m), is, should)
+}
+
+// serializer returns the Serializer in effect for opts
+func (opts SubsetDiffOpts) serializer() manifest.Serializer {
+	if opts.Serializer != nil {
+		return opts.Serializer
+	}
+	return opts.Encoder
+}
+
+func (opts SubsetDiffOpts) subsetter() subsetter {
+	s := subsetter{
+		maxDepth: opts.MaxDepth,
+		ignores:  opts.ignoreRules(),
+		allow:    opts.Allow,
+		encoder:  opts.Encoder,
+
+		serializer: opts.Serializer,
+
+		keepClusterKeys: opts.KeepClusterKeys,
+
+		sanitizer:     opts.Sanitizer,
+		specOnly:      opts.SpecOnly,
+		annotateOwned: opts.AnnotateOwned,
+		recordPruned:  opts.RecordPruned,
+		keepEmpty:     opts.KeepEmpty,
+
+		equateEmpty: opts.EquateEmpty || opts.EquateEmptyPaths != nil,
+		emptyPaths:  newEmptyPaths(opts.EquateEmptyPaths),
+
+		annotateManaged: opts.AnnotateManaged,
+
+		revisionAnnotation: opts.RevisionAnnotation,
+
+		jsonPatch: opts.JSONPatch,
+
+		keepLineEndings:   opts.KeepLineEndings,
+		trimTrailingSpace: opts.TrimTrailingSpace,
+
+		normalizeJSONAnnotations: opts.NormalizeJSONAnnotations,
+
+		annotateRestarts: opts.AnnotateRestarts,
+		maskSecrets:      opts.MaskSecrets,
+		reportIgnored:    opts.ReportIgnored,
+
+		showStatusReplicas: opts.ShowStatusReplicas,
+
+		kindStrategies: opts.KindStrategies,
+		subsetData:     opts.SubsetData,
+		exact:          opts.exact,
+		listTypes:      DefaultListTypes.merge(opts.ListTypes),
+	}
+	if s.maxDepth <= 0 {
+		s.maxDepth = DefaultMaxDepth
+	}
+	if s.sanitizer == nil {
+		s.sanitizer = NopSanitizer
+	}
+	return s
+}
+
+// ErrorMaxDepth occurs when an object is nested deeper than SubsetDiffOpts.MaxDepth
+type ErrorMaxDepth struct {
+	MaxDepth int
+}
+
+func (e ErrorMaxDepth) Error() string {
+	return fmt.Sprintf("object exceeds the maximum nesting depth of %d", e.MaxDepth)
+}
+
+// subset runs the subset algorithm using the default options
+func subset(small, big map[string]interface{}) (map[string]interface{}, error) {
+	return SubsetDiffOpts{}.subsetter().subset(small, big, "", 0)
+}
+
+// equalJSON returns whether the JSON trees a and b are equal. Unlike
+// reflect.DeepEqual, it does not allocate
+func equalJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !equalJSON(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case string, float64, int64, bool, nil:
+		return a == b
+	}
+	return false
+}
+
+// subsetter implements the subset algorithm
+type subsetter struct {
+	maxDepth int
+	ignores  []IgnoreRule
+	allow    []AllowRule
+	encoder  manifest.Encoder
+
+	serializer manifest.Serializer
+
+	keepClusterKeys []string
+
+	sanitizer     Sanitizer
+	specOnly      bool
+	annotateOwned bool
+	recordPruned  bool
+	keepEmpty     bool
+
+	equateEmpty bool
+	emptyPaths  emptyPaths
+
+	annotateManaged bool
+
+	revisionAnnotation string
+
+	jsonPatch bool
+
+	keepLineEndings   bool
+	trimTrailingSpace bool
+
+	normalizeJSONAnnotations bool
+
+	annotateRestarts bool
+	maskSecrets      bool
+	reportIgnored    bool
+
+	showStatusReplicas bool
+
+	// exact compares all fields of every object except for the ones
+	// maintained by the API server (see dropServerFields)
+	exact bool
+	// kindStrategies are the default object strategies per kind
+	kindStrategies map[string]string
+	// subsetData disables the exact comparison of dataFields
+	subsetData bool
+
+	listTypes ListTypes
+	// lists holds the ListTypes of the object currently processed
+	lists map[string]ListType
+}
+
+// serializerFor returns the Serializer for an object listing keys in
+// AnnotationSortKeys
+func (s subsetter) serializerFor(keys []string) manifest.Serializer {
+	if s.serializer != nil {
+		return s.serializer
+	}
+
+	enc := s.encoder
+	if keys != nil {
+		enc.KeyOrder = keys
+	}
+	return enc
+}
+
+// subsetterFor returns a subsetter for processing m
+func (s subsetter) subsetterFor(m manifest.Manifest) subsetter {
+	s.lists = s.listTypes.lookup(m)
+	return s
+}
+
 // subset removes all keys from big, that are not present in small.
 // It makes big a subset of small.
 // Kubernetes returns more keys than we can know about.
 // This means, we need to remove all keys from the kubectl output, that are not present locally.
-func subset(small, big map[string]interface{}) map[string]interface{} {
+func (s subsetter) subset(small, big map[string]interface{}, path string, depth int) (map[string]interface{}, error) {
+	if depth > s.maxDepth {
+		return nil, ErrorMaxDepth{MaxDepth: s.maxDepth}
+	}
+
 	if small["namespace"] != nil {
 		big["namespace"] = small["namespace"]
 	}
@@ -121,46 +1196,98 @@ func subset(small, big map[string]interface{}) map[string]interface{} {
 		big["apiVersion"] = small["apiVersion"]
 	}
 
+	var err error
 	for k, v := range big {
 		if _, ok := small[k]; !ok {
 			delete(big, k)
 			continue
 		}
 
+		// scalars are kept as is, saves allocating their path
+		switch v.(type) {
+		case string, float64, int64, bool, nil:
+			continue
+		}
+
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+
 		switch b := v.(type) {
 		case map[string]interface{}:
 			if a, ok := small[k].(map[string]interface{}); ok {
-				big[k] = subset(a, b)
+				if big[k], err = s.subset(a, b, child, depth+1); err != nil {
+					return nil, err
+				}
 			}
 		case []map[string]interface{}:
 			for i := range b {
 				if a, ok := small[k].([]map[string]interface{}); ok {
-					b[i] = subset(a[i], b[i])
+					if b[i], err = s.subset(a[i], b[i], child, depth+1); err != nil {
+						return nil, err
+					}
 				}
 			}
 		case []interface{}:
-			for i := range b {
-				if a, ok := small[k].([]interface{}); ok {
-					if i >= len(a) {
-						// slice in config shorter than in live. Abort, as there are no entries to diff anymore
-						break
-					}
+			a, ok := small[k].([]interface{})
+			if !ok {
+				continue
+			}
 
-					// value not a dict, no recursion needed
-					cShould, ok := a[i].(map[string]interface{})
+			// sets and maps are compared regardless of order
+			if lt, ok := s.lists[child]; ok && (lt.Type == "set" || lt.Type == "map") {
+				var pairs []int
+				b, pairs = matchList(lt, a, b)
+				big[k] = b
+
+				for i, j := range pairs {
+					if j < 0 {
+						continue
+					}
+					cShould, ok := a[j].(map[string]interface{})
 					if !ok {
 						continue
 					}
-
-					// value not a dict, no recursion needed
 					cIs, ok := b[i].(map[string]interface{})
 					if !ok {
 						continue
 					}
-					b[i] = subset(cShould, cIs)
+					if b[i], err = s.subset(cShould, cIs, child, depth+1); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+
+			for i := range b {
+				if i >= len(a) {
+					// slice in config shorter than in live. Abort, as there are no entries to diff anymore
+					break
+				}
+
+				// equal items are kept as is. Cheaper than descending, as
+				// nothing needs to be allocated. Matters for long lists
+				if equalJSON(a[i], b[i]) {
+					continue
+				}
+
+				// value not a dict, no recursion needed
+				cShould, ok := a[i].(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				// value not a dict, no recursion needed
+				cIs, ok := b[i].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if b[i], err = s.subset(cShould, cIs, child, depth+1); err != nil {
+					return nil, err
 				}
 			}
 		}
 	}
-	return big
+	return big, nil
 }
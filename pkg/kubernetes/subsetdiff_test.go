@@ -1,9 +1,18 @@
 package kubernetes
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/kubernetes/util"
 )
 
 func TestSubset(t *testing.T) {
@@ -137,7 +146,685 @@ func TestSubset(t *testing.T) {
 
 	for _, c := range tests {
 		t.Run(c.name, func(t *testing.T) {
-			assert.Equal(t, c.want, subset(c.should, c.is))
+			got, err := subset(c.should, c.is)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestSubsetDifferEmpty(t *testing.T) {
+	diff, err := SubsetDiffer(nil, SubsetDiffOpts{})(manifest.List{})
+	assert.Nil(t, diff)
+	assert.Equal(t, ErrorNoObjects{}, err)
+}
+
+func TestMultiDiffSkipsEmpty(t *testing.T) {
+	// SubsetDiffer errors on empty input, multiDiff must not pass it any
+	d, err := multiDiff{
+		{differ: SubsetDiffer(nil, SubsetDiffOpts{}), state: manifest.List{}},
+		{differ: StaticDiffer(true), state: manifest.List{}},
+	}.diff()
+	assert.Nil(t, d)
+	assert.NoError(t, err)
+}
+
+// TestSubsetLabelsOrder asserts that labels are compared as maps: differently
+// ordered input must not cause any diff
+func TestSubsetLabelsOrder(t *testing.T) {
+	local := manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":   "grafana",
+			"labels": map[string]interface{}{"app": "grafana", "tier": "frontend"},
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{"app": "grafana", "tier": "frontend"},
+		},
+	}
+
+	var live manifest.Manifest
+	err := json.Unmarshal([]byte(`{
+  "apiVersion": "v1",
+  "kind": "Service",
+  "metadata": {
+    "name": "grafana",
+    "labels": {"tier": "frontend", "app": "grafana"},
+    "uid": "1234"
+  },
+  "spec": {"selector": {"tier": "frontend", "app": "grafana"}}
+}`), &live)
+	require.NoError(t, err)
+
+	sub, err := subset(local, live)
+	require.NoError(t, err)
+	is := manifest.Manifest(sub).String()
+	d, err := util.DiffStr("labels", is, local.String())
+	require.NoError(t, err)
+	assert.Empty(t, d)
+}
+
+func TestDiffAgainst(t *testing.T) {
+	local := manifest.List{
+		configMap("changed", "default", map[string]interface{}{"foo": "new"}),
+		configMap("unchanged", "default", map[string]interface{}{"foo": "bar"}),
+		configMap("created", "default", map[string]interface{}{"foo": "bar"}),
+	}
+
+	liveChanged := configMap("changed", "default", map[string]interface{}{"foo": "old"})
+	liveUnchanged := configMap("unchanged", "default", map[string]interface{}{"foo": "bar"})
+	liveUnchanged.Metadata()["uid"] = "1234"
+	live := manifest.List{liveUnchanged, liveChanged}
+
+	result, err := DiffAgainst(local, live, SubsetDiffOpts{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 3)
+
+	changed := result.Entries[0]
+	assert.Equal(t, "v1.ConfigMap.default.changed", changed.Name)
+	assert.Contains(t, changed.Diff, "-  foo: old")
+	assert.Contains(t, changed.Diff, "+  foo: new")
+
+	unchanged := result.Entries[1]
+	assert.Empty(t, unchanged.Diff)
+
+	created := result.Entries[2]
+	assert.Empty(t, created.Live)
+	assert.Contains(t, created.Diff, "+kind: ConfigMap")
+
+	// inputs must not be modified
+	assert.Equal(t, "1234", live[0].Metadata().UID())
+}
+
+func configMap(name, namespace string, data map[string]interface{}) manifest.Manifest {
+	return manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"data": data,
+	}
+}
+
+// TestSubsetMaxDepth asserts pathologically nested objects are rejected with an
+// error instead of recursing without bounds
+func TestSubsetMaxDepth(t *testing.T) {
+	nest := func(depth int) map[string]interface{} {
+		m := map[string]interface{}{"leaf": "value"}
+		for i := 0; i < depth; i++ {
+			m = map[string]interface{}{"nested": m}
+		}
+		return m
+	}
+
+	_, err := subset(nest(DefaultMaxDepth+1), nest(DefaultMaxDepth+1))
+	assert.Equal(t, ErrorMaxDepth{MaxDepth: DefaultMaxDepth}, err)
+
+	_, err = subset(nest(DefaultMaxDepth), nest(DefaultMaxDepth))
+	assert.NoError(t, err)
+
+	opts := SubsetDiffOpts{MaxDepth: 1000}
+	_, err = opts.subsetter().subset(nest(500), nest(500), "", 0)
+	assert.NoError(t, err)
+}
+
+func TestSubsetDifferAnnotateOwned(t *testing.T) {
+	owned := configMap("owned", "default", map[string]interface{}{"foo": "old"})
+	owned.Metadata()["ownerReferences"] = []interface{}{
+		map[string]interface{}{"kind": "Deployment", "name": "other", "controller": false},
+		map[string]interface{}{"kind": "Operator", "name": "grafana", "controller": true},
+	}
+	free := configMap("free", "default", map[string]interface{}{"foo": "old"})
+
+	c := newFakeClient(owned, free)
+	state := manifest.List{
+		configMap("owned", "default", map[string]interface{}{"foo": "new"}),
+		configMap("free", "default", map[string]interface{}{"foo": "new"}),
+	}
+
+	diff, err := SubsetDiffer(c, SubsetDiffOpts{AnnotateOwned: true})(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Contains(t, *diff, "# v1.ConfigMap.default.owned: (controlled by Operator/grafana, drift may be caused by the controller)\n")
+	assert.NotContains(t, *diff, "# v1.ConfigMap.default.free")
+
+	diff, err = SubsetDiffer(c, SubsetDiffOpts{})(state)
+	require.NoError(t, err)
+	assert.NotContains(t, *diff, "controlled by")
+}
+
+// TestSubsetDifferPanic asserts malformed cluster responses result in an error
+// identifying the object instead of crashing or blocking forever
+func TestSubsetDifferPanic(t *testing.T) {
+	c := newFakeClient()
+	c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		return manifest.Manifest{"kind": kind, "metadata": "garbage"}, nil
+	}
+
+	cases := []struct {
+		name  string
+		opts  SubsetDiffOpts
+		state manifest.Manifest
+	}{
+		{
+			// panics inside of the fetching goroutine
+			name:  "fetch",
+			opts:  SubsetDiffOpts{WithRollout: true},
+			state: deploymentWithImage("grafana/grafana"),
+		},
+		{
+			// panics while comparing
+			name:  "compare",
+			opts:  SubsetDiffOpts{AnnotateOwned: true},
+			state: configMap("foo", "default", nil),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := SubsetDiffer(c, tc.opts)(manifest.List{tc.state})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unexpected error processing "+util.DiffName(tc.state))
+		})
+	}
+}
+
+// TestSubsetDifferNamespaces asserts that objects of the same name in different
+// namespaces are fetched and diffed independently of each other
+func TestSubsetDifferNamespaces(t *testing.T) {
+	c := newFakeClient(
+		configMap("config", "dev", map[string]interface{}{"env": "dev"}),
+		configMap("config", "prod", map[string]interface{}{"env": "staging"}),
+	)
+
+	state := manifest.List{
+		configMap("config", "dev", map[string]interface{}{"env": "dev"}),
+		configMap("config", "prod", map[string]interface{}{"env": "prod"}),
+		configMap("config", "test", map[string]interface{}{"env": "test"}),
+	}
+
+	result, err := diffState(c, state, SubsetDiffOpts{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 3)
+
+	assert.ElementsMatch(t, []string{
+		"get dev ConfigMap config",
+		"get prod ConfigMap config",
+		"get test ConfigMap config",
+	}, c.CallsWith("get "))
+
+	dev, prod, test := result.Entries[0], result.Entries[1], result.Entries[2]
+
+	assert.Equal(t, "v1.ConfigMap.dev.config", dev.Name)
+	assert.Empty(t, dev.Diff)
+
+	assert.Equal(t, "v1.ConfigMap.prod.config", prod.Name)
+	assert.Contains(t, prod.Diff, "-  env: staging")
+	assert.Contains(t, prod.Diff, "+  env: prod")
+	assert.Contains(t, prod.Merged, "namespace: prod")
+	assert.NotContains(t, prod.Diff, "dev")
+
+	assert.Equal(t, "v1.ConfigMap.test.config", test.Name)
+	assert.Empty(t, test.Live)
+	assert.Contains(t, test.Diff, "+  namespace: test")
+}
+
+// TestSubsetDifferRendered diffs manifests loaded from a directory, like the
+// committed output of `tk export`, against the cluster
+func TestSubsetDifferRendered(t *testing.T) {
+	state, err := manifest.LoadDir("manifest/testdata/rendered")
+	require.NoError(t, err)
+
+	c := newFakeClient(
+		configMap("grafana-config", "monitoring", map[string]interface{}{"grafana.ini": "[server]"}),
+		configMap("grafana-dashboards", "monitoring", nil),
+	)
+
+	result, err := diffState(c, state, SubsetDiffOpts{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 3)
+
+	deploy, config, dashboards := result.Entries[0], result.Entries[1], result.Entries[2]
+
+	assert.Equal(t, "apps-v1.Deployment.monitoring.grafana", deploy.Name)
+	assert.Equal(t, PlanCreate, deploy.Action())
+
+	assert.Equal(t, "v1.ConfigMap.monitoring.grafana-config", config.Name)
+	assert.Contains(t, config.Diff, "-  grafana.ini: '[server]'")
+	assert.Equal(t, PlanUpdate, config.Action())
+
+	assert.Equal(t, "v1.ConfigMap.monitoring.grafana-dashboards", dashboards.Name)
+	assert.Empty(t, dashboards.Diff)
+}
+
+// TestSubsetDifferTolerateUnreachable asserts that objects of an unreachable
+// region are reported as unknown drift, while all others are diffed
+func TestSubsetDifferTolerateUnreachable(t *testing.T) {
+	live := map[string]manifest.Manifest{
+		"us": configMap("config", "us", map[string]interface{}{"region": "old"}),
+	}
+	forbidden := errors.New(`Error from server (Forbidden): configmaps "config" is forbidden`)
+
+	c := newFakeClient()
+	c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		switch namespace {
+		case "eu", "ap":
+			return nil, client.ErrorConnection{}
+		case "rbac":
+			return nil, forbidden
+		}
+		if m, ok := live[namespace]; ok {
+			return manifest.Manifest(copyMSI(m)), nil
+		}
+		return nil, client.ErrorNotFound{}
+	}
+
+	state := manifest.List{
+		configMap("config", "us", map[string]interface{}{"region": "us"}),
+		configMap("config", "eu", map[string]interface{}{"region": "eu"}),
+		configMap("config", "ap", map[string]interface{}{"region": "ap"}),
+	}
+
+	opts := SubsetDiffOpts{TolerateUnreachable: true}
+	result, err := diffState(c, state, opts)
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 3)
+
+	us := result.Entries[0]
+	assert.False(t, us.Unknown)
+	assert.Contains(t, us.Diff, "+  region: us")
+	assert.Equal(t, PlanUpdate, us.Action())
+
+	for _, e := range result.Entries[1:] {
+		assert.True(t, e.Unknown)
+		assert.Empty(t, e.Diff)
+		assert.Equal(t, PlanUnknown, e.Action())
+	}
+	assert.Equal(t, []string{"v1.ConfigMap.eu.config", "v1.ConfigMap.ap.config"}, result.UnknownDrift())
+
+	diff, err := SubsetDiffer(c, opts)(state)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	assert.Contains(t, *diff, "+  region: us")
+	assert.Contains(t, *diff, "\n# v1.ConfigMap.eu.config: unknown drift, cluster unreachable\n# v1.ConfigMap.ap.config: unknown drift, cluster unreachable\n")
+
+	// fail by default
+	_, err = diffState(c, state, SubsetDiffOpts{})
+	var e ErrorDiff
+	require.True(t, errors.As(err, &e), err)
+	assert.Equal(t, DiffPhaseFetch, e.Phase)
+
+	// errors returned by the cluster are never tolerated
+	_, err = diffState(c, manifest.List{configMap("config", "rbac", nil)}, opts)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, forbidden), err)
+}
+
+// benchDeployment returns a Deployment with the given number of containers,
+// each carrying several environment variables and ports
+func benchDeployment(name string, containers int) manifest.Manifest {
+	cs := make([]interface{}, containers)
+	for i := range cs {
+		env := make([]interface{}, 10)
+		for j := range env {
+			env[j] = map[string]interface{}{"name": fmt.Sprintf("VAR_%d", j), "value": fmt.Sprintf("value-%d", j)}
+		}
+		cs[i] = map[string]interface{}{
+			"name":  fmt.Sprintf("container-%d", i),
+			"image": "grafana/grafana:7.3.0",
+			"env":   env,
+			"ports": []interface{}{
+				map[string]interface{}{"name": "http", "containerPort": float64(3000)},
+			},
+		}
+	}
+
+	m := deploymentWithImage("grafana/grafana:7.3.0")
+	m.Metadata()["name"] = name
+	m["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"] = cs
+	return m
+}
+
+// benchLive returns m as returned by the cluster, i.e. with server-side fields
+// added
+func benchLive(m manifest.Manifest) manifest.Manifest {
+	live := manifest.Manifest(copyMSI(m))
+	live.Metadata()["uid"] = "dc8a0ba2-1a15-4bbd-a29f-0e5bfd9a5c42"
+	live.Metadata()["resourceVersion"] = "123456"
+	live.Metadata()["creationTimestamp"] = "2020-01-01T00:00:00Z"
+	live["status"] = map[string]interface{}{"replicas": float64(1), "readyReplicas": float64(1)}
+
+	spec := live["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	spec["dnsPolicy"] = "ClusterFirst"
+	for _, c := range spec["containers"].([]interface{}) {
+		c := c.(map[string]interface{})
+		c["imagePullPolicy"] = "IfNotPresent"
+		c["terminationMessagePath"] = "/dev/termination-log"
+	}
+	return live
+}
+
+func BenchmarkSubset(b *testing.B) {
+	for _, containers := range []int{1, 10, 100} {
+		local := benchDeployment("grafana", containers)
+		live := benchLive(local)
+
+		b.Run(fmt.Sprintf("containers=%d", containers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// subset modifies big
+				b.StopTimer()
+				big := copyMSI(live)
+				b.StartTimer()
+
+				if _, err := subset(local, big); err != nil {
+					b.Fatal(err)
+				}
+			}
 		})
 	}
 }
+
+// benchPolicy returns a NetworkPolicy with the given number of ingress rules.
+// If live is set, the rules carry the fields defaulted by the cluster
+func benchPolicy(rules int, live bool) map[string]interface{} {
+	ingress := make([]interface{}, rules)
+	for i := range ingress {
+		port := map[string]interface{}{"port": float64(1000 + i)}
+		if live {
+			port["protocol"] = "TCP"
+		}
+		ingress[i] = map[string]interface{}{
+			"from": []interface{}{
+				map[string]interface{}{"ipBlock": map[string]interface{}{"cidr": fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)}},
+			},
+			"ports": []interface{}{port},
+		}
+	}
+	m := map[string]interface{}{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "NetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "allow", "namespace": "default"},
+		"spec":       map[string]interface{}{"ingress": ingress},
+	}
+	if live {
+		m["metadata"].(map[string]interface{})["uid"] = "dc8a0ba2-1a15-4bbd-a29f-0e5bfd9a5c42"
+	}
+	return m
+}
+
+// TestSubsetLargeList asserts long lists are reduced like short ones, both for
+// equal items (short-circuited) and for differing ones (descended into)
+func TestSubsetLargeList(t *testing.T) {
+	local := benchPolicy(5000, false)
+	live := benchPolicy(5000, false)
+
+	// item 100 differs, the last one has server-side fields
+	rules := live["spec"].(map[string]interface{})["ingress"].([]interface{})
+	rules[100].(map[string]interface{})["ports"] = []interface{}{map[string]interface{}{"port": float64(8080)}}
+	rules[4999] = benchPolicy(5000, true)["spec"].(map[string]interface{})["ingress"].([]interface{})[4999]
+
+	got, err := subset(local, copyMSI(live))
+	require.NoError(t, err)
+
+	want := benchPolicy(5000, false)
+	want["spec"].(map[string]interface{})["ingress"].([]interface{})[100] = rules[100]
+	assert.Equal(t, want, got)
+
+	// all server-side fields are dropped
+	got, err = subset(local, benchPolicy(5000, true))
+	require.NoError(t, err)
+	assert.Equal(t, benchPolicy(5000, false), got)
+}
+
+func BenchmarkSubsetLargeList(b *testing.B) {
+	for _, equal := range []bool{true, false} {
+		local := benchPolicy(5000, false)
+		live := benchPolicy(5000, !equal)
+
+		b.Run(fmt.Sprintf("equal=%v", equal), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// subset modifies big
+				b.StopTimer()
+				big := copyMSI(live)
+				b.StartTimer()
+
+				if _, err := subset(local, big); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSubsetDiffer measures the whole diff without any network, by using
+// an in-memory client. Note that this includes invoking diff(1) per object.
+func BenchmarkSubsetDiffer(b *testing.B) {
+	for _, objects := range []int{1, 10, 100} {
+		var state, live manifest.List
+		for i := 0; i < objects; i++ {
+			m := benchDeployment(fmt.Sprintf("grafana-%d", i), 3)
+			state = append(state, m)
+
+			l := benchLive(m)
+			if i%2 == 0 {
+				l["spec"].(map[string]interface{})["replicas"] = float64(2)
+				m["spec"].(map[string]interface{})["replicas"] = float64(3)
+			}
+			live = append(live, l)
+		}
+
+		differ := SubsetDiffer(newFakeClient(live...), SubsetDiffOpts{})
+		b.Run(fmt.Sprintf("objects=%d", objects), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := differ(state); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSubsetDifferUnexpectedResponse(t *testing.T) {
+	cases := []struct {
+		name   string
+		live   manifest.Manifest
+		reason string
+	}{
+		{
+			name:   "list",
+			live:   manifest.Manifest{"kind": "List", "items": []interface{}{configMap("foo", "default", nil)}},
+			reason: "got a list instead of a single object",
+		},
+		{
+			name:   "empty",
+			live:   nil,
+			reason: "got an empty response",
+		},
+		{
+			name:   "scalar-kind",
+			live:   manifest.Manifest{"kind": float64(1), "metadata": map[string]interface{}{}},
+			reason: "expected an object of kind ConfigMap, but the response has no kind",
+		},
+		{
+			name:   "other-kind",
+			live:   manifest.Manifest{"kind": "Secret", "metadata": map[string]interface{}{}},
+			reason: "expected an object of kind ConfigMap, but got Secret",
+		},
+	}
+
+	state := configMap("foo", "default", nil)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newFakeClient()
+			c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+				return tc.live, nil
+			}
+
+			_, err := SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{state})
+			require.Error(t, err)
+
+			var e ErrorUnexpectedResponse
+			require.True(t, errors.As(err, &e), err.Error())
+			assert.Equal(t, ErrorUnexpectedResponse{Ref: RefOf(state), Reason: tc.reason}, e)
+		})
+	}
+}
+
+// TestSubsetDifferMixedNamespaces asserts that the objects of a single List
+// are each fetched from their own namespace
+func TestSubsetDifferMixedNamespaces(t *testing.T) {
+	role := manifest.Manifest{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "ClusterRole",
+		"metadata":   map[string]interface{}{"name": "reader", "namespace": "ignored"},
+	}
+	list := manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items": []interface{}{
+			map[string]interface{}(configMap("a", "monitoring", map[string]interface{}{"foo": "bar"})),
+			map[string]interface{}(configMap("b", "logging", map[string]interface{}{"foo": "bar"})),
+			map[string]interface{}(configMap("c", "default", map[string]interface{}{"foo": "bar"})),
+			map[string]interface{}(role),
+		},
+	}
+	state, err := list.Items()
+	require.NoError(t, err)
+
+	for name, opts := range map[string]SubsetDiffOpts{
+		"plain":  {},
+		"cached": {Cache: FileDiffCache{Dir: t.TempDir()}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := newFakeClient()
+			c.resources = client.Resources{
+				{Kind: "ConfigMap", Namespaced: true},
+				{Kind: "ClusterRole", APIGroup: "rbac.authorization.k8s.io", Namespaced: false},
+			}
+
+			_, err = SubsetDiffer(c, opts)(state)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, []string{
+				"get monitoring ConfigMap a",
+				"get logging ConfigMap b",
+				"get default ConfigMap c",
+				"get  ClusterRole reader",
+			}, c.CallsWith("get "))
+		})
+	}
+}
+
+func TestSubsetDiffKubectlFormat(t *testing.T) {
+	local := deploymentWithImage("grafana/grafana:7.3.0")
+	live := deploymentWithImage("grafana/grafana:7.2.0")
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{KubectlFormat: true})
+	require.NoError(t, err)
+
+	e := result.Entries[0]
+	assert.Equal(t, "apps-v1.Deployment.default.grafana", e.Name)
+	assert.Regexp(t, `^diff -u -N \S+/LIVE-\d+/apps\.v1\.Deployment\.default\.grafana \S+/MERGED-\d+/apps\.v1\.Deployment\.default\.grafana\n`, e.Diff)
+}
+
+// TestErrorDiff asserts failures carry the object and phase they occurred in
+func TestErrorDiff(t *testing.T) {
+	state := manifest.List{configMap("foo", "default", map[string]interface{}{"key": "value"})}
+	ref := ObjectRef{Version: "v1", Kind: "ConfigMap", Namespace: "default", Name: "foo"}
+
+	t.Run("fetch", func(t *testing.T) {
+		refused := errors.New("connection refused")
+		c := newFakeClient()
+		c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+			return nil, refused
+		}
+
+		_, err := SubsetDiffer(c, SubsetDiffOpts{})(state)
+		var e ErrorDiff
+		require.True(t, errors.As(err, &e), err.Error())
+		assert.Equal(t, ref, e.Ref)
+		assert.Equal(t, DiffPhaseFetch, e.Phase)
+		assert.True(t, errors.Is(err, refused))
+		assert.Contains(t, err.Error(), "diffing v1/ConfigMap/default/foo (fetch): getting state from cluster: connection refused")
+	})
+
+	t.Run("render", func(t *testing.T) {
+		defer func(orig func(string, string, string) (string, error)) { diffStr = orig }(diffStr)
+		broken := errors.New("diff: not found")
+		diffStr = func(name, is, should string) (string, error) {
+			return "", broken
+		}
+
+		_, err := SubsetDiffer(newFakeClient(), SubsetDiffOpts{})(state)
+		var e ErrorDiff
+		require.True(t, errors.As(err, &e), err.Error())
+		assert.Equal(t, ref, e.Ref)
+		assert.Equal(t, DiffPhaseRender, e.Phase)
+		assert.True(t, errors.Is(err, broken))
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		deep := manifest.List{configMap("foo", "default", map[string]interface{}{
+			"a": map[string]interface{}{"b": map[string]interface{}{"c": "d"}},
+		})}
+		_, err := SubsetDiffer(newFakeClient(deep[0]), SubsetDiffOpts{MaxDepth: 1})(deep)
+		require.Error(t, err)
+		var e ErrorDiff
+		require.True(t, errors.As(err, &e), err.Error())
+		assert.Equal(t, DiffPhaseRender, e.Phase)
+
+		var depth ErrorMaxDepth
+		assert.True(t, errors.As(err, &depth))
+	})
+}
+
+// TestSubsetDifferContinueOnError asserts the diffs of the other objects are
+// returned alongside the error, if rendering one fails
+func TestSubsetDifferContinueOnError(t *testing.T) {
+	orig := diffStr
+	defer func() { diffStr = orig }()
+	broken := errors.New("diff: broken pipe")
+	diffStr = func(name, is, should string) (string, error) {
+		if strings.Contains(name, "broken") {
+			return "", broken
+		}
+		return orig(name, is, should)
+	}
+
+	c := newFakeClient(
+		configMap("broken", "default", map[string]interface{}{"foo": "old"}),
+		configMap("fine", "default", map[string]interface{}{"foo": "old"}),
+	)
+	state := manifest.List{
+		configMap("broken", "default", map[string]interface{}{"foo": "new"}),
+		configMap("fine", "default", map[string]interface{}{"foo": "new"}),
+	}
+
+	// aborts by default
+	d, err := SubsetDiffer(c, SubsetDiffOpts{})(state)
+	require.Error(t, err)
+	assert.Nil(t, d)
+
+	d, err = SubsetDiffer(c, SubsetDiffOpts{ContinueOnError: true})(state)
+	var errs ErrorDiffs
+	require.True(t, errors.As(err, &errs), err)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "broken", errs[0].Ref.Name)
+	assert.Equal(t, DiffPhaseRender, errs[0].Phase)
+	assert.True(t, errors.Is(errs[0], broken))
+	assert.Contains(t, err.Error(), "diffing 1 objects failed:\n- diffing v1/ConfigMap/default/broken (render)")
+
+	require.NotNil(t, d)
+	assert.Contains(t, *d, "v1.ConfigMap.default.fine")
+	assert.Contains(t, *d, "+  foo: new")
+	assert.NotContains(t, *d, "v1.ConfigMap.default.broken")
+
+	// nothing failed
+	d, err = SubsetDiffer(c, SubsetDiffOpts{ContinueOnError: true})(state[1:])
+	require.NoError(t, err)
+	require.NotNil(t, d)
+}
@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestSubsetDiffUnicode(t *testing.T) {
+	local := configMap("i18n", "default", map[string]interface{}{
+		"greeting": "Grüße aus München",
+		"japanese": "こんにちは世界",
+		"emoji":    "🚀 launch",
+		"multi":    "первая строка\nвторая строка — новая\n",
+	})
+	local.Metadata()["annotations"] = map[string]interface{}{"description": "Überblick 概要"}
+
+	live := configMap("i18n", "default", map[string]interface{}{
+		"greeting": "Grüße aus Berlin",
+		"japanese": "こんにちは世界",
+		"emoji":    "🛸 launch",
+		"multi":    "первая строка\nвторая строка\n",
+	})
+	live.Metadata()["annotations"] = map[string]interface{}{"description": "Überblick 概要"}
+
+	result, err := DiffAgainst(manifest.List{local}, manifest.List{live}, SubsetDiffOpts{AnnotatePaths: true})
+	require.NoError(t, err)
+
+	e := result.Entries[0]
+	require.True(t, utf8.ValidString(e.Diff))
+
+	for _, l := range []string{
+		"-  emoji: 🛸 launch",
+		"+  emoji: 🚀 launch",
+		"-  greeting: Grüße aus Berlin",
+		"+  greeting: Grüße aus München",
+		"-    вторая строка",
+		"+    вторая строка — новая",
+		"   japanese: こんにちは世界",
+	} {
+		assert.Contains(t, e.Diff, l)
+	}
+	assert.Contains(t, e.Diff, " @@ data.emoji, data.greeting, data.multi\n")
+	assert.Contains(t, e.Merged, "    description: Überblick 概要\n")
+}
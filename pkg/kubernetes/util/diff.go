@@ -32,19 +32,59 @@ func DiffStr(name, is, should string) (string, error) {
 	}
 	defer os.RemoveAll(dir)
 
-	if err := ioutil.WriteFile(filepath.Join(dir, "LIVE-"+name), []byte(is), os.ModePerm); err != nil {
+	return diffFiles(
+		filepath.Join(dir, "LIVE-"+name), is,
+		filepath.Join(dir, "MERGED-"+name), should,
+	)
+}
+
+// KubectlDiffName computes the filename `kubectl diff` uses for m, which is
+// `<group>.<version>.<kind>.<namespace>.<name>`
+func KubectlDiffName(m manifest.Manifest) string {
+	return strings.Replace(fmt.Sprintf("%s.%s.%s.%s",
+		m.APIVersion(),
+		m.Kind(),
+		m.Metadata().Namespace(),
+		m.Metadata().Name(),
+	), "/", ".", -1)
+}
+
+// KubectlDiffStr is like DiffStr, but lays out the files like `kubectl diff`
+// (LIVE-<random>/<name> and MERGED-<random>/<name>), so the output can be
+// parsed by tools expecting the format of kubectl.
+func KubectlDiffStr(name, is, should string) (string, error) {
+	liveDir, err := ioutil.TempDir("", "LIVE-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(liveDir)
+
+	mergedDir, err := ioutil.TempDir("", "MERGED-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(mergedDir)
+
+	return diffFiles(
+		filepath.Join(liveDir, name), is,
+		filepath.Join(mergedDir, name), should,
+	)
+}
+
+// diffFiles writes is to live and should to merged and compares them using
+// `diff -u -N`
+func diffFiles(live, is, merged, should string) (string, error) {
+	if err := ioutil.WriteFile(live, []byte(is), os.ModePerm); err != nil {
 		return "", err
 	}
-	if err := ioutil.WriteFile(filepath.Join(dir, "MERGED-"+name), []byte(should), os.ModePerm); err != nil {
+	if err := ioutil.WriteFile(merged, []byte(should), os.ModePerm); err != nil {
 		return "", err
 	}
 
 	buf := bytes.Buffer{}
-	merged := filepath.Join(dir, "MERGED-"+name)
-	live := filepath.Join(dir, "LIVE-"+name)
 	cmd := exec.Command("diff", "-u", "-N", live, merged)
 	cmd.Stdout = &buf
-	err = cmd.Run()
+	err := cmd.Run()
 
 	// the diff utility exits with `1` if there are differences. We need to not fail there.
 	if exitError, ok := err.(*exec.ExitError); ok && err != nil {
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"io/ioutil"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+var (
+	// kubectl diff uses random directory names and the current time
+	tmpDirs    = regexp.MustCompile(`\S*/(LIVE|MERGED)-\d+/`)
+	timestamps = regexp.MustCompile(`\t\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+ [+-]\d{4}`)
+)
+
+func normalize(d string) string {
+	d = tmpDirs.ReplaceAllString(d, "/tmp/$1-000000000/")
+	return timestamps.ReplaceAllString(d, "\t2020-01-01 00:00:00.000000000 +0000")
+}
+
+func TestKubectlDiffName(t *testing.T) {
+	deploy := manifest.Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "grafana", "namespace": "default"},
+	}
+	assert.Equal(t, "apps.v1.Deployment.default.grafana", KubectlDiffName(deploy))
+
+	cm := manifest.Manifest{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "foo", "namespace": "default"},
+	}
+	assert.Equal(t, "v1.ConfigMap.default.foo", KubectlDiffName(cm))
+}
+
+// TestKubectlDiffStr compares the output against the one of `kubectl diff`
+// (captured using 1.19, with temporary paths and timestamps normalized)
+func TestKubectlDiffStr(t *testing.T) {
+	live := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: grafana
+  namespace: default
+spec:
+  replicas: 1
+`
+	merged := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: grafana
+  namespace: default
+spec:
+  replicas: 3
+`
+
+	want, err := ioutil.ReadFile("testdata/kubectl-diff.golden")
+	require.NoError(t, err)
+
+	got, err := KubectlDiffStr("apps.v1.Deployment.default.grafana", live, merged)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), normalize(got))
+
+	// no differences
+	got, err = KubectlDiffStr("apps.v1.Deployment.default.grafana", live, live)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
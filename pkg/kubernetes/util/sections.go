@@ -0,0 +1,157 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SplitDiff splits a diff of multiple objects into the sections of each
+// object. A section starts with a `diff -` line, comments (#) directly
+// preceding it are part of it as well. Blank lines separating the sections are
+// dropped, those within a hunk are kept. Everything else, like remarks
+// following the last section, is returned as rest.
+func SplitDiff(d string) (sections []string, rest string) {
+	var cur, pending, tail strings.Builder
+	inSection := false
+	// lines of the current hunk yet to come, as stated by its header
+	remOld, remNew := 0, 0
+
+	flush := func() {
+		if inSection {
+			sections = append(sections, cur.String())
+		}
+		cur.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(d, "\n") {
+		if inSection && (remOld > 0 || remNew > 0) && line != "" {
+			cur.WriteString(line)
+			switch line[0] {
+			case '-':
+				remOld--
+			case '+':
+				remNew--
+			case '\\':
+			default:
+				remOld--
+				remNew--
+			}
+			continue
+		}
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "diff -"):
+			flush()
+			inSection = true
+			cur.WriteString(pending.String())
+			pending.Reset()
+			cur.WriteString(line)
+		case strings.HasPrefix(line, "#"):
+			pending.WriteString(line)
+		case line == "\n":
+			continue
+		case inSection && pending.Len() == 0 && isDiffLine(line):
+			if strings.HasPrefix(line, "@@ ") {
+				remOld, remNew = hunkLines(line)
+			}
+			cur.WriteString(line)
+		default:
+			tail.WriteString(pending.String())
+			pending.Reset()
+			tail.WriteString(line)
+		}
+	}
+	flush()
+	tail.WriteString(pending.String())
+
+	return sections, tail.String()
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// hunkLines returns the number of old and new lines of the hunk
+// starting with header, like `@@ -6,7 +6,8 @@`. Counts omitted from the
+// header are 1.
+func hunkLines(header string) (old, new int) {
+	m := hunkHeader.FindStringSubmatch(header)
+	if m == nil {
+		return 0, 0
+	}
+
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	return count(m[1]), count(m[2])
+}
+
+// isDiffLine returns whether line belongs to the body of a unified diff
+func isDiffLine(line string) bool {
+	return strings.IndexAny(line[:1], " +-@\\") == 0
+}
+
+// ChangedLines returns the number of added and removed lines of a diff,
+// excluding the file headers
+func ChangedLines(d string) int {
+	n := 0
+	for _, line := range strings.Split(d, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			continue
+		case strings.HasPrefix(line, "+"), strings.HasPrefix(line, "-"):
+			n++
+		}
+	}
+	return n
+}
+
+// ArrangeDiff drops the sections (see SplitDiff) of d with less than min
+// changed lines. Their number is stated at the end, so changes are never
+// hidden silently. If byChanges is set, the remaining sections are ordered by
+// the number of changed lines, the largest first. Sections with the same
+// number of changes keep their order.
+func ArrangeDiff(d string, min int, byChanges bool) string {
+	sections, rest := SplitDiff(d)
+
+	// keep blank lines between the sections, if d has them
+	sep := ""
+	if strings.Contains(d, "\n\n") {
+		sep = "\n"
+	}
+
+	kept := make([]string, 0, len(sections))
+	for _, s := range sections {
+		if ChangedLines(s) >= min {
+			kept = append(kept, s)
+		}
+	}
+	if byChanges {
+		sort.SliceStable(kept, func(i, j int) bool {
+			return ChangedLines(kept[i]) > ChangedLines(kept[j])
+		})
+	}
+
+	switch hidden := len(sections) - len(kept); {
+	case hidden == 1:
+		rest += fmt.Sprintf("# 1 object with less than %d changed lines hidden\n", min)
+	case hidden > 1:
+		rest += fmt.Sprintf("# %d objects with less than %d changed lines hidden\n", hidden, min)
+	}
+
+	out := strings.Join(kept, sep)
+	if rest != "" {
+		if out != "" {
+			out += sep
+		}
+		out += rest
+	}
+	return out
+}
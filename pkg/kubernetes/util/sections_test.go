@@ -0,0 +1,121 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	sectionSmall = `diff -u -N /tmp/LIVE-small /tmp/MERGED-small
+--- /tmp/LIVE-small
++++ /tmp/MERGED-small
+@@ -1,2 +1,2 @@
+ kind: ConfigMap
+-  key: old
++  key: new
+`
+	sectionLarge = `# large: (will restart pods)
+diff -u -N /tmp/LIVE-large /tmp/MERGED-large
+--- /tmp/LIVE-large
++++ /tmp/MERGED-large
+@@ -1,3 +1,3 @@
+ kind: Deployment
+-  replicas: 1
++  replicas: 2
+-  image: old
++  image: new
+`
+	sectionOne = `diff -u -N /tmp/LIVE-one /tmp/MERGED-one
+--- /tmp/LIVE-one
++++ /tmp/MERGED-one
+@@ -1 +1,2 @@
+ kind: Service
++  port: 80
+`
+)
+
+func TestSplitDiff(t *testing.T) {
+	d := sectionSmall + "\n" + sectionLarge + "\n" + sectionOne + "\n# 1 object has ignored drift\n"
+
+	sections, rest := SplitDiff(d)
+	assert.Equal(t, []string{sectionSmall, sectionLarge, sectionOne}, sections)
+	assert.Equal(t, "# 1 object has ignored drift\n", rest)
+
+	assert.Equal(t, []int{2, 4, 1}, []int{ChangedLines(sections[0]), ChangedLines(sections[1]), ChangedLines(sections[2])})
+}
+
+func TestArrangeDiff(t *testing.T) {
+	native := sectionSmall + sectionLarge + sectionOne
+	rendered := sectionSmall + "\n" + sectionLarge + "\n" + sectionOne
+
+	cases := []struct {
+		name      string
+		d         string
+		min       int
+		byChanges bool
+		want      string
+	}{
+		{
+			name:      "sorted",
+			d:         native,
+			byChanges: true,
+			want:      sectionLarge + sectionSmall + sectionOne,
+		},
+		{
+			name:      "sorted-separated",
+			d:         rendered,
+			byChanges: true,
+			want:      sectionLarge + "\n" + sectionSmall + "\n" + sectionOne,
+		},
+		{
+			name: "min-changes",
+			d:    native,
+			min:  2,
+			want: sectionSmall + sectionLarge + "# 1 object with less than 2 changed lines hidden\n",
+		},
+		{
+			name:      "min-changes-sorted",
+			d:         rendered + "\ndiff truncated, 3 of 4 objects shown\n",
+			min:       3,
+			byChanges: true,
+			want:      sectionLarge + "\ndiff truncated, 3 of 4 objects shown\n# 2 objects with less than 3 changed lines hidden\n",
+		},
+		{
+			name: "all-hidden",
+			d:    native,
+			min:  10,
+			want: "# 3 objects with less than 10 changed lines hidden\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, ArrangeDiff(c.d, c.min, c.byChanges))
+		})
+	}
+}
+
+func TestSplitDiffBlankLines(t *testing.T) {
+	// blank YAML lines within block scalars, as diff(1) renders them: with a
+	// single space of context, or empty (--suppress-blank-empty)
+	blank := `diff -u -N /tmp/LIVE-blank /tmp/MERGED-blank
+--- /tmp/LIVE-blank
++++ /tmp/MERGED-blank
+@@ -1,5 +1,6 @@
+ data:
+   script: |
++    b
+ 
+     c
+
+     d
+`
+
+	sections, rest := SplitDiff(sectionSmall + "\n" + blank + "\n" + sectionOne)
+	assert.Equal(t, []string{sectionSmall, blank, sectionOne}, sections)
+	assert.Empty(t, rest)
+
+	// reordered intact, so the hunk counts still match
+	assert.Equal(t, sectionSmall+"\n"+blank+"\n"+sectionOne, ArrangeDiff(blank+"\n"+sectionSmall+"\n"+sectionOne, 0, true))
+}
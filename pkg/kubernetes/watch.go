@@ -0,0 +1,105 @@
+package kubernetes
+
+import (
+	"time"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+// DefaultWatchInterval is the time between two diffs of WatchDiff by default
+const DefaultWatchInterval = 30 * time.Second
+
+// WatchOpts allow to tune WatchDiff
+type WatchOpts struct {
+	SubsetDiffOpts
+
+	// Interval is the time between the end of a diff and the start of the
+	// next one. Defaults to DefaultWatchInterval
+	Interval time.Duration
+
+	// Debounce is the number of consecutive diffs a change of drift must
+	// persist for, before it is reported. Defaults to 1
+	Debounce int
+}
+
+// WatchEvent reports that drift appeared or cleared
+type WatchEvent struct {
+	// Drift is set if the cluster differs from the desired state
+	Drift bool
+	// Result of the diff that caused the event
+	Result *DiffResult
+
+	// Err is set if a diff failed. Watching continues regardless
+	Err error
+}
+
+// WatchDiff repeatedly compares state to the cluster and sends an event
+// whenever drift appears or clears. The first event reports the initial
+// drift. Diffs never overlap, the next one only starts opts.Interval after
+// the previous one finished. Watching stops once stop is closed, after which
+// the returned channel is closed as well.
+func WatchDiff(c client.Client, state manifest.List, opts WatchOpts, stop <-chan struct{}) <-chan WatchEvent {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultWatchInterval
+	}
+	if opts.Debounce < 1 {
+		opts.Debounce = 1
+	}
+
+	events := make(chan WatchEvent)
+	go func() {
+		defer close(events)
+
+		send := func(e WatchEvent) bool {
+			select {
+			case events <- e:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+
+		state = filterKinds(state, opts.Kinds)
+		if len(state) == 0 {
+			send(WatchEvent{Err: ErrorNoObjects{}})
+			return
+		}
+		if opts.DetectListTypes && opts.ListTypes == nil {
+			opts.ListTypes = detectListTypes(c)
+		}
+
+		var reported *bool
+		pending, streak := false, 0
+		for {
+			result, err := diffState(c, state, opts.SubsetDiffOpts)
+			switch {
+			case err != nil:
+				if !send(WatchEvent{Err: err}) {
+					return
+				}
+			default:
+				drift := result.HasDrift()
+				if drift != pending {
+					pending, streak = drift, 0
+				}
+				streak++
+
+				if streak >= opts.Debounce && (reported == nil || *reported != drift) {
+					if !send(WatchEvent{Drift: drift, Result: result}) {
+						return
+					}
+					reported = &drift
+				}
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(opts.Interval):
+			}
+		}
+	}()
+
+	return events
+}
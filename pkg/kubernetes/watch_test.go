@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestWatchDiff(t *testing.T) {
+	state := manifest.List{configMap("foo", "default", map[string]interface{}{"key": "want"})}
+
+	// the value of the live object on each run. The single drifting run is
+	// debounced
+	values := []string{"want", "want", "blip", "want", "drift", "drift", "want", "want"}
+
+	var mu sync.Mutex
+	runs := 0
+	c := newFakeClient()
+	c.getFunc = func(namespace, kind, name string) (manifest.Manifest, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		v := values[len(values)-1]
+		if runs < len(values) {
+			v = values[runs]
+		}
+		runs++
+		return configMap("foo", "default", map[string]interface{}{"key": v}), nil
+	}
+
+	stop := make(chan struct{})
+	events := WatchDiff(c, state, WatchOpts{Interval: time.Millisecond, Debounce: 2}, stop)
+
+	var got []bool
+	for len(got) < 3 {
+		select {
+		case e := <-events:
+			require.NoError(t, e.Err)
+			got = append(got, e.Drift)
+			if e.Drift {
+				assert.Contains(t, e.Result.String(), "+  key: want")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for events")
+		}
+	}
+	close(stop)
+
+	assert.Equal(t, []bool{false, true, false}, got)
+
+	// the channel is closed once stopped
+	for range events {
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.GreaterOrEqual(t, runs, len(values))
+}
+
+func TestWatchDiffError(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	events := WatchDiff(newFakeClient(), manifest.List{}, WatchOpts{}, stop)
+	e := <-events
+	assert.Equal(t, ErrorNoObjects{}, e.Err)
+
+	_, open := <-events
+	assert.False(t, open)
+}
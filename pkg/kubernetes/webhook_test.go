@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func webhookConfig(kind string, caBundle string, names ...string) manifest.Manifest {
+	hooks := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		clientConfig := map[string]interface{}{
+			"service": map[string]interface{}{"name": "policy", "namespace": "default", "path": "/" + name},
+		}
+		if caBundle != "" {
+			clientConfig["caBundle"] = caBundle
+		}
+		hooks = append(hooks, map[string]interface{}{
+			"name":                    name + ".example.com",
+			"clientConfig":            clientConfig,
+			"admissionReviewVersions": []interface{}{"v1"},
+			"sideEffects":             "None",
+		})
+	}
+
+	return manifest.Manifest{
+		"apiVersion": "admissionregistration.k8s.io/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": "policy"},
+		"webhooks":   hooks,
+	}
+}
+
+func TestSubsetDifferWebhooks(t *testing.T) {
+	for _, kind := range []string{"ValidatingWebhookConfiguration", "MutatingWebhookConfiguration"} {
+		t.Run(kind, func(t *testing.T) {
+			// reordered, with the caBundle injected by cert-manager
+			live := webhookConfig(kind, "LS0tLS1CRUdJTi==", "pods", "deployments")
+			c := newFakeClient(live)
+
+			// the injected caBundle is ignored
+			d, err := SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{webhookConfig(kind, "", "deployments", "pods")})
+			require.NoError(t, err)
+			assert.Nil(t, d)
+
+			// also if compared exactly, which does not reorder the webhooks
+			exact := SubsetDiffOpts{KindStrategies: map[string]string{kind: ObjectStrategyExact}}
+			d, err = SubsetDiffer(c, exact)(manifest.List{webhookConfig(kind, "", "pods", "deployments")})
+			require.NoError(t, err)
+			assert.Nil(t, d)
+
+			// a caBundle set locally is compared
+			d, err = SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{webhookConfig(kind, "Cg==", "deployments", "pods")})
+			require.NoError(t, err)
+			require.NotNil(t, d)
+			assert.Contains(t, *d, "+    caBundle: Cg==")
+
+			// actual changes are still found
+			local := webhookConfig(kind, "", "deployments", "pods")
+			local["webhooks"].([]interface{})[1].(map[string]interface{})["sideEffects"] = "NoneOnDryRun"
+			d, err = SubsetDiffer(c, SubsetDiffOpts{})(manifest.List{local})
+			require.NoError(t, err)
+			require.NotNil(t, d)
+			assert.Contains(t, *d, "+  sideEffects: NoneOnDryRun")
+			assert.NotContains(t, *d, "caBundle")
+		})
+	}
+}
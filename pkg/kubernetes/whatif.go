@@ -0,0 +1,104 @@
+package kubernetes
+
+import (
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/kubernetes/util"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// PlanAction is what applying the desired state does to an object
+type PlanAction string
+
+// Actions of a Plan
+const (
+	PlanCreate    PlanAction = "create"
+	PlanUpdate    PlanAction = "update"
+	PlanUnchanged PlanAction = "unchanged"
+	PlanPrune     PlanAction = "prune"
+	// PlanSkip is used for objects excluded from diffing using
+	// AnnotationDiffStrategy
+	PlanSkip PlanAction = "skip"
+	// PlanUnknown is used for objects that could not be compared, because the
+	// cluster was unreachable (see SubsetDiffOpts.TolerateUnreachable)
+	PlanUnknown PlanAction = "unknown"
+)
+
+// Plan describes what applying the desired state would do, in the order apply
+// would do it. Objects to be pruned come last.
+type Plan struct {
+	Steps []PlanStep
+}
+
+// PlanStep is the change of a single object
+type PlanStep struct {
+	Ref    ObjectRef
+	Action PlanAction
+	// Diff holds the differences in `diff(1)` format, if any
+	Diff string
+}
+
+// Changes returns the steps that modify the cluster
+func (p Plan) Changes() []PlanStep {
+	var steps []PlanStep
+	for _, s := range p.Steps {
+		if s.Action != PlanUnchanged && s.Action != PlanSkip {
+			steps = append(steps, s)
+		}
+	}
+	return steps
+}
+
+// WhatIf computes the Plan of applying state using the subset diff. Objects
+// to be pruned are only included if opts.PruneSelector is set. state is not
+// modified.
+func WhatIf(c client.Client, state manifest.List, opts SubsetDiffOpts) (*Plan, error) {
+	ordered := append(manifest.List(nil), state...)
+	process.Sort(ordered)
+
+	// pruning is planned separately, so it can be attributed to objects
+	pruneSelector := opts.PruneSelector
+	opts.PruneSelector = nil
+
+	result, err := diffState(c, ordered, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]DiffEntry, len(result.Entries))
+	for _, e := range result.Entries {
+		if _, ok := entries[e.Name]; !ok {
+			entries[e.Name] = e
+		}
+	}
+
+	plan := Plan{Steps: make([]PlanStep, 0, len(ordered))}
+	for _, m := range ordered {
+		step := PlanStep{Ref: RefOf(m), Action: PlanSkip}
+		if e, ok := entries[util.DiffName(m)]; ok {
+			step.Diff = e.Diff
+			step.Action = e.Action()
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	if pruneSelector != nil {
+		candidates, err := pruneCandidates(c, ordered, mergeLabels(opts.Selector, pruneSelector), opts.PruneAllowlist)
+		if err != nil {
+			return nil, err
+		}
+		pruned, err := pruneEntries(candidates, opts)
+		if err != nil {
+			return nil, err
+		}
+		for i, m := range candidates {
+			plan.Steps = append(plan.Steps, PlanStep{
+				Ref:    RefOf(m),
+				Action: PlanPrune,
+				Diff:   pruned[i].Diff,
+			})
+		}
+	}
+
+	return &plan, nil
+}
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/tanka/pkg/kubernetes/client"
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// loadList reads all YAML documents of the given file
+func loadList(t *testing.T, file string) manifest.List {
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+
+	var list manifest.List
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var m manifest.Manifest
+		err := dec.Decode(&m)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		list = append(list, m)
+	}
+	return list
+}
+
+func TestWhatIf(t *testing.T) {
+	state := loadList(t, "testdata/whatif/environment.yaml")
+
+	c := newFakeClient(loadList(t, "testdata/whatif/cluster.yaml")...)
+	c.resources = client.Resources{
+		{Kind: "ConfigMap", Namespaced: true, Verbs: "[get list]"},
+		{Kind: "Service", Namespaced: true, Verbs: "[get list]"},
+		{Kind: "Deployment", APIGroup: "apps", Namespaced: true, Verbs: "[get list]"},
+		{Kind: "Namespace", Namespaced: false, Verbs: "[get list]"},
+	}
+
+	plan, err := WhatIf(c, state, SubsetDiffOpts{
+		PruneSelector: map[string]string{process.LabelEnvironment: "monitoring"},
+	})
+	require.NoError(t, err)
+
+	type step struct {
+		ref    string
+		action PlanAction
+	}
+	var got []step
+	for _, s := range plan.Steps {
+		got = append(got, step{s.Ref.String(), s.Action})
+	}
+
+	assert.Equal(t, []step{
+		{"v1/Namespace/monitoring", PlanUnchanged},
+		{"v1/ConfigMap/monitoring/grafana-config", PlanCreate},
+		{"v1/Service/monitoring/grafana", PlanCreate},
+		{"apps/v1/Deployment/monitoring/grafana", PlanUpdate},
+		{"v1/ConfigMap/monitoring/grafana-dashboards", PlanPrune},
+	}, got)
+
+	deploy := plan.Steps[3]
+	assert.Contains(t, deploy.Diff, "-  replicas: 1")
+	assert.Contains(t, deploy.Diff, "+  replicas: 2")
+	assert.Empty(t, plan.Steps[0].Diff)
+	assert.Contains(t, plan.Steps[4].Diff, "-  home.json: '{}'")
+
+	assert.Len(t, plan.Changes(), 4)
+
+	// the input is not reordered
+	assert.Equal(t, "Deployment", state[0].Kind())
+}
@@ -0,0 +1,40 @@
+package kubernetes
+
+import "strings"
+
+// normalizeWhitespace rewrites all multiline strings of m in place: CRLF line
+// endings are converted to LF, unless keepLineEndings is set. If trimTrailing
+// is set, whitespace at the end of each line is removed as well. These only
+// differ in presentation, e.g. depending on the editor a file was saved with.
+func normalizeWhitespace(m map[string]interface{}, keepLineEndings, trimTrailing bool) {
+	for k, v := range m {
+		m[k] = normalizeValue(v, keepLineEndings, trimTrailing)
+	}
+}
+
+func normalizeValue(v interface{}, keepLineEndings, trimTrailing bool) interface{} {
+	switch t := v.(type) {
+	case string:
+		if !strings.ContainsAny(t, "\r\n") {
+			return t
+		}
+		if !keepLineEndings {
+			t = strings.Replace(t, "\r\n", "\n", -1)
+		}
+		if trimTrailing {
+			lines := strings.Split(t, "\n")
+			for i, l := range lines {
+				lines[i] = strings.TrimRight(l, " \t")
+			}
+			t = strings.Join(lines, "\n")
+		}
+		return t
+	case map[string]interface{}:
+		normalizeWhitespace(t, keepLineEndings, trimTrailing)
+	case []interface{}:
+		for i := range t {
+			t[i] = normalizeValue(t[i], keepLineEndings, trimTrailing)
+		}
+	}
+	return v
+}
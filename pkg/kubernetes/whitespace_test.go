@@ -0,0 +1,55 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+)
+
+func TestNormalizeWhitespace(t *testing.T) {
+	crlf := configMap("foo", "default", map[string]interface{}{
+		"script.sh": "#!/bin/sh\r\necho hello\r\n",
+		"single":    "value",
+	})
+	lf := configMap("foo", "default", map[string]interface{}{
+		"script.sh": "#!/bin/sh\necho hello\n",
+		"single":    "value",
+	})
+	trailing := configMap("foo", "default", map[string]interface{}{
+		"script.sh": "#!/bin/sh  \necho hello\t\n",
+		"single":    "value",
+	})
+
+	cases := []struct {
+		name        string
+		local, live manifest.Manifest
+		opts        SubsetDiffOpts
+		changed     bool
+	}{
+		{name: "crlf", local: crlf, live: lf},
+		{name: "crlf-live", local: lf, live: crlf},
+		{name: "keep-line-endings", local: crlf, live: lf, opts: SubsetDiffOpts{KeepLineEndings: true}, changed: true},
+		{name: "trailing", local: lf, live: trailing, changed: true},
+		{name: "trim-trailing", local: lf, live: trailing, opts: SubsetDiffOpts{TrimTrailingSpace: true}},
+		{name: "trim-trailing-crlf", local: crlf, live: trailing, opts: SubsetDiffOpts{TrimTrailingSpace: true}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := DiffAgainst(manifest.List{c.local}, manifest.List{c.live}, c.opts)
+			require.NoError(t, err)
+			assert.Equal(t, c.changed, result.Entries[0].Diff != "", result.Entries[0].Diff)
+		})
+	}
+
+	// the desired state is not modified
+	assert.Equal(t, "#!/bin/sh\r\necho hello\r\n", crlf["data"].(map[string]interface{})["script.sh"])
+}
+
+func TestNormalizeValue(t *testing.T) {
+	v := normalizeValue([]interface{}{"a \r\nb\r\n", map[string]interface{}{"c": "d\t\n"}, 1.0}, false, true)
+	assert.Equal(t, []interface{}{"a\nb\n", map[string]interface{}{"c": "d\n"}, 1.0}, v)
+}
@@ -57,12 +57,13 @@ func (m Metadata) NameLabel() string {
 
 // Spec defines Kubernetes properties
 type Spec struct {
-	APIServer        string           `json:"apiServer"`
-	Namespace        string           `json:"namespace"`
-	DiffStrategy     string           `json:"diffStrategy,omitempty"`
-	InjectLabels     bool             `json:"injectLabels,omitempty"`
-	ResourceDefaults ResourceDefaults `json:"resourceDefaults"`
-	ExpectVersions   ExpectVersions   `json:"expectVersions"`
+	APIServer          string            `json:"apiServer"`
+	Namespace          string            `json:"namespace"`
+	DiffStrategy       string            `json:"diffStrategy,omitempty"`
+	KindDiffStrategies map[string]string `json:"kindDiffStrategies,omitempty"`
+	InjectLabels       bool              `json:"injectLabels,omitempty"`
+	ResourceDefaults   ResourceDefaults  `json:"resourceDefaults"`
+	ExpectVersions     ExpectVersions    `json:"expectVersions"`
 }
 
 // ExpectVersions holds semantic version constraints
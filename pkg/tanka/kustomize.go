@@ -0,0 +1,30 @@
+package tanka
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes"
+	"github.com/grafana/tanka/pkg/kustomize"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// DiffKustomize compares the environment at the given directory (a `baseDir`)
+// to the output of `kustomize build <kustomization>`, without contacting the
+// cluster. This helps verifying migrations from Kustomize to Tanka. Objects of
+// the Kustomization lacking a namespace are put into the default namespace
+// of the environment, like Tanka does for its own objects.
+// NOTE: This function requires `kustomize(1)` and `diff(1)`
+func DiffKustomize(baseDir, kustomization string, opts Opts) (*kubernetes.DiffResult, error) {
+	l, err := Load(baseDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline, err := kustomize.ExecKustomize{}.Build(kustomization)
+	if err != nil {
+		return nil, errors.Wrap(err, "building baseline")
+	}
+	baseline = process.Namespace(baseline, l.Env.Spec.Namespace)
+
+	return kubernetes.DiffRenders(baseline, l.Resources, kubernetes.SubsetDiffOpts{})
+}
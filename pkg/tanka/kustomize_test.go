@@ -0,0 +1,30 @@
+package tanka
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffKustomize(t *testing.T) {
+	stub, err := filepath.Abs("testdata/kustomize/kustomize")
+	require.NoError(t, err)
+	os.Setenv("TANKA_KUSTOMIZE_PATH", stub)
+	defer os.Unsetenv("TANKA_KUSTOMIZE_PATH")
+
+	result, err := DiffKustomize("./testdata/cases/withspecjson/", "./testdata/kustomize", Opts{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 2)
+
+	// matched, although the Kustomization sets no namespace
+	config := result.Entries[0]
+	assert.Equal(t, "v1.ConfigMap.withspec.config", config.Name)
+	assert.Contains(t, config.Diff, "-data:\n-  legacy: \"true\"\n")
+
+	legacy := result.Entries[1]
+	assert.Equal(t, "v1.Service.withspec.legacy", legacy.Name)
+	assert.Equal(t, []string{"(removed)"}, legacy.Notes)
+}
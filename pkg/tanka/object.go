@@ -0,0 +1,34 @@
+package tanka
+
+import (
+	"regexp"
+
+	"github.com/grafana/tanka/pkg/kubernetes"
+	"github.com/grafana/tanka/pkg/process"
+)
+
+// DiffObject evaluates the environment at the given directory (a `baseDir`)
+// and compares the single object referenced by ref to the cluster, without
+// fetching any other object. The Jsonnet is evaluated as a whole, but only
+// objects of the kind and name of ref are processed afterwards, so
+// opts.Filters is replaced.
+// NOTE: This function requires `diff(1)` and `kubectl(1)`
+func DiffObject(baseDir string, ref kubernetes.ObjectRef, opts Opts) (*kubernetes.DiffEntry, error) {
+	filters, err := process.StrExps(regexp.QuoteMeta(ref.Kind + "/" + ref.Name))
+	if err != nil {
+		return nil, err
+	}
+	opts.Filters = filters
+
+	l, err := Load(baseDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	kube, err := l.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer kube.Close()
+
+	return kube.DiffObject(l.Resources, ref)
+}
@@ -0,0 +1,25 @@
+package tanka
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tanka/pkg/kubernetes"
+)
+
+// DiffSelf evaluates the environment at the given directory (a `baseDir`)
+// twice and compares both renders, without contacting the cluster. Any
+// differences indicate that the environment does not render
+// deterministically, e.g. because of Helm charts or Kustomizations generating
+// random values. Meant as a sanity check in CI.
+func DiffSelf(baseDir string, opts Opts) (*kubernetes.DiffResult, error) {
+	a, err := Load(baseDir, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "first render")
+	}
+	b, err := Load(baseDir, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "second render")
+	}
+
+	return kubernetes.DiffRenders(a.Resources, b.Resources, kubernetes.SubsetDiffOpts{})
+}
@@ -0,0 +1,35 @@
+package tanka
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSelf(t *testing.T) {
+	stub, err := filepath.Abs("testdata/kustomize/random")
+	require.NoError(t, err)
+	os.Setenv("TANKA_KUSTOMIZE_PATH", stub)
+	defer os.Unsetenv("TANKA_KUSTOMIZE_PATH")
+
+	result, err := DiffSelf("./testdata/cases/nondeterministic/", Opts{})
+	require.NoError(t, err)
+	require.True(t, result.HasDrift())
+
+	var changed []string
+	for _, e := range result.Entries {
+		if e.Diff != "" {
+			changed = append(changed, e.Name)
+		}
+	}
+	assert.Equal(t, []string{"v1.Secret.default.generated"}, changed)
+
+	// Jsonnet alone is deterministic
+	result, err = DiffSelf("./testdata/cases/withspecjson/", Opts{})
+	require.NoError(t, err)
+	assert.False(t, result.HasDrift())
+	assert.Len(t, result.Entries, 1)
+}
@@ -5,10 +5,13 @@ import (
 	"log"
 
 	"github.com/fatih/color"
+	"github.com/pkg/errors"
 
 	"github.com/grafana/tanka/pkg/kubernetes"
 	"github.com/grafana/tanka/pkg/kubernetes/client"
 	"github.com/grafana/tanka/pkg/kubernetes/manifest"
+	"github.com/grafana/tanka/pkg/process"
+	"github.com/grafana/tanka/pkg/spec/v1alpha1"
 	"github.com/grafana/tanka/pkg/term"
 )
 
@@ -89,7 +92,7 @@ func confirmPrompt(action, namespace string, info client.Info) error {
 type DiffOpts struct {
 	Opts
 
-	// Strategy must be one of "native" or "subset"
+	// Strategy must be one of "server", "native", "subset", "exact" or "mixed"
 	Strategy string
 	// Summarize prints a summary, instead of the actual diff
 	Summarize bool
@@ -97,6 +100,19 @@ type DiffOpts struct {
 	WithPrune bool
 	// Exit with 0 even when differences are found
 	ExitZero bool
+	// Kinds limits the diff to objects of these kinds
+	Kinds []string
+	// SortByChanges shows the objects with the most changed lines first
+	SortByChanges bool
+	// MinChanges hides objects with less changed lines than this
+	MinChanges int
+	// OnlyNew only reports objects that do not exist in the cluster yet
+	OnlyNew bool
+	// ShowStatusReplicas shows the replica counts of the status of workloads
+	ShowStatusReplicas bool
+	// AwaitCRDs reports custom resources as created, if their CRD is part of
+	// the environment but not applied yet
+	AwaitCRDs bool
 }
 
 // Diff parses the environment at the given directory (a `baseDir`) and returns
@@ -116,11 +132,65 @@ func Diff(baseDir string, opts DiffOpts) (*string, error) {
 	}
 	defer kube.Close()
 
-	return kube.Diff(l.Resources, kubernetes.DiffOpts{
+	return kube.Diff(l.Resources, opts.kubernetes())
+}
+
+// DiffRendered is like Diff, but compares the cluster to already rendered
+// manifests, e.g. committed output of `tk export`, instead of evaluating the
+// Jsonnet of the environment. The environment at `baseDir` is only used for
+// its metadata, like the cluster to compare to. `dir` is loaded using
+// manifest.LoadExport, so only the files of this environment are used if
+// multiple were exported. opts.Filters apply as usual.
+// NOTE: This function requires `diff(1)`, `kubectl(1)` and perhaps `diffstat(1)`
+func DiffRendered(baseDir, dir string, opts DiffOpts) (*string, error) {
+	env, err := Peek(baseDir, opts.Opts)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := loadRendered(env, dir, opts.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	l := LoadResult{Env: env, Resources: list}
+	kube, err := l.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer kube.Close()
+
+	return kube.Diff(l.Resources, opts.kubernetes())
+}
+
+// loadRendered loads the manifests of env from dir (see DiffRendered)
+func loadRendered(env *v1alpha1.Environment, dir string, filters process.Matchers) (manifest.List, error) {
+	list, err := manifest.LoadExport(dir, env.Metadata.Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading rendered manifests")
+	}
+	list = process.Namespace(list, env.Spec.Namespace)
+	if len(filters) > 0 {
+		list = process.Filter(list, filters)
+	}
+	return list, nil
+}
+
+// kubernetes returns the options of kubernetes.Diff
+func (opts DiffOpts) kubernetes() kubernetes.DiffOpts {
+	return kubernetes.DiffOpts{
 		Summarize: opts.Summarize,
 		Strategy:  opts.Strategy,
 		WithPrune: opts.WithPrune,
-	})
+		Kinds:     opts.Kinds,
+
+		SortByChanges: opts.SortByChanges,
+		MinChanges:    opts.MinChanges,
+		OnlyNew:       opts.OnlyNew,
+
+		ShowStatusReplicas: opts.ShowStatusReplicas,
+		AwaitCRDs:          opts.AwaitCRDs,
+	}
 }
 
 // DeleteOpts specify additional properties for the Delete operation
@@ -0,0 +1,46 @@
+package tanka
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tanka/pkg/process"
+	"github.com/grafana/tanka/pkg/spec/v1alpha1"
+)
+
+func TestLoadRendered(t *testing.T) {
+	// output of exporting two environments
+	dir := t.TempDir()
+	files := map[string]string{
+		"manifest.json": `{
+    "logging/v1.ConfigMap-loki.yaml": "environments/logging/main.jsonnet",
+    "monitoring/v1.ConfigMap-grafana.yaml": "environments/monitoring/main.jsonnet"
+}`,
+		"logging/v1.ConfigMap-loki.yaml":       "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: loki\n",
+		"monitoring/v1.ConfigMap-grafana.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: grafana\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	env := v1alpha1.New()
+	env.Metadata.Namespace = "environments/monitoring/main.jsonnet"
+	env.Spec.Namespace = "monitoring"
+
+	list, err := loadRendered(env, dir, nil)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "ConfigMap/grafana", list[0].KindName())
+	assert.Equal(t, "monitoring", list[0].Metadata().Namespace())
+
+	list, err = loadRendered(env, dir, process.MustStrExps("Deployment/.*"))
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,56 @@
+package term
+
+import (
+	"strings"
+)
+
+// Wrap breaks the lines of the unified diff d that are longer than width
+// columns. Continuation lines repeat the prefix of the original line (`+`,
+// `-` or ` `), so they are colorized and read like it. File headers (`---`,
+// `+++`) and other lines lacking such a prefix are kept as is. A width of 0
+// disables wrapping. The result is meant for reading only, as the line counts
+// of the hunk headers no longer match.
+func Wrap(d string, width int) string {
+	if width <= 1 {
+		return d
+	}
+
+	lines := strings.Split(d, "\n")
+	out := make([]string, 0, len(lines))
+	for _, l := range lines {
+		out = append(out, wrapLine(l, width)...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// wrapLine wraps a single line of a diff, see Wrap
+func wrapLine(l string, width int) []string {
+	r := []rune(l)
+	if len(r) <= width || !wrappable(l) {
+		return []string{l}
+	}
+
+	prefix, content := r[0], r[1:]
+	var lines []string
+	for len(content) > 0 {
+		n := width - 1
+		if n > len(content) {
+			n = len(content)
+		}
+		lines = append(lines, string(prefix)+string(content[:n]))
+		content = content[n:]
+	}
+	return lines
+}
+
+// wrappable returns whether l is a content line of a diff
+func wrappable(l string) bool {
+	if strings.HasPrefix(l, "--- ") || strings.HasPrefix(l, "+++ ") {
+		return false
+	}
+	switch l[0] {
+	case '+', '-', ' ':
+		return true
+	}
+	return false
+}
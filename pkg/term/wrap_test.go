@@ -0,0 +1,68 @@
+package term
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrap(t *testing.T) {
+	digest := "grafana/grafana@sha256:0123456789abcdef0123456789abcdef"
+	data := strings.Join([]string{
+		"diff -u -N /tmp/LIVE-155518783/apps.v1.Deployment.default.grafana /tmp/MERGED-942280082/apps.v1.Deployment.default.grafana",
+		"--- /tmp/LIVE-155518783/apps.v1.Deployment.default.grafana",
+		"+++ /tmp/MERGED-942280082/apps.v1.Deployment.default.grafana",
+		"@@ -32,3 +32,3 @@",
+		"       containers:",
+		"-      - image: " + digest,
+		"+      - image: " + digest + "ff",
+		"         name: grafana",
+	}, "\n")
+
+	got := Wrap(data, 30)
+	assert.Equal(t, strings.Join([]string{
+		// headers are kept as is
+		"diff -u -N /tmp/LIVE-155518783/apps.v1.Deployment.default.grafana /tmp/MERGED-942280082/apps.v1.Deployment.default.grafana",
+		"--- /tmp/LIVE-155518783/apps.v1.Deployment.default.grafana",
+		"+++ /tmp/MERGED-942280082/apps.v1.Deployment.default.grafana",
+		"@@ -32,3 +32,3 @@",
+		"       containers:",
+		"-      - image: grafana/grafan",
+		"-a@sha256:0123456789abcdef0123",
+		"-456789abcdef",
+		"+      - image: grafana/grafan",
+		"+a@sha256:0123456789abcdef0123",
+		"+456789abcdefff",
+		"         name: grafana",
+	}, "\n"), got)
+
+	for _, l := range strings.Split(got, "\n") {
+		if !strings.HasPrefix(l, "diff ") && !strings.HasPrefix(l, "--- ") && !strings.HasPrefix(l, "+++ ") {
+			assert.LessOrEqual(t, len(l), 30, l)
+		}
+	}
+
+	// the continuation lines reassemble to the original ones
+	assert.Equal(t, "-      - image: "+digest, reassemble(got, 5, 3))
+	assert.Equal(t, "+      - image: "+digest+"ff", reassemble(got, 8, 3))
+
+	// disabled
+	assert.Equal(t, data, Wrap(data, 0))
+}
+
+func TestWrapRunes(t *testing.T) {
+	got := Wrap("+äöüäöü", 4)
+	assert.Equal(t, "+äöü\n+äöü", got)
+}
+
+// reassemble joins n lines of d starting at the given one, stripping the
+// prefix of the continuation lines
+func reassemble(d string, start, n int) string {
+	lines := strings.Split(d, "\n")[start : start+n]
+	s := lines[0]
+	for _, l := range lines[1:] {
+		s += l[1:]
+	}
+	return s
+}